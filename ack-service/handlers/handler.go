@@ -26,7 +26,7 @@ func (h *ACKHandler) ACKAlarm(c *gin.Context) {
 		return
 	}
 
-	err := h.service.ACKAlarm(alarmID)
+	err := h.service.ACKAlarm(c.Request.Context(), alarmID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "failed to ACK alarm",
@@ -65,3 +65,9 @@ func (h *ACKHandler) CheckACKState(c *gin.Context) {
 		"should_notify":        shouldNotify,
 	})
 }
+
+// ReconcilerMetrics reports the drift found by the reconciler between
+// ACK states and alarm statuses
+func (h *ACKHandler) ReconcilerMetrics(c *gin.Context) {
+	c.JSON(http.StatusOK, h.service.ReconcilerMetrics())
+}
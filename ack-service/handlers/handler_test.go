@@ -1,12 +1,14 @@
 package handlers
 
 import (
+	"context"
 	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 	"time"
 
+	"github.com/26christy/CarbonQuest/ack-service/service"
 	"github.com/26christy/CarbonQuest/common/models"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
@@ -18,7 +20,7 @@ type MockACKService struct {
 	mock.Mock
 }
 
-func (m *MockACKService) ACKAlarm(alarmID string) error {
+func (m *MockACKService) ACKAlarm(ctx context.Context, alarmID string) error {
 	args := m.Called(alarmID)
 	return args.Error(0)
 }
@@ -28,6 +30,21 @@ func (m *MockACKService) GetACKState(alarmID string) (models.ACKState, bool) {
 	return args.Get(0).(models.ACKState), args.Bool(1)
 }
 
+func (m *MockACKService) StartReconciler() func() {
+	m.Called()
+	return func() {}
+}
+
+func (m *MockACKService) Reconcile(ctx context.Context, now time.Time) (map[string]int, error) {
+	args := m.Called(now)
+	return args.Get(0).(map[string]int), args.Error(1)
+}
+
+func (m *MockACKService) ReconcilerMetrics() service.ReconcileMetrics {
+	args := m.Called()
+	return args.Get(0).(service.ReconcileMetrics)
+}
+
 func setupTestRouter() (*gin.Engine, *MockACKService) {
 	gin.SetMode(gin.TestMode)
 	router := gin.Default()
@@ -125,3 +142,22 @@ func TestCheckACKState_Exists_ShouldNotNotify(t *testing.T) {
 	assert.Contains(t, resp.Body.String(), `"should_notify":false`)
 	assert.Contains(t, resp.Body.String(), `"alarm_id":"123"`)
 }
+
+func TestReconcilerMetrics(t *testing.T) {
+	router, mockService := setupTestRouter()
+
+	mockService.On("ReconcilerMetrics").Return(service.ReconcileMetrics{
+		Runs:       3,
+		DriftTotal: map[string]int{service.DriftMissingACKState: 2},
+		Repaired:   2,
+	})
+
+	req, _ := http.NewRequest(http.MethodGet, "/ack/reconciler/metrics", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"runs":3`)
+	assert.Contains(t, w.Body.String(), `"drift_total":{"missing_ack_state":2}`)
+	mockService.AssertExpectations(t)
+}
@@ -12,5 +12,6 @@ func RegisterRoutes(router *gin.Engine, ackService service.ACKService) {
 	{
 		ackGroup.POST("/:id", handler.ACKAlarm)
 		ackGroup.GET("/:id", handler.CheckACKState)
+		ackGroup.GET("/reconciler/metrics", handler.ReconcilerMetrics)
 	}
 }
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"io"
 	"log"
 	"net/http"
 	"os"
@@ -12,6 +13,7 @@ import (
 	"github.com/26christy/CarbonQuest/ack-service/handlers"
 	"github.com/26christy/CarbonQuest/ack-service/service"
 	"github.com/26christy/CarbonQuest/ack-service/storage"
+	"github.com/26christy/CarbonQuest/common/client"
 	"github.com/26christy/CarbonQuest/middleware"
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
@@ -42,14 +44,25 @@ func main() {
 	router.Use(gin.Recovery())
 	router.Use(middleware.ErrorHandler(logger))
 
-	// Initialize in-memory storage
-	ackStorage := storage.NewMemoryStorage()
+	// Initialize storage, STORAGE_TYPE selects the backend (memory or sqlite)
+	ackStorage, err := storage.CreateStorage(os.Getenv("STORAGE_TYPE"), os.Getenv("STORAGE_PATH"))
+	if err != nil {
+		logger.Fatalf("Failed to initialize storage: %v", err)
+	}
 
-	// Use the default HTTP client
-	httpClient := &http.Client{}
+	// ALARM_SERVICE_URL overrides the alarm-service address built from HOST and ALARM_SERVICE_PORT
+	alarmClient := client.NewAlarmClient(client.Config{
+		BaseURL:       client.BaseURLFromEnv("ALARM_SERVICE_URL", "ALARM_SERVICE_PORT"),
+		ServiceName:   os.Getenv("SERVICE_NAME"),
+		InternalToken: os.Getenv("INTERNAL_TOKEN"),
+	})
 
 	// Initialize the ACK service
-	ackService := service.NewACKService(ackStorage, httpClient)
+	ackService := service.NewACKService(ackStorage, alarmClient)
+
+	// Repair drift between ACK states and alarm statuses every RECONCILE_INTERVAL minutes
+	stopReconciler := ackService.StartReconciler()
+	logger.Info("ACK reconciler started")
 
 	// Register API routes
 	handlers.RegisterRoutes(router, ackService)
@@ -69,6 +82,17 @@ func main() {
 
 	// Graceful shutdown
 	gracefulShutdown(server, logger)
+
+	// Wait for a reconciler run in progress before the storage is closed
+	stopReconciler()
+	logger.Info("ACK reconciler stopped")
+
+	// Release the database handle for persistent stores
+	if closer, ok := ackStorage.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			logger.Errorf("Failed to close storage: %v", err)
+		}
+	}
 }
 
 // gracefulShutdown handles clean shutdown on SIGINT or SIGTERM
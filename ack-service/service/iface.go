@@ -1,8 +1,17 @@
 package service
 
-import "github.com/26christy/CarbonQuest/common/models"
+import (
+	"context"
+	"time"
+
+	"github.com/26christy/CarbonQuest/common/models"
+)
 
 type ACKService interface {
-	ACKAlarm(alarmID string) error
+	ACKAlarm(ctx context.Context, alarmID string) error
 	GetACKState(alarmID string) (models.ACKState, bool)
+	// StartReconciler returns a function that stops the reconciler and waits for a run in progress
+	StartReconciler() (stop func())
+	Reconcile(ctx context.Context, now time.Time) (map[string]int, error)
+	ReconcilerMetrics() ReconcileMetrics
 }
@@ -0,0 +1,203 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/26christy/CarbonQuest/common/models"
+)
+
+// Kinds of drift between ACK states and alarm statuses found by the reconciler
+const (
+	// DriftPendingACK is an ACK that was started but never confirmed or rolled back
+	DriftPendingACK = "pending_ack"
+	// DriftMissingACKState is an alarm in status ACK without an ACK state
+	DriftMissingACKState = "missing_ack_state"
+	// DriftStaleACKState is an ACK state for an alarm that is no longer in status ACK
+	DriftStaleACKState = "stale_ack_state"
+	// DriftOrphanedACKState is an ACK state for an alarm that no longer exists
+	DriftOrphanedACKState = "orphaned_ack_state"
+)
+
+// reconcileGrace leaves recent ACKs alone, so an ACK still in flight is not taken for drift
+const reconcileGrace = time.Minute
+
+// ReconcileMetrics reports what the reconciler has found and repaired since start
+type ReconcileMetrics struct {
+	Runs           int            `json:"runs"`
+	FailedRuns     int            `json:"failed_runs"`
+	LastRunAt      *time.Time     `json:"last_run_at"`
+	LastError      string         `json:"last_error,omitempty"`
+	LastDrift      map[string]int `json:"last_drift"`
+	DriftTotal     map[string]int `json:"drift_total"`
+	Repaired       int            `json:"repaired"`
+	RepairFailures int            `json:"repair_failures"`
+}
+
+// StartReconciler compares ACK states with alarm statuses every RECONCILE_INTERVAL minutes
+// until the returned stop function is called. Stop cancels a run in progress and waits for it.
+func (s *ACKServiceImpl) StartReconciler() (stop func()) {
+	interval, err := strconv.Atoi(os.Getenv("RECONCILE_INTERVAL"))
+	if err != nil || interval <= 0 {
+		interval = 5 // default is every 5 minutes
+	}
+	return s.startReconciler(time.Duration(interval) * time.Minute)
+}
+
+func (s *ACKServiceImpl) startReconciler(interval time.Duration) (stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case t := <-ticker.C:
+				drift, err := s.Reconcile(ctx, t)
+				if err != nil {
+					fmt.Printf("[ERROR] Reconciler run failed: %v\n", err)
+					continue
+				}
+				if len(drift) > 0 {
+					fmt.Printf("[INFO] Reconciler found drift: %v\n", drift)
+				}
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-stopped
+	}
+}
+
+// Reconcile repairs drift between the local ACK states and the alarm statuses
+// in alarm-service, which is the source of truth. It returns the drift found per kind.
+func (s *ACKServiceImpl) Reconcile(ctx context.Context, now time.Time) (map[string]int, error) {
+	s.reconcileMu.Lock()
+	defer s.reconcileMu.Unlock()
+
+	drift := make(map[string]int)
+	repaired, failures := 0, 0
+	repair := func(kind, alarmID string, err error) {
+		drift[kind]++
+		if err != nil {
+			failures++
+			fmt.Printf("[ERROR] Failed to repair %s for alarm %s: %v\n", kind, alarmID, err)
+			return
+		}
+		repaired++
+	}
+
+	// Alarms are read first, so anything stored afterwards is at least as new
+	list, err := s.alarms.ListAlarms(ctx, models.AlarmQuery{})
+	if err != nil {
+		s.recordReconcile(now, nil, 0, 0, err)
+		return nil, err
+	}
+	pending, err := s.storage.GetPendingACKs()
+	if err != nil {
+		s.recordReconcile(now, nil, 0, 0, err)
+		return nil, err
+	}
+	ackStates, err := s.storage.GetAllACKStates()
+	if err != nil {
+		s.recordReconcile(now, nil, 0, 0, err)
+		return nil, err
+	}
+
+	statuses := make(map[string]string, len(list.Alarms))
+	for _, alarm := range list.Alarms {
+		statuses[alarm.ID.String()] = alarm.Status
+	}
+	cutoff := now.Add(-reconcileGrace)
+
+	// A pending ACK is completed if alarm-service has the ACK, otherwise dropped
+	inFlight := make(map[string]bool, len(pending))
+	for _, p := range pending {
+		inFlight[p.AlarmID] = true
+		if p.MarkedAt.After(cutoff) {
+			continue
+		}
+		if statuses[p.AlarmID] == "ACK" {
+			repair(DriftPendingACK, p.AlarmID, s.storage.ACKAlarm(p.AlarmID))
+		} else {
+			repair(DriftPendingACK, p.AlarmID, s.storage.ClearPendingACK(p.AlarmID))
+		}
+	}
+
+	acked := make(map[string]bool, len(ackStates))
+	for _, ackState := range ackStates {
+		acked[ackState.AlarmID] = true
+		if ackState.ACKedAt.After(cutoff) {
+			continue
+		}
+		status, exists := statuses[ackState.AlarmID]
+		switch {
+		case !exists:
+			repair(DriftOrphanedACKState, ackState.AlarmID, s.storage.DeleteACKState(ackState.AlarmID))
+		case status != "ACK":
+			repair(DriftStaleACKState, ackState.AlarmID, s.storage.DeleteACKState(ackState.AlarmID))
+		}
+	}
+
+	for alarmID, status := range statuses {
+		if status == "ACK" && !acked[alarmID] && !inFlight[alarmID] {
+			repair(DriftMissingACKState, alarmID, s.storage.ACKAlarm(alarmID))
+		}
+	}
+
+	s.recordReconcile(now, drift, repaired, failures, nil)
+	return drift, nil
+}
+
+// ReconcilerMetrics returns a snapshot of the reconciler metrics
+func (s *ACKServiceImpl) ReconcilerMetrics() ReconcileMetrics {
+	s.metricsMu.Lock()
+	defer s.metricsMu.Unlock()
+
+	metrics := s.metrics
+	metrics.LastDrift = copyCounts(s.metrics.LastDrift)
+	metrics.DriftTotal = copyCounts(s.metrics.DriftTotal)
+	return metrics
+}
+
+func (s *ACKServiceImpl) recordReconcile(at time.Time, drift map[string]int, repaired, failures int, err error) {
+	s.metricsMu.Lock()
+	defer s.metricsMu.Unlock()
+
+	s.metrics.Runs++
+	s.metrics.LastRunAt = &at
+	if err != nil {
+		s.metrics.FailedRuns++
+		s.metrics.LastError = err.Error()
+		return
+	}
+
+	s.metrics.LastError = ""
+	s.metrics.LastDrift = drift
+	if s.metrics.DriftTotal == nil {
+		s.metrics.DriftTotal = make(map[string]int)
+	}
+	for kind, count := range drift {
+		s.metrics.DriftTotal[kind] += count
+	}
+	s.metrics.Repaired += repaired
+	s.metrics.RepairFailures += failures
+}
+
+func copyCounts(counts map[string]int) map[string]int {
+	copied := make(map[string]int, len(counts))
+	for kind, count := range counts {
+		copied[kind] = count
+	}
+	return copied
+}
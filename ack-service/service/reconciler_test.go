@@ -0,0 +1,140 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/26christy/CarbonQuest/ack-service/storage"
+	"github.com/26christy/CarbonQuest/common/client"
+	"github.com/26christy/CarbonQuest/common/models"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeAlarmService serves GET /alarms with the given alarms and returns a client for it
+func fakeAlarmService(t *testing.T, alarms []models.Alarm) *client.AlarmClient {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/alarms", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"alarms": alarms})
+	})
+
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+
+	return client.NewAlarmClient(client.Config{BaseURL: server.URL})
+}
+
+func TestReconcile_RepairsDrift(t *testing.T) {
+	acked := models.Alarm{ID: uuid.New(), Status: "ACK"}          // ACK stored in alarm-service only
+	retriggered := models.Alarm{ID: uuid.New(), Status: "active"} // ACK state left over from before
+	confirmed := models.Alarm{ID: uuid.New(), Status: "ACK"}      // pending ACK that reached alarm-service
+	abandoned := models.Alarm{ID: uuid.New(), Status: "active"}   // pending ACK that never did
+	healthy := models.Alarm{ID: uuid.New(), Status: "ACK"}
+	alarms := fakeAlarmService(t, []models.Alarm{acked, retriggered, confirmed, abandoned, healthy})
+
+	store := storage.NewMemoryStorage()
+	require.NoError(t, store.ACKAlarm(retriggered.ID.String()))
+	require.NoError(t, store.ACKAlarm(healthy.ID.String()))
+	require.NoError(t, store.ACKAlarm("deleted-alarm"))
+	require.NoError(t, store.MarkPendingACK(confirmed.ID.String(), "active"))
+	require.NoError(t, store.MarkPendingACK(abandoned.ID.String(), "active"))
+
+	service := NewACKService(store, alarms)
+
+	// Run past the grace period so nothing counts as in flight
+	drift, err := service.Reconcile(context.Background(), time.Now().Add(2*reconcileGrace))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{
+		DriftMissingACKState:  1,
+		DriftStaleACKState:    1,
+		DriftOrphanedACKState: 1,
+		DriftPendingACK:       2,
+	}, drift)
+
+	_, exists := store.GetACKState(acked.ID.String())
+	assert.True(t, exists, "a missing ACK state should be recreated")
+	_, exists = store.GetACKState(retriggered.ID.String())
+	assert.False(t, exists, "a stale ACK state should be removed")
+	_, exists = store.GetACKState("deleted-alarm")
+	assert.False(t, exists, "an orphaned ACK state should be removed")
+	_, exists = store.GetACKState(confirmed.ID.String())
+	assert.True(t, exists, "a pending ACK present in alarm-service should be confirmed")
+	_, exists = store.GetACKState(abandoned.ID.String())
+	assert.False(t, exists, "a pending ACK missing from alarm-service should be dropped")
+	pending, _ := store.GetPendingACKs()
+	assert.Empty(t, pending)
+
+	// A second run finds nothing left to fix
+	drift, err = service.Reconcile(context.Background(), time.Now().Add(2*reconcileGrace))
+	require.NoError(t, err)
+	assert.Empty(t, drift)
+
+	metrics := service.ReconcilerMetrics()
+	assert.Equal(t, 2, metrics.Runs)
+	assert.Equal(t, 5, metrics.Repaired)
+	assert.Equal(t, 2, metrics.DriftTotal[DriftPendingACK])
+	assert.Empty(t, metrics.LastDrift)
+}
+
+func TestReconcile_SkipsACKsInFlight(t *testing.T) {
+	inFlight := models.Alarm{ID: uuid.New(), Status: "ACK"}
+	justACKed := models.Alarm{ID: uuid.New(), Status: "active"}
+	alarms := fakeAlarmService(t, []models.Alarm{inFlight, justACKed})
+
+	store := storage.NewMemoryStorage()
+	require.NoError(t, store.MarkPendingACK(inFlight.ID.String(), "active"))
+	require.NoError(t, store.ACKAlarm(justACKed.ID.String()))
+
+	service := NewACKService(store, alarms)
+
+	drift, err := service.Reconcile(context.Background(), time.Now())
+	require.NoError(t, err)
+	assert.Empty(t, drift)
+
+	pending, _ := store.GetPendingACKs()
+	assert.Len(t, pending, 1, "a recent pending ACK belongs to a request still running")
+	_, exists := store.GetACKState(justACKed.ID.String())
+	assert.True(t, exists)
+}
+
+func TestReconcile_AlarmServiceDown(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	alarms := client.NewAlarmClient(client.Config{BaseURL: server.URL, MaxRetries: -1})
+
+	store := storage.NewMemoryStorage()
+	require.NoError(t, store.ACKAlarm("alarm-1"))
+
+	service := NewACKService(store, alarms)
+
+	_, err := service.Reconcile(context.Background(), time.Now().Add(2*reconcileGrace))
+	assert.Error(t, err)
+
+	_, exists := store.GetACKState("alarm-1")
+	assert.True(t, exists, "nothing may be repaired without the alarm statuses")
+
+	metrics := service.ReconcilerMetrics()
+	assert.Equal(t, 1, metrics.Runs)
+	assert.Equal(t, 1, metrics.FailedRuns)
+	assert.NotEmpty(t, metrics.LastError)
+}
+
+func TestStartReconciler_Stop(t *testing.T) {
+	service := NewACKService(storage.NewMemoryStorage(), fakeAlarmService(t, nil)).(*ACKServiceImpl)
+
+	stop := service.startReconciler(time.Millisecond)
+	require.Eventually(t, func() bool {
+		return service.ReconcilerMetrics().Runs > 0
+	}, time.Second, time.Millisecond)
+
+	stop()
+	runs := service.ReconcilerMetrics().Runs
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, runs, service.ReconcilerMetrics().Runs, "no run may start after stop returns")
+}
@@ -1,85 +1,78 @@
 package service
 
 import (
-	"bytes"
-	"encoding/json"
-	"errors"
+	"context"
 	"fmt"
-	"io"
-	"net/http"
-	"os"
+	"sync"
 
 	"github.com/26christy/CarbonQuest/ack-service/storage"
+	"github.com/26christy/CarbonQuest/common/client"
 	"github.com/26christy/CarbonQuest/common/models"
 )
 
 type ACKServiceImpl struct {
-	storage    storage.ACKStorage
-	httpClient *http.Client
-}
+	storage storage.ACKStorage
+	alarms  *client.AlarmClient
 
-func NewACKService(storage storage.ACKStorage, client *http.Client) ACKService {
-	return &ACKServiceImpl{
-		storage:    storage,
-		httpClient: client,
-	}
+	// reconcileMu allows one reconciler run at a time, metricsMu guards metrics
+	reconcileMu sync.Mutex
+	metricsMu   sync.Mutex
+	metrics     ReconcileMetrics
 }
 
-// ACKAlarm marks an alarm as acknowledged and sets next notification time
-func (s *ACKServiceImpl) ACKAlarm(alarmID string) error {
-	_, success := s.callUpdateAlarm(alarmID)
-	if !success {
-		return errors.New("failed to update the alarm status")
+func NewACKService(storage storage.ACKStorage, alarms *client.AlarmClient) ACKService {
+	return &ACKServiceImpl{
+		storage: storage,
+		alarms:  alarms,
 	}
-	return s.storage.ACKAlarm(alarmID)
 }
 
-// ShouldNotify checks if the alarm should be notified based on ACK state
-func (s *ACKServiceImpl) GetACKState(alarmID string) (models.ACKState, bool) {
-	return s.storage.GetACKState(alarmID)
-}
-
-func (s *ACKServiceImpl) callUpdateAlarm(alarmID string) (models.UpdateAlarm, bool) {
-	host := os.Getenv("HOST")
-	port := os.Getenv("ALARM_SERVICE_PORT")
-	url := fmt.Sprintf("http://%s:%s/alarms/%s", host, port, alarmID)
-
-	// Create the request payload
-	payload := map[string]string{
-		"status": "ACK",
-	}
-
-	payloadBytes, err := json.Marshal(payload)
+// ACKAlarm marks an alarm as acknowledged and sets next notification time.
+// The ACK is marked as pending locally, applied in alarm-service and then confirmed.
+// A failed step undoes the steps before it, and anything left half done
+// is repaired by the reconciler.
+func (s *ACKServiceImpl) ACKAlarm(ctx context.Context, alarmID string) error {
+	alarm, err := s.alarms.GetAlarm(ctx, alarmID)
 	if err != nil {
-		return models.UpdateAlarm{}, false
+		return fmt.Errorf("failed to fetch the alarm: %w", err)
 	}
 
-	req, err := http.NewRequest(http.MethodPut, url, bytes.NewBuffer(payloadBytes))
-	if err != nil {
-		return models.UpdateAlarm{}, false
+	if err := s.storage.MarkPendingACK(alarmID, alarm.Status); err != nil {
+		return fmt.Errorf("failed to mark the ACK as pending: %w", err)
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := s.httpClient.Do(req)
-	if err != nil {
-		return models.UpdateAlarm{}, false
+	if _, err := s.alarms.UpdateAlarmStatus(ctx, alarmID, "ACK"); err != nil {
+		if err := s.storage.ClearPendingACK(alarmID); err != nil {
+			fmt.Printf("[ERROR] Failed to clear pending ACK for %s: %v\n", alarmID, err)
+		}
+		return fmt.Errorf("failed to update the alarm status: %w", err)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return models.UpdateAlarm{}, false
+	if err := s.storage.ACKAlarm(alarmID); err != nil {
+		s.compensateACK(ctx, alarmID, alarm.Status)
+		return fmt.Errorf("failed to store the ACK state: %w", err)
 	}
+	return nil
+}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return models.UpdateAlarm{}, false
+// compensateACK restores the alarm status in alarm-service after the ACK
+// could not be stored. alarm-service accepts ACK back to triggered from other
+// services for this. If that fails too the pending mark is kept, and the
+// reconciler completes the ACK instead.
+func (s *ACKServiceImpl) compensateACK(ctx context.Context, alarmID, previousStatus string) {
+	if previousStatus != "ACK" {
+		if _, err := s.alarms.UpdateAlarmStatus(ctx, alarmID, previousStatus); err != nil {
+			fmt.Printf("[ERROR] Failed to restore status %s for alarm %s, leaving it to the reconciler: %v\n", previousStatus, alarmID, err)
+			return
+		}
 	}
 
-	var updatedAlarm models.UpdateAlarm
-	if err := json.Unmarshal(body, &updatedAlarm); err != nil {
-		return models.UpdateAlarm{}, false
+	if err := s.storage.ClearPendingACK(alarmID); err != nil {
+		fmt.Printf("[ERROR] Failed to clear pending ACK for %s: %v\n", alarmID, err)
 	}
+}
 
-	return updatedAlarm, true
+// ShouldNotify checks if the alarm should be notified based on ACK state
+func (s *ACKServiceImpl) GetACKState(alarmID string) (models.ACKState, bool) {
+	return s.storage.GetACKState(alarmID)
 }
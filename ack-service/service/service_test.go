@@ -2,13 +2,25 @@ package service
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"io"
 	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/26christy/CarbonQuest/alarm-service/events"
+	"github.com/26christy/CarbonQuest/alarm-service/handlers"
+	alarmservice "github.com/26christy/CarbonQuest/alarm-service/service"
+	alarmstorage "github.com/26christy/CarbonQuest/alarm-service/storage"
+	"github.com/26christy/CarbonQuest/common/client"
 	"github.com/26christy/CarbonQuest/common/models"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 // Mock for the ACKStorage interface
@@ -26,6 +38,31 @@ func (m *MockACKStorage) GetACKState(alarmID string) (models.ACKState, bool) {
 	return args.Get(0).(models.ACKState), args.Bool(1)
 }
 
+func (m *MockACKStorage) GetAllACKStates() ([]models.ACKState, error) {
+	args := m.Called()
+	return args.Get(0).([]models.ACKState), args.Error(1)
+}
+
+func (m *MockACKStorage) DeleteACKState(alarmID string) error {
+	args := m.Called(alarmID)
+	return args.Error(0)
+}
+
+func (m *MockACKStorage) MarkPendingACK(alarmID, previousStatus string) error {
+	args := m.Called(alarmID, previousStatus)
+	return args.Error(0)
+}
+
+func (m *MockACKStorage) ClearPendingACK(alarmID string) error {
+	args := m.Called(alarmID)
+	return args.Error(0)
+}
+
+func (m *MockACKStorage) GetPendingACKs() ([]models.PendingACK, error) {
+	args := m.Called()
+	return args.Get(0).([]models.PendingACK), args.Error(1)
+}
+
 // Mock for the HTTP Client
 type MockHTTPClient struct {
 	mock.Mock
@@ -46,34 +83,159 @@ func setupTestService() (*ACKServiceImpl, *MockACKStorage, *MockHTTPClient) {
 	}
 
 	service := &ACKServiceImpl{
-		storage:    mockStorage,
-		httpClient: httpClient,
+		storage: mockStorage,
+		alarms: client.NewAlarmClient(client.Config{
+			BaseURL:    "http://alarm-service",
+			HTTPClient: httpClient,
+			MaxRetries: -1,
+		}),
 	}
 
 	return service, mockStorage, mockHTTPClient
 }
 
+// jsonResponse builds a response with the given status code and body
+func jsonResponse(statusCode int, body string) *http.Response {
+	return &http.Response{
+		StatusCode: statusCode,
+		Body:       io.NopCloser(bytes.NewBufferString(body)),
+	}
+}
+
+// expectRequest sets up a single alarm-service call, status is the one sent in a PUT body
+func expectRequest(mockHTTPClient *MockHTTPClient, method, status string, resp *http.Response) {
+	mockHTTPClient.On("RoundTrip", mock.MatchedBy(func(req *http.Request) bool {
+		if req.Method != method {
+			return false
+		}
+		if method != http.MethodPut {
+			return true
+		}
+		body, _ := io.ReadAll(req.Body)
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		return bytes.Contains(body, []byte(`"status":"`+status+`"`))
+	})).Return(resp, nil).Once()
+}
+
 func TestACKAlarm_Success(t *testing.T) {
 	service, mockStorage, mockHTTPClient := setupTestService()
 
 	alarmID := "test-alarm-id"
-	mockStorage.On("ACKAlarm", alarmID).Return(nil)
+	mockStorage.On("MarkPendingACK", alarmID, "active").Return(nil).Once()
+	mockStorage.On("ACKAlarm", alarmID).Return(nil).Once()
 
-	// Create a mock response for the HTTP request
-	mockResponse := &http.Response{
-		StatusCode: http.StatusOK,
-		Body:       io.NopCloser(bytes.NewBufferString(`{"status":"acknowledged"}`)),
-	}
-
-	mockHTTPClient.On("RoundTrip", mock.Anything).Return(mockResponse, nil)
+	expectRequest(mockHTTPClient, http.MethodGet, "", jsonResponse(http.StatusOK, `{"status":"active"}`))
+	expectRequest(mockHTTPClient, http.MethodPut, "ACK", jsonResponse(http.StatusOK, `{"status":"ACK"}`))
 
 	// Call the method under test
-	err := service.ACKAlarm(alarmID)
+	err := service.ACKAlarm(context.Background(), alarmID)
 
 	// Assertions
 	assert.NoError(t, err)
-	mockStorage.AssertCalled(t, "ACKAlarm", alarmID)
-	mockHTTPClient.AssertCalled(t, "RoundTrip", mock.Anything)
+	mockStorage.AssertExpectations(t)
+	mockHTTPClient.AssertExpectations(t)
+}
+
+func TestACKAlarm_AlarmNotFound(t *testing.T) {
+	service, mockStorage, mockHTTPClient := setupTestService()
+
+	expectRequest(mockHTTPClient, http.MethodGet, "", jsonResponse(http.StatusNotFound, `{"error":"not found"}`))
+
+	err := service.ACKAlarm(context.Background(), "missing-alarm")
+
+	assert.Error(t, err)
+	mockStorage.AssertNotCalled(t, "MarkPendingACK", mock.Anything, mock.Anything)
+	mockStorage.AssertNotCalled(t, "ACKAlarm", mock.Anything)
+}
+
+func TestACKAlarm_UpdateFails_ClearsPending(t *testing.T) {
+	service, mockStorage, mockHTTPClient := setupTestService()
+
+	alarmID := "test-alarm-id"
+	mockStorage.On("MarkPendingACK", alarmID, "active").Return(nil).Once()
+	mockStorage.On("ClearPendingACK", alarmID).Return(nil).Once()
+
+	expectRequest(mockHTTPClient, http.MethodGet, "", jsonResponse(http.StatusOK, `{"status":"active"}`))
+	expectRequest(mockHTTPClient, http.MethodPut, "ACK", jsonResponse(http.StatusInternalServerError, `{}`))
+
+	err := service.ACKAlarm(context.Background(), alarmID)
+
+	assert.Error(t, err)
+	mockStorage.AssertExpectations(t)
+	mockStorage.AssertNotCalled(t, "ACKAlarm", mock.Anything)
+}
+
+func TestACKAlarm_StoreFails_RestoresStatus(t *testing.T) {
+	service, mockStorage, mockHTTPClient := setupTestService()
+
+	alarmID := "test-alarm-id"
+	mockStorage.On("MarkPendingACK", alarmID, "triggered").Return(nil).Once()
+	mockStorage.On("ACKAlarm", alarmID).Return(errors.New("disk full")).Once()
+	mockStorage.On("ClearPendingACK", alarmID).Return(nil).Once()
+
+	expectRequest(mockHTTPClient, http.MethodGet, "", jsonResponse(http.StatusOK, `{"status":"triggered"}`))
+	expectRequest(mockHTTPClient, http.MethodPut, "ACK", jsonResponse(http.StatusOK, `{"status":"ACK"}`))
+	expectRequest(mockHTTPClient, http.MethodPut, "triggered", jsonResponse(http.StatusOK, `{"status":"triggered"}`))
+
+	err := service.ACKAlarm(context.Background(), alarmID)
+
+	assert.Error(t, err)
+	mockStorage.AssertExpectations(t)
+	mockHTTPClient.AssertExpectations(t)
+}
+
+// TestACKAlarm_StoreFails_RestoresStatusInAlarmService runs the compensation
+// against the alarm-service API, whose state machine must accept the rollback
+func TestACKAlarm_StoreFails_RestoresStatusInAlarmService(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("INTERNAL_TOKEN", "internal-secret")
+	store := alarmstorage.NewMemoryStorage()
+	router := gin.New()
+	handlers.RegisterRoutes(router, handlers.NewAlarmHandler(alarmservice.NewAlarmService(store, events.NewRelay(store))))
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	for _, status := range []string{"triggered", "active"} {
+		alarm := models.Alarm{ID: uuid.New(), Name: "Disk full", Status: status, CreatedAt: time.Now()}
+		require.NoError(t, store.SaveAlarm(alarm))
+		alarmID := alarm.ID.String()
+
+		mockStorage := new(MockACKStorage)
+		mockStorage.On("MarkPendingACK", alarmID, status).Return(nil).Once()
+		mockStorage.On("ACKAlarm", alarmID).Return(errors.New("disk full")).Once()
+		mockStorage.On("ClearPendingACK", alarmID).Return(nil).Once()
+		service := &ACKServiceImpl{
+			storage: mockStorage,
+			alarms:  client.NewAlarmClient(client.Config{BaseURL: server.URL, MaxRetries: -1, ServiceName: "ack-service", InternalToken: "internal-secret"}),
+		}
+
+		err := service.ACKAlarm(context.Background(), alarmID)
+		assert.Error(t, err)
+		mockStorage.AssertExpectations(t)
+
+		restored, err := store.GetAlarm(alarm.ID)
+		require.NoError(t, err)
+		assert.Equal(t, status, restored.Status, "the alarm is put back to %s", status)
+	}
+}
+
+func TestACKAlarm_CompensationFails_KeepsPending(t *testing.T) {
+	service, mockStorage, mockHTTPClient := setupTestService()
+
+	alarmID := "test-alarm-id"
+	mockStorage.On("MarkPendingACK", alarmID, "active").Return(nil).Once()
+	mockStorage.On("ACKAlarm", alarmID).Return(errors.New("disk full")).Once()
+
+	expectRequest(mockHTTPClient, http.MethodGet, "", jsonResponse(http.StatusOK, `{"status":"active"}`))
+	expectRequest(mockHTTPClient, http.MethodPut, "ACK", jsonResponse(http.StatusOK, `{"status":"ACK"}`))
+	expectRequest(mockHTTPClient, http.MethodPut, "active", jsonResponse(http.StatusServiceUnavailable, `{}`))
+
+	err := service.ACKAlarm(context.Background(), alarmID)
+
+	assert.Error(t, err)
+	mockHTTPClient.AssertExpectations(t)
+	// The pending mark is left for the reconciler
+	mockStorage.AssertNotCalled(t, "ClearPendingACK", alarmID)
 }
 
 func TestGetACKState(t *testing.T) {
@@ -3,6 +3,13 @@ package storage
 import "github.com/26christy/CarbonQuest/common/models"
 
 type ACKStorage interface {
+	// ACKAlarm records the acknowledgement and settles any pending mark for the alarm
 	ACKAlarm(alarmID string) error
 	GetACKState(alarmID string) (models.ACKState, bool)
+	GetAllACKStates() ([]models.ACKState, error)
+	DeleteACKState(alarmID string) error
+
+	MarkPendingACK(alarmID, previousStatus string) error
+	ClearPendingACK(alarmID string) error
+	GetPendingACKs() ([]models.PendingACK, error)
 }
@@ -13,32 +13,24 @@ import (
 type memoryStorage struct {
 	mu      sync.RWMutex
 	ackData map[string]models.ACKState
+	pending map[string]models.PendingACK
 }
 
 func NewMemoryStorage() ACKStorage {
 	return &memoryStorage{
 		ackData: make(map[string]models.ACKState),
+		pending: make(map[string]models.PendingACK),
 	}
 }
 
 func (s *memoryStorage) ACKAlarm(alarmID string) error {
-	ackDurationStr := os.Getenv("ACK_DURATION")
-	ackDurationInt, err := strconv.Atoi(ackDurationStr)
-	if err != nil {
-		fmt.Printf("Error parsing ACK_DURATION: %v", err)
-		ackDurationInt = 1440 // default is 24 hours
-	}
-	ackDuration := time.Duration(ackDurationInt) * time.Minute
+	ackState := newACKState(alarmID)
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	ackState := models.ACKState{
-		AlarmID:            alarmID,
-		ACKedAt:            time.Now(),
-		NextNotificationAt: time.Now().Add(ackDuration),
-	}
-
 	s.ackData[alarmID] = ackState
+	delete(s.pending, alarmID)
 	return nil
 }
 
@@ -49,3 +41,72 @@ func (s *memoryStorage) GetACKState(alarmID string) (models.ACKState, bool) {
 	ackState, exists := s.ackData[alarmID]
 	return ackState, exists
 }
+
+func (s *memoryStorage) GetAllACKStates() ([]models.ACKState, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ackStates := make([]models.ACKState, 0, len(s.ackData))
+	for _, ackState := range s.ackData {
+		ackStates = append(ackStates, ackState)
+	}
+	return ackStates, nil
+}
+
+func (s *memoryStorage) DeleteACKState(alarmID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.ackData, alarmID)
+	return nil
+}
+
+func (s *memoryStorage) MarkPendingACK(alarmID, previousStatus string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pending[alarmID] = models.PendingACK{
+		AlarmID:        alarmID,
+		PreviousStatus: previousStatus,
+		MarkedAt:       time.Now(),
+	}
+	return nil
+}
+
+func (s *memoryStorage) ClearPendingACK(alarmID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.pending, alarmID)
+	return nil
+}
+
+func (s *memoryStorage) GetPendingACKs() ([]models.PendingACK, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	pending := make([]models.PendingACK, 0, len(s.pending))
+	for _, p := range s.pending {
+		pending = append(pending, p)
+	}
+	return pending, nil
+}
+
+// newACKState builds the state for an alarm acknowledged now,
+// the next notification is deferred by ACK_DURATION minutes
+func newACKState(alarmID string) models.ACKState {
+	ackDurationStr := os.Getenv("ACK_DURATION")
+	ackDurationInt, err := strconv.Atoi(ackDurationStr)
+	if err != nil {
+		fmt.Printf("Error parsing ACK_DURATION: %v", err)
+		ackDurationInt = 1440 // default is 24 hours
+	}
+	ackDuration := time.Duration(ackDurationInt) * time.Minute
+
+	now := time.Now()
+	return models.ACKState{
+		AlarmID:            alarmID,
+		ACKedAt:            now,
+		NextNotificationAt: now.Add(ackDuration),
+	}
+}
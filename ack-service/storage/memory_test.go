@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNewMemoryStorage(t *testing.T) {
@@ -38,3 +39,57 @@ func TestGetACKState_NotExists(t *testing.T) {
 	_, exists := storage.GetACKState(alarmID)
 	assert.False(t, exists, "GetACKState should return false for non-existent alarm")
 }
+
+func TestPendingACK(t *testing.T) {
+	testPendingACK(t, NewMemoryStorage())
+}
+
+func TestGetAllACKStates(t *testing.T) {
+	testGetAllACKStates(t, NewMemoryStorage())
+}
+
+// testPendingACK checks the mark then confirm flow, shared by every storage backend
+func testPendingACK(t *testing.T, storage ACKStorage) {
+	require.NoError(t, storage.MarkPendingACK("confirmed-alarm", "active"))
+	require.NoError(t, storage.MarkPendingACK("rolled-back-alarm", "triggered"))
+
+	pending, err := storage.GetPendingACKs()
+	require.NoError(t, err)
+	require.Len(t, pending, 2)
+	for _, p := range pending {
+		assert.WithinDuration(t, time.Now(), p.MarkedAt, time.Second)
+		if p.AlarmID == "rolled-back-alarm" {
+			assert.Equal(t, "triggered", p.PreviousStatus)
+		}
+	}
+
+	// Confirming the ACK settles the pending mark
+	require.NoError(t, storage.ACKAlarm("confirmed-alarm"))
+	require.NoError(t, storage.ClearPendingACK("rolled-back-alarm"))
+
+	pending, err = storage.GetPendingACKs()
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+
+	_, exists := storage.GetACKState("confirmed-alarm")
+	assert.True(t, exists)
+	_, exists = storage.GetACKState("rolled-back-alarm")
+	assert.False(t, exists, "a cleared mark must not leave an ACK state behind")
+}
+
+// testGetAllACKStates checks listing and deleting ACK states, shared by every storage backend
+func testGetAllACKStates(t *testing.T, storage ACKStorage) {
+	require.NoError(t, storage.ACKAlarm("alarm-1"))
+	require.NoError(t, storage.ACKAlarm("alarm-2"))
+
+	ackStates, err := storage.GetAllACKStates()
+	require.NoError(t, err)
+	assert.Len(t, ackStates, 2)
+
+	require.NoError(t, storage.DeleteACKState("alarm-1"))
+
+	ackStates, err = storage.GetAllACKStates()
+	require.NoError(t, err)
+	require.Len(t, ackStates, 1)
+	assert.Equal(t, "alarm-2", ackStates[0].AlarmID)
+}
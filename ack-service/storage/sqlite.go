@@ -0,0 +1,165 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/26christy/CarbonQuest/common/database"
+	"github.com/26christy/CarbonQuest/common/models"
+)
+
+// migrations for the ACK schema, never edit an existing entry, always append
+var migrations = []string{
+	`CREATE TABLE IF NOT EXISTS ack_states (
+		alarm_id             TEXT PRIMARY KEY,
+		acked_at             TEXT NOT NULL,
+		next_notification_at TEXT NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS pending_acks (
+		alarm_id        TEXT PRIMARY KEY,
+		previous_status TEXT NOT NULL,
+		marked_at       TEXT NOT NULL
+	)`,
+}
+
+// sqliteStorage keeps ACK states in a database file so that
+// acknowledgements survive a restart of the ACK service
+type sqliteStorage struct {
+	db *sql.DB
+}
+
+// NewSQLiteStorage opens the database at path. Any acknowledgement written
+// before a restart is recovered from the file and served straight away.
+func NewSQLiteStorage(path string) (ACKStorage, error) {
+	db, err := database.OpenSQLite(path, migrations)
+	if err != nil {
+		return nil, err
+	}
+	return &sqliteStorage{db: db}, nil
+}
+
+// Close releases the underlying database handle
+func (s *sqliteStorage) Close() error {
+	return s.db.Close()
+}
+
+func (s *sqliteStorage) ACKAlarm(alarmID string) error {
+	ackState := newACKState(alarmID)
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(
+		`INSERT OR REPLACE INTO ack_states (alarm_id, acked_at, next_notification_at) VALUES (?, ?, ?)`,
+		ackState.AlarmID,
+		database.FormatTime(ackState.ACKedAt),
+		database.FormatTime(ackState.NextNotificationAt),
+	)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM pending_acks WHERE alarm_id = ?`, alarmID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *sqliteStorage) GetACKState(alarmID string) (models.ACKState, bool) {
+	row := s.db.QueryRow(
+		`SELECT alarm_id, acked_at, next_notification_at FROM ack_states WHERE alarm_id = ?`,
+		alarmID,
+	)
+	ackState, err := scanACKState(row)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			fmt.Printf("Error reading ACK state for %s: %v\n", alarmID, err)
+		}
+		return models.ACKState{}, false
+	}
+	return ackState, true
+}
+
+func (s *sqliteStorage) GetAllACKStates() ([]models.ACKState, error) {
+	rows, err := s.db.Query(`SELECT alarm_id, acked_at, next_notification_at FROM ack_states`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ackStates []models.ACKState
+	for rows.Next() {
+		ackState, err := scanACKState(rows)
+		if err != nil {
+			return nil, err
+		}
+		ackStates = append(ackStates, ackState)
+	}
+	return ackStates, rows.Err()
+}
+
+func (s *sqliteStorage) DeleteACKState(alarmID string) error {
+	_, err := s.db.Exec(`DELETE FROM ack_states WHERE alarm_id = ?`, alarmID)
+	return err
+}
+
+func (s *sqliteStorage) MarkPendingACK(alarmID, previousStatus string) error {
+	_, err := s.db.Exec(
+		`INSERT OR REPLACE INTO pending_acks (alarm_id, previous_status, marked_at) VALUES (?, ?, ?)`,
+		alarmID, previousStatus, database.FormatTime(time.Now()),
+	)
+	return err
+}
+
+func (s *sqliteStorage) ClearPendingACK(alarmID string) error {
+	_, err := s.db.Exec(`DELETE FROM pending_acks WHERE alarm_id = ?`, alarmID)
+	return err
+}
+
+func (s *sqliteStorage) GetPendingACKs() ([]models.PendingACK, error) {
+	rows, err := s.db.Query(`SELECT alarm_id, previous_status, marked_at FROM pending_acks`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var pending []models.PendingACK
+	for rows.Next() {
+		var p models.PendingACK
+		var markedAt string
+		if err := rows.Scan(&p.AlarmID, &p.PreviousStatus, &markedAt); err != nil {
+			return nil, err
+		}
+		if p.MarkedAt, err = database.ParseTime(markedAt); err != nil {
+			return nil, err
+		}
+		pending = append(pending, p)
+	}
+	return pending, rows.Err()
+}
+
+// scanner is satisfied by both *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanACKState(row scanner) (models.ACKState, error) {
+	var ackState models.ACKState
+	var ackedAt, nextNotificationAt string
+	if err := row.Scan(&ackState.AlarmID, &ackedAt, &nextNotificationAt); err != nil {
+		return models.ACKState{}, err
+	}
+
+	var err error
+	if ackState.ACKedAt, err = database.ParseTime(ackedAt); err != nil {
+		return models.ACKState{}, fmt.Errorf("parsing acked_at: %w", err)
+	}
+	if ackState.NextNotificationAt, err = database.ParseTime(nextNotificationAt); err != nil {
+		return models.ACKState{}, fmt.Errorf("parsing next_notification_at: %w", err)
+	}
+	return ackState, nil
+}
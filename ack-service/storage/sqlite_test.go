@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLiteACKAlarm(t *testing.T) {
+	storage, err := NewSQLiteStorage(filepath.Join(t.TempDir(), "ack.db"))
+	require.NoError(t, err)
+	defer storage.(io.Closer).Close()
+
+	alarmID := "test-alarm"
+	err = storage.ACKAlarm(alarmID)
+	assert.NoError(t, err, "ACKAlarm should not return an error")
+
+	ackState, exists := storage.GetACKState(alarmID)
+	assert.True(t, exists, "ACK state should exist after acknowledgment")
+	assert.Equal(t, alarmID, ackState.AlarmID)
+	assert.WithinDuration(t, time.Now(), ackState.ACKedAt, time.Second)
+
+	expectedNextNotification := ackState.ACKedAt.Add(24 * time.Hour)
+	assert.WithinDuration(t, expectedNextNotification, ackState.NextNotificationAt, time.Second)
+
+	_, exists = storage.GetACKState("non-existent-alarm")
+	assert.False(t, exists, "GetACKState should return false for non-existent alarm")
+}
+
+func TestSQLiteACKAlarm_SurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ack.db")
+
+	storage, err := NewSQLiteStorage(path)
+	require.NoError(t, err)
+	require.NoError(t, storage.ACKAlarm("test-alarm"))
+	before, _ := storage.GetACKState("test-alarm")
+	require.NoError(t, storage.(io.Closer).Close())
+
+	recovered, err := NewSQLiteStorage(path)
+	require.NoError(t, err)
+	defer recovered.(io.Closer).Close()
+
+	after, exists := recovered.GetACKState("test-alarm")
+	assert.True(t, exists, "ACK state should be recovered after restart")
+	assert.True(t, before.ACKedAt.Equal(after.ACKedAt))
+	assert.True(t, before.NextNotificationAt.Equal(after.NextNotificationAt))
+}
+
+func TestSQLitePendingACK(t *testing.T) {
+	storage, err := NewSQLiteStorage(filepath.Join(t.TempDir(), "ack.db"))
+	require.NoError(t, err)
+	defer storage.(io.Closer).Close()
+
+	testPendingACK(t, storage)
+}
+
+func TestSQLiteGetAllACKStates(t *testing.T) {
+	storage, err := NewSQLiteStorage(filepath.Join(t.TempDir(), "ack.db"))
+	require.NoError(t, err)
+	defer storage.(io.Closer).Close()
+
+	testGetAllACKStates(t, storage)
+}
+
+func TestCreateStorage(t *testing.T) {
+	storage, err := CreateStorage("memory", "")
+	assert.NoError(t, err)
+	assert.NotNil(t, storage)
+
+	_, err = CreateStorage("sqlite", "")
+	assert.Error(t, err, "sqlite requires a database path")
+
+	_, err = CreateStorage("unknown", "")
+	assert.Error(t, err)
+}
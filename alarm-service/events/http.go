@@ -0,0 +1,42 @@
+package events
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/26christy/CarbonQuest/common/models"
+)
+
+// NewHTTPSubscriber returns a Handler that POSTs each event as JSON to url.
+// Any 2xx response acknowledges the event, everything else is retried.
+// The event ID is sent as X-Event-ID and as Idempotency-Key so receivers
+// can drop the duplicates that at-least-once delivery produces.
+func NewHTTPSubscriber(url string, client *http.Client) Handler {
+	return func(event models.AlarmHistoryEntry) error {
+		payload, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+
+		req, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(payload))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Event-ID", event.ID.String())
+		req.Header.Set("Idempotency-Key", event.ID.String())
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return fmt.Errorf("subscriber %s responded with status code: %d", url, resp.StatusCode)
+		}
+		return nil
+	}
+}
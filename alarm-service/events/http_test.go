@@ -0,0 +1,31 @@
+package events
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/26christy/CarbonQuest/common/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTTPSubscriber(t *testing.T) {
+	var gotEventID, gotIdempotencyKey string
+	status := http.StatusAccepted
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotEventID = r.Header.Get("X-Event-ID")
+		gotIdempotencyKey = r.Header.Get("Idempotency-Key")
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	handler := NewHTTPSubscriber(server.URL, server.Client())
+	event := newTestEvent(models.AlarmCreated)
+
+	assert.NoError(t, handler(event), "any 2xx acknowledges the event")
+	assert.Equal(t, event.ID.String(), gotEventID)
+	assert.Equal(t, event.ID.String(), gotIdempotencyKey)
+
+	status = http.StatusServiceUnavailable
+	assert.Error(t, handler(event))
+}
@@ -0,0 +1,17 @@
+package events
+
+import "github.com/26christy/CarbonQuest/common/models"
+
+// Handler consumes a single alarm change event. Returning an error
+// asks the publisher to deliver the same event again later.
+type Handler func(event models.AlarmHistoryEntry) error
+
+// EventPublisher fans alarm change events out to subscribers.
+// Delivery is at-least-once, so handlers must be idempotent,
+// the event ID is stable across redeliveries.
+type EventPublisher interface {
+	Publish(event models.AlarmHistoryEntry)
+	// Subscribe registers a handler for one action, or every action when action is "*".
+	// The name identifies the subscriber, it must be stable across restarts.
+	Subscribe(name, action string, handler Handler)
+}
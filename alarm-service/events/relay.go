@@ -0,0 +1,242 @@
+package events
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/26christy/CarbonQuest/common/models"
+)
+
+// Retry backoff for failed deliveries, doubled after every attempt
+const (
+	initialRetryDelay = 500 * time.Millisecond
+	maxRetryDelay     = 30 * time.Second
+	pollInterval      = 5 * time.Second
+	batchSize         = 100
+)
+
+// OutboxStore is the part of the alarm storage the relay drains
+type OutboxStore interface {
+	GetOutboxAfter(seq int64, limit int) ([]models.OutboxEntry, error)
+	PruneOutbox(seq int64) error
+	GetOutboxCursor(subscriber string) (models.OutboxCursor, error)
+	SaveOutboxCursor(cursor models.OutboxCursor) error
+	DeadLetterOutbox(deadLetter models.OutboxDeadLetter, cursor models.OutboxCursor) error
+}
+
+type subscription struct {
+	name    string
+	action  string
+	handler Handler
+}
+
+// Relay is an EventPublisher backed by the transactional outbox. Events are
+// written to the outbox together with the alarm change, and every subscriber
+// reads the outbox from its own cursor, which is stored with the outbox, so an
+// event survives a crash or a subscriber outage. Each subscriber receives events
+// in outbox order, a failed event holds back later ones for that subscriber only.
+// An event still failing after maxAttempts is dead-lettered for that subscriber,
+// and events every subscriber has passed are deleted.
+type Relay struct {
+	store OutboxStore
+
+	mu            sync.RWMutex
+	subscriptions []*subscription
+
+	wake        chan struct{}
+	done        chan struct{}
+	wg          sync.WaitGroup
+	retryDelay  time.Duration
+	interval    time.Duration
+	maxAttempts int
+}
+
+// NewRelay initializes a relay for the outbox in store
+func NewRelay(store OutboxStore) *Relay {
+	return &Relay{
+		store:       store,
+		wake:        make(chan struct{}, 1),
+		done:        make(chan struct{}),
+		retryDelay:  initialRetryDelay,
+		interval:    pollInterval,
+		maxAttempts: eventMaxAttempts(),
+	}
+}
+
+// Subscribe registers a handler for one action, or every action when action is "*".
+// The name keeps the position of the subscriber in the outbox across restarts,
+// a second subscription with the same name is ignored.
+func (r *Relay) Subscribe(name, action string, handler Handler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, sub := range r.subscriptions {
+		if sub.name == name {
+			fmt.Printf("[Relay] Ignoring a second subscription for %s\n", name)
+			return
+		}
+	}
+	r.subscriptions = append(r.subscriptions, &subscription{name: name, action: action, handler: handler})
+}
+
+// Publish wakes the relay. The event itself is already in the outbox.
+func (r *Relay) Publish(event models.AlarmHistoryEntry) {
+	select {
+	case r.wake <- struct{}{}:
+	default:
+		// a wake up is already pending
+	}
+}
+
+// Start runs the relay worker until Close is called
+func (r *Relay) Start() {
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		for {
+			r.drain(time.Now())
+			select {
+			case <-r.wake:
+			case <-ticker.C:
+			case <-r.done:
+				return
+			}
+		}
+	}()
+}
+
+// Close stops the relay worker, undelivered events stay in the outbox
+func (r *Relay) Close() error {
+	close(r.done)
+	r.wg.Wait()
+	return nil
+}
+
+// drain delivers the outbox to every subscriber from its own cursor, then
+// deletes the events that all of them have passed
+func (r *Relay) drain(now time.Time) {
+	r.mu.RLock()
+	subscriptions := r.subscriptions
+	r.mu.RUnlock()
+
+	var passed int64 = -1
+	for _, sub := range subscriptions {
+		cursor, err := r.deliver(sub, now)
+		if err != nil {
+			fmt.Printf("[Relay] Failed to deliver the outbox to %s: %v\n", sub.name, err)
+			return
+		}
+		if passed < 0 || cursor.Seq < passed {
+			passed = cursor.Seq
+		}
+	}
+
+	if passed < 0 {
+		// Without subscribers nobody is waiting for the events
+		entries, err := r.store.GetOutboxAfter(0, batchSize)
+		if err != nil || len(entries) == 0 {
+			return
+		}
+		passed = entries[len(entries)-1].Seq
+	}
+	if err := r.store.PruneOutbox(passed); err != nil {
+		fmt.Printf("[Relay] Failed to prune the outbox: %v\n", err)
+	}
+}
+
+// deliver sends the subscriber the events after its cursor until it is caught
+// up or an event fails, and returns the cursor it saved
+func (r *Relay) deliver(sub *subscription, now time.Time) (models.OutboxCursor, error) {
+	cursor, err := r.store.GetOutboxCursor(sub.name)
+	if err != nil {
+		return cursor, err
+	}
+	if cursor.NextAttemptAt.After(now) {
+		// The retry of the failed event is not due yet
+		return cursor, nil
+	}
+
+	for {
+		entries, err := r.store.GetOutboxAfter(cursor.Seq, batchSize)
+		if err != nil {
+			return cursor, err
+		}
+		if len(entries) == 0 {
+			return cursor, nil
+		}
+
+		start := cursor.Seq
+		for _, entry := range entries {
+			event := entry.Event
+			if sub.action != "*" && sub.action != event.Action {
+				cursor.Seq = entry.Seq
+				continue
+			}
+
+			err := sub.handler(event)
+			if err == nil {
+				cursor = models.OutboxCursor{Subscriber: sub.name, Seq: entry.Seq}
+				continue
+			}
+
+			attempts := cursor.Attempts + 1
+			if attempts < r.maxAttempts {
+				fmt.Printf("[Relay] Delivery of event %s to %s failed (attempt %d): %v\n", event.ID, sub.name, attempts, err)
+				cursor.Attempts = attempts
+				cursor.NextAttemptAt = now.Add(r.backoff(attempts))
+				cursor.LastError = err.Error()
+				return cursor, r.store.SaveOutboxCursor(cursor)
+			}
+
+			// Give up on the event so the subscriber gets the ones after it
+			fmt.Printf("[Relay] Dead-lettering event %s for %s after %d attempts: %v\n", event.ID, sub.name, attempts, err)
+			cursor = models.OutboxCursor{Subscriber: sub.name, Seq: entry.Seq}
+			deadLetter := models.OutboxDeadLetter{
+				Subscriber: sub.name,
+				Event:      event,
+				Attempts:   attempts,
+				LastError:  err.Error(),
+				FailedAt:   now,
+			}
+			if err := r.store.DeadLetterOutbox(deadLetter, cursor); err != nil {
+				return cursor, err
+			}
+		}
+
+		if cursor.Seq != start {
+			if err := r.store.SaveOutboxCursor(cursor); err != nil {
+				return cursor, err
+			}
+		}
+		if len(entries) < batchSize {
+			return cursor, nil
+		}
+	}
+}
+
+// backoff returns the delay before the given attempt, doubling up to maxRetryDelay
+func (r *Relay) backoff(attempt int) time.Duration {
+	delay := r.retryDelay
+	for i := 1; i < attempt && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxRetryDelay {
+		delay = maxRetryDelay
+	}
+	return delay
+}
+
+// eventMaxAttempts reads EVENT_MAX_ATTEMPTS, the attempts to deliver one event to
+// a subscriber before it is dead-lettered for that subscriber
+func eventMaxAttempts() int {
+	attempts, err := strconv.Atoi(os.Getenv("EVENT_MAX_ATTEMPTS"))
+	if err != nil || attempts <= 0 {
+		attempts = 100 // default is 100 attempts, close to 50 minutes with the backoff
+	}
+	return attempts
+}
@@ -0,0 +1,223 @@
+package events
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/26christy/CarbonQuest/alarm-service/storage"
+	"github.com/26christy/CarbonQuest/common/models"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestEvent(action string) models.AlarmHistoryEntry {
+	return models.AlarmHistoryEntry{
+		ID:        uuid.New(),
+		AlarmID:   uuid.New(),
+		Action:    action,
+		CreatedAt: time.Now(),
+	}
+}
+
+// recorder is a subscriber that remembers what it received and can be made to fail
+type recorder struct {
+	received []uuid.UUID
+	fail     bool
+}
+
+func (r *recorder) handle(event models.AlarmHistoryEntry) error {
+	if r.fail {
+		return errors.New("subscriber unavailable")
+	}
+	r.received = append(r.received, event.ID)
+	return nil
+}
+
+func TestRelay_DeliversAndPrunes(t *testing.T) {
+	store := storage.NewMemoryStorage()
+	relay := NewRelay(store)
+
+	all, deletes := &recorder{}, &recorder{}
+	relay.Subscribe("all", "*", all.handle)
+	relay.Subscribe("deletes", models.AlarmDeleted, deletes.handle)
+
+	created, deleted := newTestEvent(models.AlarmCreated), newTestEvent(models.AlarmDeleted)
+	store.EnqueueOutbox(created)
+	store.EnqueueOutbox(deleted)
+
+	relay.drain(time.Now())
+
+	assert.Equal(t, []uuid.UUID{created.ID, deleted.ID}, all.received, "events should arrive in outbox order")
+	assert.Equal(t, []uuid.UUID{deleted.ID}, deletes.received)
+
+	pending, _ := store.GetOutboxAfter(0, 10)
+	assert.Empty(t, pending, "events every subscriber has passed should leave the outbox")
+}
+
+func TestRelay_RetriesFailedSubscriberInOrder(t *testing.T) {
+	store := storage.NewMemoryStorage()
+	relay := NewRelay(store)
+
+	healthy, flaky := &recorder{}, &recorder{fail: true}
+	relay.Subscribe("healthy", "*", healthy.handle)
+	relay.Subscribe("flaky", "*", flaky.handle)
+
+	first, second := newTestEvent(models.AlarmCreated), newTestEvent(models.AlarmUpdated)
+	store.EnqueueOutbox(first)
+	store.EnqueueOutbox(second)
+
+	now := time.Now()
+	relay.drain(now)
+
+	// The healthy subscriber is not held back by the flaky one
+	assert.Equal(t, []uuid.UUID{first.ID, second.ID}, healthy.received)
+	assert.Empty(t, flaky.received)
+
+	pending, _ := store.GetOutboxAfter(0, 10)
+	assert.Len(t, pending, 2, "events stay in the outbox until every subscriber has them")
+	cursor, _ := store.GetOutboxCursor("flaky")
+	assert.Equal(t, 1, cursor.Attempts)
+	assert.Equal(t, "subscriber unavailable", cursor.LastError)
+	assert.WithinDuration(t, now.Add(initialRetryDelay), cursor.NextAttemptAt, time.Millisecond)
+
+	// Before the retry is due nothing is sent, not even the newer event
+	flaky.fail = false
+	relay.drain(now)
+	assert.Empty(t, flaky.received, "later events must wait for the failed one")
+
+	// Once due, the flaky subscriber catches up in order and the healthy one is not sent duplicates
+	relay.drain(now.Add(time.Minute))
+	assert.Equal(t, []uuid.UUID{first.ID, second.ID}, flaky.received)
+	assert.Equal(t, []uuid.UUID{first.ID, second.ID}, healthy.received)
+
+	pending, _ = store.GetOutboxAfter(0, 10)
+	assert.Empty(t, pending)
+}
+
+func TestRelay_SubscriberDownDoesNotHoldBackOthers(t *testing.T) {
+	store := storage.NewMemoryStorage()
+	relay := NewRelay(store)
+
+	healthy, down := &recorder{}, &recorder{fail: true}
+	relay.Subscribe("healthy", "*", healthy.handle)
+	relay.Subscribe("down", "*", down.handle)
+
+	// Far more events than one batch arrive while a subscriber is down
+	now := time.Now()
+	var sent []uuid.UUID
+	for i := range 3 * batchSize {
+		event := newTestEvent(models.AlarmUpdated)
+		store.EnqueueOutbox(event)
+		sent = append(sent, event.ID)
+		if i%batchSize == 0 {
+			relay.drain(now)
+			now = now.Add(time.Second)
+		}
+	}
+	relay.drain(now)
+
+	assert.Equal(t, sent, healthy.received, "the healthy subscriber gets every event")
+	assert.Empty(t, down.received)
+	pending, _ := store.GetOutboxAfter(0, 0)
+	assert.Len(t, pending, len(sent), "events are kept for the subscriber that is down")
+
+	// Back up, it catches up in order and the outbox is emptied
+	down.fail = false
+	relay.drain(now.Add(time.Hour))
+	assert.Equal(t, sent, down.received)
+	assert.Equal(t, sent, healthy.received)
+	pending, _ = store.GetOutboxAfter(0, 0)
+	assert.Empty(t, pending)
+}
+
+func TestRelay_DeadLettersAfterMaxAttempts(t *testing.T) {
+	store := storage.NewMemoryStorage()
+	relay := NewRelay(store)
+	relay.maxAttempts = 2
+
+	poisoned := newTestEvent(models.AlarmCreated)
+	next := newTestEvent(models.AlarmUpdated)
+	received := []uuid.UUID{}
+	relay.Subscribe("picky", "*", func(event models.AlarmHistoryEntry) error {
+		if event.ID == poisoned.ID {
+			return errors.New("cannot parse event")
+		}
+		received = append(received, event.ID)
+		return nil
+	})
+	store.EnqueueOutbox(poisoned)
+	store.EnqueueOutbox(next)
+
+	now := time.Now()
+	relay.drain(now)
+	assert.Empty(t, received)
+	relay.drain(now.Add(time.Minute))
+	assert.Equal(t, []uuid.UUID{next.ID}, received, "the next event is delivered once the failed one is dead-lettered")
+
+	deadLetters, _ := store.GetOutboxDeadLetters()
+	if assert.Len(t, deadLetters, 1) {
+		assert.Equal(t, "picky", deadLetters[0].Subscriber)
+		assert.Equal(t, poisoned.ID, deadLetters[0].Event.ID)
+		assert.Equal(t, 2, deadLetters[0].Attempts)
+		assert.Equal(t, "cannot parse event", deadLetters[0].LastError)
+	}
+	pending, _ := store.GetOutboxAfter(0, 10)
+	assert.Empty(t, pending)
+}
+
+func TestRelay_CursorSurvivesRestart(t *testing.T) {
+	store := storage.NewMemoryStorage()
+	delivered, down := &recorder{}, &recorder{fail: true}
+
+	relay := NewRelay(store)
+	relay.Subscribe("delivered", "*", delivered.handle)
+	relay.Subscribe("down", "*", down.handle)
+	event := newTestEvent(models.AlarmCreated)
+	store.EnqueueOutbox(event)
+	relay.drain(time.Now())
+
+	// A new relay on the same store does not send the event again to the subscriber that took it
+	restarted := NewRelay(store)
+	restarted.Subscribe("delivered", "*", delivered.handle)
+	restarted.Subscribe("down", "*", down.handle)
+	down.fail = false
+	restarted.drain(time.Now().Add(time.Minute))
+
+	assert.Equal(t, []uuid.UUID{event.ID}, delivered.received)
+	assert.Equal(t, []uuid.UUID{event.ID}, down.received)
+}
+
+func TestRelay_PublishWakesWorker(t *testing.T) {
+	store := storage.NewMemoryStorage()
+	relay := NewRelay(store)
+	relay.interval = time.Hour
+
+	delivered := make(chan uuid.UUID, 1)
+	relay.Subscribe("worker", "*", func(event models.AlarmHistoryEntry) error {
+		delivered <- event.ID
+		return nil
+	})
+	relay.Start()
+	defer relay.Close()
+
+	event := newTestEvent(models.AlarmCreated)
+	store.EnqueueOutbox(event)
+	relay.Publish(event)
+
+	select {
+	case id := <-delivered:
+		assert.Equal(t, event.ID, id)
+	case <-time.After(time.Second):
+		t.Fatal("Publish should wake the relay")
+	}
+}
+
+func TestRelay_Backoff(t *testing.T) {
+	relay := NewRelay(storage.NewMemoryStorage())
+
+	assert.Equal(t, initialRetryDelay, relay.backoff(1))
+	assert.Equal(t, 2*initialRetryDelay, relay.backoff(2))
+	assert.Equal(t, 4*initialRetryDelay, relay.backoff(3))
+	assert.Equal(t, maxRetryDelay, relay.backoff(50))
+}
@@ -1,8 +1,11 @@
 package handlers
 
 import (
+	"crypto/subtle"
+	"errors"
 	"fmt"
 	"net/http"
+	"os"
 	"time"
 
 	"github.com/26christy/CarbonQuest/alarm-service/service"
@@ -14,11 +17,14 @@ import (
 
 type AlarmHandler struct {
 	service service.AlarmServiceInterface
+	// internalToken is INTERNAL_TOKEN, the other services send it to be trusted as themselves
+	internalToken string
 }
 
 func NewAlarmHandler(service service.AlarmServiceInterface) *AlarmHandler {
 	return &AlarmHandler{
-		service: service,
+		service:       service,
+		internalToken: os.Getenv("INTERNAL_TOKEN"),
 	}
 }
 
@@ -42,19 +48,38 @@ func (h *AlarmHandler) createAlarm(c *gin.Context) {
 		return
 	}
 
+	// A new alarm is triggered, it only moves on through updates
+	if req.Status != "" && req.Status != "triggered" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "request body validation failed",
+			"details": "a new alarm has status triggered, not " + req.Status,
+		})
+		return
+	}
+	req.Status = "triggered"
+
 	req.ID = uuid.New()
 
 	// Ensure timestamps are set
 	req.CreatedAt = time.Now()
 	req.UpdatedAt = req.CreatedAt
 
-	// Set a default status if not provided
-	if req.Status == "" {
-		req.Status = "triggered" // Default status when an alarm is created
+	// Alarms without a priority are ranked by their severity
+	if req.Severity == "" {
+		req.Severity = models.DefaultSeverity
+	}
+	if req.Priority == 0 {
+		req.Priority = models.DefaultPriority(req.Severity)
 	}
 
+	// Repeats of an open alarm are counted on it instead of creating another one
+	req.Fingerprint = models.Fingerprint(req.Name, req.Labels)
+	req.Occurrences = 1
+	req.LastSeenAt = req.CreatedAt
+
 	// Call service to create alarm
-	if err := h.service.CreateAlarm(req); err != nil {
+	alarm, created, err := h.service.CreateAlarm(req, actorFromRequest(c))
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to create alarm",
 			"details": err.Error(),
@@ -62,7 +87,11 @@ func (h *AlarmHandler) createAlarm(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusCreated, req)
+	if !created {
+		c.JSON(http.StatusOK, alarm)
+		return
+	}
+	c.JSON(http.StatusCreated, alarm)
 }
 
 func (h *AlarmHandler) getAlarm(c *gin.Context) {
@@ -88,7 +117,12 @@ func (h *AlarmHandler) getAlarm(c *gin.Context) {
 }
 
 func (h *AlarmHandler) getAllAlarm(c *gin.Context) {
-	alarms, err := h.service.GetAllAlarm()
+	query, err := h.bindListQuery(c)
+	if err != nil {
+		return
+	}
+
+	page, err := h.service.ListAlarms(query)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "failed to fetch the alarms",
@@ -96,13 +130,26 @@ func (h *AlarmHandler) getAllAlarm(c *gin.Context) {
 		return
 	}
 
+	alarms := page.Alarms
 	if alarms == nil {
 		alarms = []models.Alarm{}
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	response := gin.H{
 		"alarms": alarms,
-	})
+	}
+	if page.Next != nil {
+		nextCursor, err := utils.EncodeCursor(page.Next)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "failed to build the next page cursor",
+				"details": err.Error()})
+			return
+		}
+		response["next_cursor"] = nextCursor
+	}
+
+	c.JSON(http.StatusOK, response)
 }
 
 func (h *AlarmHandler) deleteAlarm(c *gin.Context) {
@@ -116,7 +163,7 @@ func (h *AlarmHandler) deleteAlarm(c *gin.Context) {
 		return
 	}
 
-	err = h.service.DeleteAlarm(id)
+	err = h.service.DeleteAlarm(id, actorFromRequest(c))
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{
 			"error":   "failed to delete alarm for alarm ID: " + id.String(),
@@ -137,8 +184,7 @@ func (h *AlarmHandler) updateAlarm(c *gin.Context) {
 		return
 	}
 
-	existingAlarm, err := h.service.GetAlarm(id)
-	if err != nil {
+	if _, err := h.service.GetAlarm(id); err != nil {
 		c.JSON(http.StatusNotFound, gin.H{
 			"error":   fmt.Sprintf("failed to fetch the details for alarm ID: %s", id.String()),
 			"details": err.Error(),
@@ -146,17 +192,17 @@ func (h *AlarmHandler) updateAlarm(c *gin.Context) {
 		return
 	}
 
-	if !h.isValidStateTransition(existingAlarm.Status, req.Status) {
+	// Only the fields set in the request change, the service checks the status
+	// transition against the alarm as it is when the update runs
+	updatedAlarm, err := h.service.UpdateAlarm(id, req, actorFromRequest(c), h.isInternalCall(c, "ack-service"))
+	if errors.Is(err, service.ErrInvalidTransition) {
 		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "invalid state transition",
+			"error":   "invalid state transition",
+			"details": err.Error(),
 		})
 		return
 	}
-
-	// Fill missing fields with existing values
-	updatedAlarm := h.fillMissingFields(req, *existingAlarm)
-
-	if err := h.service.UpdateAlarm(updatedAlarm); err != nil {
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   fmt.Sprintf("failed to update the alarm for ID: %s", id.String()),
 			"details": err.Error(),
@@ -167,6 +213,106 @@ func (h *AlarmHandler) updateAlarm(c *gin.Context) {
 	c.JSON(http.StatusOK, updatedAlarm)
 }
 
+func (h *AlarmHandler) resolveAlarm(c *gin.Context) {
+	id, err := h.parseAlarmID(c)
+	if err != nil {
+		return
+	}
+
+	// The body is optional, an alarm resolved without one is fixed
+	var req models.ResolveAlarm
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "invalid request body",
+				"details": err.Error(),
+			})
+			return
+		}
+	}
+	if err := utils.ValidateStruct(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "request body validation failed",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if _, err := h.service.GetAlarm(id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "failed to fetch the details for alarm ID: " + id.String(),
+			"details": err.Error(),
+		})
+		return
+	}
+
+	alarm, err := h.service.ResolveAlarm(id, req, actorFromRequest(c))
+	if errors.Is(err, service.ErrAlarmNotOpen) {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "alarm ID: " + id.String() + " is already resolved or closed",
+			"details": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "failed to resolve the alarm for ID: " + id.String(),
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, alarm)
+}
+
+func (h *AlarmHandler) getAlarmHistory(c *gin.Context) {
+	id, err := h.parseAlarmID(c)
+	if err != nil {
+		return
+	}
+
+	history, err := h.service.GetAlarmHistory(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "failed to fetch the history for alarm ID: " + id.String(),
+			"details": err.Error(),
+		})
+		return
+	}
+
+	// History outlives the alarm, an alarm without any, such as one created
+	// before history was recorded, has an empty history as long as it exists
+	if len(history) == 0 {
+		if _, err := h.service.GetAlarm(id); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "failed to fetch the details for alarm ID: " + id.String(),
+				"details": err.Error(),
+			})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"history": history,
+	})
+}
+
+// actorFromRequest identifies who is making the change. Other services
+// set X-Source-Service to their own name, anything else is the public API.
+func actorFromRequest(c *gin.Context) models.Actor {
+	actor := models.Actor{
+		Name:   c.GetHeader("X-Actor"),
+		Source: c.GetHeader("X-Source-Service"),
+	}
+	if actor.Source == "" {
+		actor.Source = "api"
+	}
+	if actor.Name == "" {
+		actor.Name = "anonymous"
+	}
+	return actor
+}
+
 // Parses and validates the alarm ID from the request
 func (h *AlarmHandler) parseAlarmID(c *gin.Context) (uuid.UUID, error) {
 	idParam := c.Param("id")
@@ -201,47 +347,69 @@ func (h *AlarmHandler) bindAndValidateRequest(c *gin.Context, req *models.Update
 	return nil
 }
 
-// Checks if the state transition is valid
-func (h *AlarmHandler) isValidStateTransition(currentStatus, newState string) bool {
-	validTransitions := map[string][]string{
-		"triggered": {"active", "ACK"},
-		"active":    {"ACK"},
-		"ACK":       {"active"},
+// Binds and validates the list query string, including the page cursor
+func (h *AlarmHandler) bindListQuery(c *gin.Context) (models.AlarmQuery, error) {
+	var query models.AlarmQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "invalid query parameters",
+			"details": err.Error(),
+		})
+		return query, err
 	}
 
-	allowedTransitions, exists := validTransitions[currentStatus]
-	return exists && contains(allowedTransitions, newState)
-}
-
-// Fills missing fields from the existing alarm
-func (h *AlarmHandler) fillMissingFields(req models.UpdateAlarm, existing models.Alarm) models.Alarm {
-	// If fields are empty, use the existing values
-	if req.Name == "" {
-		req.Name = existing.Name
+	if err := utils.ValidateStruct(query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "query parameter validation failed",
+			"details": err.Error(),
+		})
+		return query, err
 	}
-	if req.Timestamp.IsZero() {
-		req.Timestamp = existing.Timestamp
+
+	if query.SortBy == "" {
+		query.SortBy = "created_at"
 	}
-	if req.Status == "" {
-		req.Status = existing.Status
+	if query.Order == "" {
+		query.Order = "asc"
 	}
 
-	return models.Alarm{
-		ID:        existing.ID,
-		Name:      req.Name,
-		Timestamp: req.Timestamp,
-		Status:    req.Status,
-		CreatedAt: existing.CreatedAt,
-		UpdatedAt: time.Now(),
+	for _, label := range query.Labels {
+		selector, err := models.ParseLabelSelector(label)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "invalid label selector",
+				"details": err.Error(),
+			})
+			return query, err
+		}
+		query.LabelSelectors = append(query.LabelSelectors, selector)
 	}
-}
 
-// Helper function to check if a state transition is allowed
-func contains(states []string, target string) bool {
-	for _, state := range states {
-		if state == target {
-			return true
+	if query.Cursor != "" {
+		var cursor models.AlarmCursor
+		err := utils.DecodeCursor(query.Cursor, &cursor)
+		if err == nil && (cursor.SortBy != query.SortBy || cursor.Order != query.Order) {
+			err = fmt.Errorf("cursor was issued for sort_by=%s and order=%s", cursor.SortBy, cursor.Order)
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "invalid cursor",
+				"details": err.Error(),
+			})
+			return query, err
 		}
+		query.After = &cursor
 	}
-	return false
+
+	return query, nil
+}
+
+// isInternalCall reports whether the request comes from the named service. Anyone
+// can send X-Source-Service, so the caller must also send INTERNAL_TOKEN in
+// X-Internal-Token. Without a token configured no call is trusted.
+func (h *AlarmHandler) isInternalCall(c *gin.Context, serviceName string) bool {
+	token := []byte(c.GetHeader("X-Internal-Token"))
+	return h.internalToken != "" &&
+		c.GetHeader("X-Source-Service") == serviceName &&
+		subtle.ConstantTimeCompare(token, []byte(h.internalToken)) == 1
 }
@@ -2,18 +2,24 @@ package handlers
 
 import (
 	"bytes"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 	"time"
 
+	"github.com/26christy/CarbonQuest/alarm-service/events"
+	"github.com/26christy/CarbonQuest/alarm-service/service"
+	"github.com/26christy/CarbonQuest/alarm-service/storage"
 	"github.com/26christy/CarbonQuest/common/models"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 // Mocking the AlarmServiceInterface
@@ -21,9 +27,13 @@ type MockAlarmService struct {
 	mock.Mock
 }
 
-func (m *MockAlarmService) CreateAlarm(alarm models.Alarm) error {
-	args := m.Called(alarm)
-	return args.Error(0)
+// CreateAlarm returns the alarm it is given when the expectation returns a nil alarm
+func (m *MockAlarmService) CreateAlarm(alarm models.Alarm, actor models.Actor) (*models.Alarm, bool, error) {
+	args := m.Called(alarm, actor)
+	if saved, ok := args.Get(0).(*models.Alarm); ok {
+		return saved, args.Bool(1), args.Error(2)
+	}
+	return &alarm, args.Bool(1), args.Error(2)
 }
 
 func (m *MockAlarmService) GetAlarm(id uuid.UUID) (*models.Alarm, error) {
@@ -39,14 +49,36 @@ func (m *MockAlarmService) GetAllAlarm() ([]models.Alarm, error) {
 	return args.Get(0).([]models.Alarm), args.Error(1)
 }
 
-func (m *MockAlarmService) UpdateAlarm(alarm models.Alarm) error {
-	args := m.Called(alarm)
+func (m *MockAlarmService) ListAlarms(query models.AlarmQuery) (models.AlarmPage, error) {
+	args := m.Called(query)
+	return args.Get(0).(models.AlarmPage), args.Error(1)
+}
+
+// UpdateAlarm returns an alarm with the id when the expectation returns a nil alarm
+func (m *MockAlarmService) UpdateAlarm(id uuid.UUID, changes models.UpdateAlarm, actor models.Actor, allowRollback bool) (*models.Alarm, error) {
+	args := m.Called(id, changes, actor, allowRollback)
+	if updated, ok := args.Get(0).(*models.Alarm); ok {
+		return updated, args.Error(1)
+	}
+	return &models.Alarm{ID: id, Name: changes.Name, Status: changes.Status}, args.Error(1)
+}
+
+func (m *MockAlarmService) DeleteAlarm(id uuid.UUID, actor models.Actor) error {
+	args := m.Called(id, actor)
 	return args.Error(0)
 }
 
-func (m *MockAlarmService) DeleteAlarm(id uuid.UUID) error {
+func (m *MockAlarmService) ResolveAlarm(id uuid.UUID, resolution models.ResolveAlarm, actor models.Actor) (*models.Alarm, error) {
+	args := m.Called(id, resolution, actor)
+	if args.Get(0) != nil {
+		return args.Get(0).(*models.Alarm), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockAlarmService) GetAlarmHistory(id uuid.UUID) ([]models.AlarmHistoryEntry, error) {
 	args := m.Called(id)
-	return args.Error(0)
+	return args.Get(0).([]models.AlarmHistoryEntry), args.Error(1)
 }
 
 func setupRouter(handler *AlarmHandler) *gin.Engine {
@@ -56,9 +88,29 @@ func setupRouter(handler *AlarmHandler) *gin.Engine {
 	router.GET("/alarms", handler.getAllAlarm)
 	router.PUT("/alarms/:id", handler.updateAlarm)
 	router.DELETE("/alarms/:id", handler.deleteAlarm)
+	router.POST("/alarms/:id/resolve", handler.resolveAlarm)
+	router.GET("/alarms/:id/history", handler.getAlarmHistory)
 	return router
 }
 
+// setupServiceRouter routes to the alarm service on a memory store, for tests that need the real service
+func setupServiceRouter() (*gin.Engine, storage.AlarmStorage) {
+	store := storage.NewMemoryStorage()
+	return setupRouter(NewAlarmHandler(service.NewAlarmService(store, events.NewRelay(store)))), store
+}
+
+// serveJSON sends the body to the router and decodes the response into out, if not nil
+func serveJSON(t *testing.T, router *gin.Engine, method, path, body string, out any) int {
+	req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	if out != nil {
+		require.NoError(t, json.Unmarshal(resp.Body.Bytes(), out), resp.Body.String())
+	}
+	return resp.Code
+}
+
 func TestCreateAlarm(t *testing.T) {
 	mockService := new(MockAlarmService)
 	handler := NewAlarmHandler(mockService)
@@ -68,13 +120,138 @@ func TestCreateAlarm(t *testing.T) {
 	req, _ := http.NewRequest(http.MethodPost, "/alarms", bytes.NewBuffer([]byte(reqBody)))
 	req.Header.Set("Content-Type", "application/json")
 
-	mockService.On("CreateAlarm", mock.Anything).Return(nil)
+	req.Header.Set("X-Actor", "alice")
+
+	mockService.On("CreateAlarm", mock.Anything, mock.Anything).Return(nil, true, nil)
 
 	resp := httptest.NewRecorder()
 	router.ServeHTTP(resp, req)
 
 	assert.Equal(t, http.StatusCreated, resp.Code)
-	mockService.AssertCalled(t, "CreateAlarm", mock.Anything)
+	mockService.AssertCalled(t, "CreateAlarm", mock.Anything, models.Actor{Name: "alice", Source: "api"})
+
+	var created models.Alarm
+	assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &created))
+	assert.Equal(t, models.DefaultSeverity, created.Severity)
+	assert.Equal(t, 2, created.Priority, "the priority defaults to the rank of the severity")
+	assert.Equal(t, models.Fingerprint("Test Alarm", nil), created.Fingerprint)
+	assert.Equal(t, 1, created.Occurrences)
+	assert.Equal(t, created.CreatedAt, created.LastSeenAt)
+}
+
+func TestCreateAlarm_Status(t *testing.T) {
+	mockService := new(MockAlarmService)
+	handler := NewAlarmHandler(mockService)
+	router := setupRouter(handler)
+	mockService.On("CreateAlarm", mock.Anything, mock.Anything).Return(nil, true, nil)
+
+	// A new alarm is triggered, it can't be created resolved or closed
+	for _, status := range []string{"active", "ACK", "resolved", "closed", "acknowledged"} {
+		code := serveJSON(t, router, http.MethodPost, "/alarms", `{"name":"Disk full","timestamp":"2025-03-17T15:04:05Z","status":"`+status+`"}`, nil)
+		assert.Equal(t, http.StatusBadRequest, code, status)
+	}
+	mockService.AssertNotCalled(t, "CreateAlarm", mock.Anything, mock.Anything)
+
+	var created models.Alarm
+	code := serveJSON(t, router, http.MethodPost, "/alarms", `{"name":"Disk full","timestamp":"2025-03-17T15:04:05Z","status":"triggered"}`, &created)
+	assert.Equal(t, http.StatusCreated, code)
+	assert.Equal(t, "triggered", created.Status)
+}
+
+func TestCreateAlarm_Deduplicated(t *testing.T) {
+	mockService := new(MockAlarmService)
+	handler := NewAlarmHandler(mockService)
+	router := setupRouter(handler)
+
+	existing := &models.Alarm{
+		ID:          uuid.New(),
+		Name:        "Disk",
+		Status:      "active",
+		Labels:      map[string]string{"host": "db-1"},
+		Fingerprint: models.Fingerprint("Disk", map[string]string{"host": "db-1"}),
+		Occurrences: 2,
+	}
+	mockService.On("CreateAlarm", mock.MatchedBy(func(alarm models.Alarm) bool {
+		return alarm.Fingerprint == existing.Fingerprint
+	}), mock.Anything).Return(existing, false, nil).Once()
+
+	reqBody := `{"name":"Disk","timestamp":"2023-03-17T15:04:05Z","labels":{"host":"db-1"}}`
+	req, _ := http.NewRequest(http.MethodPost, "/alarms", bytes.NewBufferString(reqBody))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusOK, resp.Code, "a repeat of an open alarm is not created again")
+	var body models.Alarm
+	assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &body))
+	assert.Equal(t, existing.ID, body.ID)
+	assert.Equal(t, 2, body.Occurrences)
+	mockService.AssertExpectations(t)
+}
+
+func TestCreateAlarm_SeverityAndPriority(t *testing.T) {
+	mockService := new(MockAlarmService)
+	handler := NewAlarmHandler(mockService)
+	router := setupRouter(handler)
+	mockService.On("CreateAlarm", mock.Anything, mock.Anything).Return(nil, true, nil)
+
+	tests := []struct {
+		body             string
+		expectedStatus   int
+		expectedSeverity string
+		expectedPriority int
+	}{
+		{`{"name":"Outage","timestamp":"2023-03-17T15:04:05Z","severity":"critical"}`, http.StatusCreated, models.SeverityCritical, 1},
+		{`{"name":"Disk","timestamp":"2023-03-17T15:04:05Z","severity":"warning","priority":2}`, http.StatusCreated, models.SeverityWarning, 2},
+		{`{"name":"Disk","timestamp":"2023-03-17T15:04:05Z","severity":"fatal"}`, http.StatusBadRequest, "", 0},
+		{`{"name":"Disk","timestamp":"2023-03-17T15:04:05Z","priority":6}`, http.StatusBadRequest, "", 0},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest(http.MethodPost, "/alarms", bytes.NewBufferString(tt.body))
+		req.Header.Set("Content-Type", "application/json")
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, tt.expectedStatus, resp.Code, tt.body)
+		if tt.expectedStatus != http.StatusCreated {
+			continue
+		}
+		var created models.Alarm
+		assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &created))
+		assert.Equal(t, tt.expectedSeverity, created.Severity, tt.body)
+		assert.Equal(t, tt.expectedPriority, created.Priority, tt.body)
+	}
+}
+
+func TestCreateAlarm_LabelsAndAnnotations(t *testing.T) {
+	mockService := new(MockAlarmService)
+	handler := NewAlarmHandler(mockService)
+	router := setupRouter(handler)
+	mockService.On("CreateAlarm", mock.Anything, mock.Anything).Return(nil, true, nil)
+
+	tests := []struct {
+		body           string
+		expectedStatus int
+	}{
+		{`{"name":"Disk","timestamp":"2023-03-17T15:04:05Z","labels":{"team":"db","app.kubernetes.io/name":"postgres"},"annotations":{"runbook_url":"https://runbooks.example.com/disk"}}`, http.StatusCreated},
+		{`{"name":"Disk","timestamp":"2023-03-17T15:04:05Z","labels":{"":"db"}}`, http.StatusBadRequest},
+		{`{"name":"Disk","timestamp":"2023-03-17T15:04:05Z","labels":{"team name":"db"}}`, http.StatusBadRequest},
+		{`{"name":"Disk","timestamp":"2023-03-17T15:04:05Z","annotations":{"1st":"x"}}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest(http.MethodPost, "/alarms", bytes.NewBufferString(tt.body))
+		req.Header.Set("Content-Type", "application/json")
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, tt.expectedStatus, resp.Code, tt.body)
+	}
+
+	mockService.AssertCalled(t, "CreateAlarm", mock.MatchedBy(func(alarm models.Alarm) bool {
+		return alarm.Labels["team"] == "db" && alarm.Labels["app.kubernetes.io/name"] == "postgres" &&
+			alarm.Annotations["runbook_url"] == "https://runbooks.example.com/disk"
+	}), mock.Anything)
+	mockService.AssertNumberOfCalls(t, "CreateAlarm", 1)
 }
 
 func TestGetAlarm(t *testing.T) {
@@ -129,7 +306,7 @@ func TestGetAllAlarm(t *testing.T) {
 		},
 	}
 
-	mockService.On("GetAllAlarm").Return(alarms, nil).Once()
+	mockService.On("ListAlarms", mock.Anything).Return(models.AlarmPage{Alarms: alarms}, nil).Once()
 
 	req, _ := http.NewRequest(http.MethodGet, "/alarms", nil)
 	resp := httptest.NewRecorder()
@@ -146,7 +323,7 @@ func TestGetAllAlarm(t *testing.T) {
 	assert.Equal(t, "Alarm 2", successResponse["alarms"][1].Name)
 
 	// Test case 2: No alarms found (should return an empty list)
-	mockService.On("GetAllAlarm").Return([]models.Alarm{}, nil).Once()
+	mockService.On("ListAlarms", mock.Anything).Return(models.AlarmPage{Alarms: []models.Alarm{}}, nil).Once()
 
 	req, _ = http.NewRequest(http.MethodGet, "/alarms", nil)
 	resp = httptest.NewRecorder()
@@ -163,6 +340,88 @@ func TestGetAllAlarm(t *testing.T) {
 	mockService.AssertExpectations(t)
 }
 
+func TestGetAllAlarm_Query(t *testing.T) {
+	mockService := new(MockAlarmService)
+	handler := NewAlarmHandler(mockService)
+	router := setupRouter(handler)
+
+	next := &models.AlarmCursor{SortBy: "timestamp", Order: "desc", Key: "k", ID: uuid.New().String()}
+	mockService.On("ListAlarms", mock.MatchedBy(func(query models.AlarmQuery) bool {
+		return query.Status == "triggered" &&
+			query.Name == "disk" &&
+			assert.ObjectsAreEqual([]string{"critical", "major"}, query.Severities) &&
+			assert.ObjectsAreEqual([]int{1}, query.Priorities) &&
+			assert.ObjectsAreEqual([]models.LabelSelector{
+				{Key: "team", Op: models.LabelEquals, Value: "db"},
+				{Key: "region", Op: models.LabelNotEquals, Value: "eu-west"},
+				{Key: "host", Op: models.LabelExists},
+				{Key: "muted", Op: models.LabelNotExists},
+			}, query.LabelSelectors) &&
+			query.SortBy == "timestamp" &&
+			query.Order == "desc" &&
+			query.Limit == 2 &&
+			query.TimestampFrom.Equal(time.Date(2025, 3, 18, 0, 0, 0, 0, time.UTC)) &&
+			query.After == nil
+	})).Return(models.AlarmPage{Alarms: []models.Alarm{{ID: uuid.New()}, {ID: uuid.New()}}, Next: next}, nil).Once()
+
+	req, _ := http.NewRequest(http.MethodGet, "/alarms?status=triggered&severity=critical&severity=major&priority=1&label=team%3Ddb&label=region!%3Deu-west&label=host&label=!muted&name=disk&sort_by=timestamp&order=desc&limit=2&timestamp_from=2025-03-18T00:00:00Z", nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	var body struct {
+		Alarms     []models.Alarm `json:"alarms"`
+		NextCursor string         `json:"next_cursor"`
+	}
+	assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &body))
+	assert.Len(t, body.Alarms, 2)
+	assert.NotEmpty(t, body.NextCursor)
+
+	// Following the cursor passes the decoded position back to the service
+	mockService.On("ListAlarms", mock.MatchedBy(func(query models.AlarmQuery) bool {
+		return query.After != nil && *query.After == *next
+	})).Return(models.AlarmPage{}, nil).Once()
+
+	req, _ = http.NewRequest(http.MethodGet, "/alarms?sort_by=timestamp&order=desc&limit=2&cursor="+body.NextCursor, nil)
+	resp = httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.JSONEq(t, `{"alarms":[]}`, resp.Body.String())
+	mockService.AssertExpectations(t)
+}
+
+func TestGetAllAlarm_InvalidQuery(t *testing.T) {
+	mockService := new(MockAlarmService)
+	handler := NewAlarmHandler(mockService)
+	router := setupRouter(handler)
+
+	cursor := base64.RawURLEncoding.EncodeToString([]byte(`{"sort_by":"name","order":"asc"}`))
+	for _, rawQuery := range []string{
+		"status=unknown",
+		"severity=fatal",
+		"priority=0",
+		"label=",
+		"label=team%20name%3Ddb",
+		"label=!%3Ddb",
+		"sort_by=severity",
+		"order=sideways",
+		"limit=5000",
+		"timestamp_from=yesterday",
+		"cursor=not-a-cursor",
+		// cursor issued for a different ordering
+		"cursor=" + cursor,
+	} {
+		req, _ := http.NewRequest(http.MethodGet, "/alarms?"+rawQuery, nil)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusBadRequest, resp.Code, rawQuery)
+	}
+	mockService.AssertNotCalled(t, "ListAlarms", mock.Anything)
+}
+
 func TestUpdateAlarm(t *testing.T) {
 	mockService := new(MockAlarmService)
 	handler := NewAlarmHandler(mockService)
@@ -170,24 +429,28 @@ func TestUpdateAlarm(t *testing.T) {
 
 	alarmID := uuid.New()
 	existingAlarm := &models.Alarm{
-		ID:        alarmID,
-		Name:      "Old Alarm",
-		Timestamp: time.Now(),
-		Status:    "active",
+		ID:          alarmID,
+		Name:        "Old Alarm",
+		Timestamp:   time.Now(),
+		Status:      "active",
+		Severity:    models.SeverityMinor,
+		Priority:    3,
+		Labels:      map[string]string{"team": "db"},
+		Occurrences: 4,
 	}
 
 	// Mocking the GetAlarm method to return the existing alarm
 	mockService.On("GetAlarm", alarmID).Return(existingAlarm, nil)
 
 	// Mocking the UpdateAlarm method to simulate a successful update
-	mockService.On("UpdateAlarm", mock.MatchedBy(func(alarm models.Alarm) bool {
-		return alarm.ID == alarmID && alarm.Name == "Updated Alarm" && alarm.Status == "ACK"
-	})).Return(nil)
+	mockService.On("UpdateAlarm", alarmID, mock.Anything, mock.Anything, mock.Anything).Return(nil, nil)
 
 	// Preparing a valid JSON request body
 	reqBody := `{"name":"Updated Alarm","status":"ACK"}`
 	req, _ := http.NewRequest(http.MethodPut, fmt.Sprintf("/alarms/%s", alarmID.String()), bytes.NewBuffer([]byte(reqBody)))
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Actor", "ack-service")
+	req.Header.Set("X-Source-Service", "ack-service")
 
 	// Performing the request
 	resp := httptest.NewRecorder()
@@ -195,12 +458,214 @@ func TestUpdateAlarm(t *testing.T) {
 
 	// Asserting the response status
 	assert.Equal(t, http.StatusOK, resp.Code, "Expected status 200 OK")
+	assert.Contains(t, resp.Body.String(), `"name":"Updated Alarm"`)
 
-	// Verifying that the expected methods were called
+	// Verifying that only the requested changes are passed on, the service applies them
 	mockService.AssertCalled(t, "GetAlarm", alarmID)
-	mockService.AssertCalled(t, "UpdateAlarm", mock.MatchedBy(func(alarm models.Alarm) bool {
-		return alarm.ID == alarmID && alarm.Name == "Updated Alarm" && alarm.Status == "ACK"
-	}))
+	mockService.AssertCalled(t, "UpdateAlarm", alarmID, models.UpdateAlarm{Name: "Updated Alarm", Status: "ACK"},
+		models.Actor{Name: "ack-service", Source: "ack-service"}, false)
+}
+
+func TestUpdateAlarm_Resolve(t *testing.T) {
+	mockService := new(MockAlarmService)
+	handler := NewAlarmHandler(mockService)
+	router := setupRouter(handler)
+
+	alarmID := uuid.New()
+	mockService.On("GetAlarm", alarmID).Return(&models.Alarm{ID: alarmID, Name: "Disk full", Status: "ACK"}, nil)
+	mockService.On("UpdateAlarm", alarmID, mock.Anything, mock.Anything, mock.Anything).Return(nil, nil)
+
+	reqBody := `{"status":"resolved","resolve_reason":"false_positive","resolve_note":"probe misconfigured"}`
+	req, _ := http.NewRequest(http.MethodPut, "/alarms/"+alarmID.String(), bytes.NewBufferString(reqBody))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+	mockService.AssertCalled(t, "UpdateAlarm", alarmID, models.UpdateAlarm{
+		Status: "resolved", ResolveReason: models.ResolveFalsePositive, ResolveNote: "probe misconfigured",
+	}, mock.Anything, false)
+}
+
+func TestUpdateAlarm_WithoutStatus(t *testing.T) {
+	router, store := setupServiceRouter()
+
+	var created models.Alarm
+	code := serveJSON(t, router, http.MethodPost, "/alarms", `{"name":"Disk full","timestamp":"2025-03-17T15:04:05Z","severity":"minor"}`, &created)
+	require.Equal(t, http.StatusCreated, code)
+	var resolved models.Alarm
+	code = serveJSON(t, router, http.MethodPost, "/alarms/"+created.ID.String()+"/resolve", `{"reason":"duplicate"}`, &resolved)
+	require.Equal(t, http.StatusOK, code)
+
+	// Neither a missing status nor the current one is a transition, and the resolve is kept
+	for _, reqBody := range []string{`{"severity":"critical"}`, `{"priority":4,"status":"resolved"}`} {
+		code = serveJSON(t, router, http.MethodPut, "/alarms/"+created.ID.String(), reqBody, nil)
+		assert.Equal(t, http.StatusOK, code, reqBody)
+	}
+
+	stored, err := store.GetAlarm(created.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.SeverityCritical, stored.Severity)
+	assert.Equal(t, 4, stored.Priority)
+	assert.Equal(t, "resolved", stored.Status)
+	assert.Equal(t, models.ResolveDuplicate, stored.ResolveReason)
+	assert.True(t, stored.ResolvedAt.Equal(*resolved.ResolvedAt))
+}
+
+// resolvingService resolves an alarm right after the handler read it, as a concurrent resolve would
+type resolvingService struct {
+	*service.AlarmService
+}
+
+func (s resolvingService) GetAlarm(id uuid.UUID) (*models.Alarm, error) {
+	alarm, err := s.AlarmService.GetAlarm(id)
+	if err == nil {
+		_, err = s.ResolveAlarm(id, models.ResolveAlarm{}, models.Actor{Name: "bob", Source: "api"})
+	}
+	return alarm, err
+}
+
+func TestUpdateAlarm_ConcurrentResolve(t *testing.T) {
+	store := storage.NewMemoryStorage()
+	alarmService := service.NewAlarmService(store, events.NewRelay(store))
+	router := setupRouter(NewAlarmHandler(resolvingService{alarmService}))
+
+	alarm := models.Alarm{ID: uuid.New(), Name: "Disk full", Status: "ACK"}
+	require.NoError(t, store.SaveAlarm(alarm))
+
+	// ACK to active was valid when the handler read the alarm, resolved to active is not
+	code := serveJSON(t, router, http.MethodPut, "/alarms/"+alarm.ID.String(), `{"status":"active"}`, nil)
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	stored, err := store.GetAlarm(alarm.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "resolved", stored.Status, "the resolve is not undone")
+}
+
+func TestUpdateAlarm_LabelsOnly(t *testing.T) {
+	router, store := setupServiceRouter()
+
+	var created models.Alarm
+	code := serveJSON(t, router, http.MethodPost, "/alarms", `{"name":"Disk full","timestamp":"2025-03-17T15:04:05Z","labels":{"host":"db-1"}}`, &created)
+	require.Equal(t, http.StatusCreated, code)
+
+	var updated models.Alarm
+	code = serveJSON(t, router, http.MethodPut, "/alarms/"+created.ID.String(), `{"labels":{"host":"db-2"}}`, &updated)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "triggered", updated.Status)
+
+	stored, err := store.GetAlarm(created.ID)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"host": "db-2"}, stored.Labels)
+	assert.Equal(t, models.Fingerprint("Disk full", stored.Labels), stored.Fingerprint, "the fingerprint follows the labels")
+
+	// A repeat with the new labels is counted on the alarm, one with the old labels is another alarm
+	var repeated models.Alarm
+	code = serveJSON(t, router, http.MethodPost, "/alarms", `{"name":"Disk full","timestamp":"2025-03-17T15:05:05Z","labels":{"host":"db-2"}}`, &repeated)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, created.ID, repeated.ID)
+	assert.Equal(t, 2, repeated.Occurrences)
+
+	var other models.Alarm
+	code = serveJSON(t, router, http.MethodPost, "/alarms", `{"name":"Disk full","timestamp":"2025-03-17T15:06:05Z","labels":{"host":"db-1"}}`, &other)
+	assert.Equal(t, http.StatusCreated, code)
+	assert.NotEqual(t, created.ID, other.ID)
+}
+
+func TestUpdateAlarm_Rollback(t *testing.T) {
+	t.Setenv("INTERNAL_TOKEN", "internal-secret")
+	mockService := new(MockAlarmService)
+	handler := NewAlarmHandler(mockService)
+	router := setupRouter(handler)
+
+	alarmID := uuid.New()
+	mockService.On("GetAlarm", alarmID).Return(&models.Alarm{ID: alarmID, Name: "Disk full", Status: "ACK"}, nil)
+	// The service only allows the rollback when the handler trusts the caller as ack-service
+	changes := models.UpdateAlarm{Status: "triggered"}
+	mockService.On("UpdateAlarm", alarmID, changes, mock.Anything, false).Return(nil, service.ErrInvalidTransition)
+	mockService.On("UpdateAlarm", alarmID, changes, mock.Anything, true).Return(nil, nil)
+
+	rollback := func(source, token string) int {
+		req, _ := http.NewRequest(http.MethodPut, "/alarms/"+alarmID.String(), bytes.NewBufferString(`{"status":"triggered"}`))
+		req.Header.Set("Content-Type", "application/json")
+		if source != "" {
+			req.Header.Set("X-Source-Service", source)
+		}
+		if token != "" {
+			req.Header.Set("X-Internal-Token", token)
+		}
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		return resp.Code
+	}
+
+	// Only ack-service undoing its ACK may put an alarm back to triggered
+	assert.Equal(t, http.StatusBadRequest, rollback("", ""))
+	assert.Equal(t, http.StatusBadRequest, rollback("x", ""), "any other source is the public API")
+	assert.Equal(t, http.StatusBadRequest, rollback("ack-service", ""), "the source header alone is not trusted")
+	assert.Equal(t, http.StatusBadRequest, rollback("ack-service", "guessed"))
+	assert.Equal(t, http.StatusBadRequest, rollback("notification-service", "internal-secret"))
+	mockService.AssertNotCalled(t, "UpdateAlarm", mock.Anything, mock.Anything, mock.Anything, true)
+	assert.Equal(t, http.StatusOK, rollback("ack-service", "internal-secret"))
+	mockService.AssertCalled(t, "UpdateAlarm", alarmID, changes, models.Actor{Name: "anonymous", Source: "ack-service"}, true)
+}
+
+func TestResolveAlarm(t *testing.T) {
+	mockService := new(MockAlarmService)
+	handler := NewAlarmHandler(mockService)
+	router := setupRouter(handler)
+
+	alarmID := uuid.New()
+	resolvedAt := time.Now()
+	resolved := &models.Alarm{ID: alarmID, Status: "resolved", ResolvedAt: &resolvedAt, ResolveReason: models.ResolveDuplicate}
+	mockService.On("GetAlarm", alarmID).Return(&models.Alarm{ID: alarmID, Status: "active"}, nil)
+	mockService.On("ResolveAlarm", alarmID, models.ResolveAlarm{Reason: "duplicate", Note: "see the db alarm"}, mock.Anything).
+		Return(resolved, nil).Once()
+
+	req, _ := http.NewRequest(http.MethodPost, "/alarms/"+alarmID.String()+"/resolve",
+		bytes.NewBufferString(`{"reason":"duplicate","note":"see the db alarm"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Actor", "alice")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+	var body models.Alarm
+	assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &body))
+	assert.Equal(t, "resolved", body.Status)
+	assert.Equal(t, models.ResolveDuplicate, body.ResolveReason)
+	mockService.AssertCalled(t, "ResolveAlarm", alarmID, mock.Anything, models.Actor{Name: "alice", Source: "api"})
+
+	// Without a body
+	mockService.On("ResolveAlarm", alarmID, models.ResolveAlarm{}, mock.Anything).Return(resolved, nil).Once()
+	req, _ = http.NewRequest(http.MethodPost, "/alarms/"+alarmID.String()+"/resolve", nil)
+	resp = httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	// Already resolved
+	mockService.On("ResolveAlarm", alarmID, models.ResolveAlarm{}, mock.Anything).Return(nil, service.ErrAlarmNotOpen).Once()
+	req, _ = http.NewRequest(http.MethodPost, "/alarms/"+alarmID.String()+"/resolve", nil)
+	resp = httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusConflict, resp.Code)
+
+	// Invalid reason
+	req, _ = http.NewRequest(http.MethodPost, "/alarms/"+alarmID.String()+"/resolve", bytes.NewBufferString(`{"reason":"quiet"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp = httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+	// Unknown alarm
+	unknownID := uuid.New()
+	mockService.On("GetAlarm", unknownID).Return(nil, errors.New("alarm not found"))
+	req, _ = http.NewRequest(http.MethodPost, "/alarms/"+unknownID.String()+"/resolve", nil)
+	resp = httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+
+	mockService.AssertNumberOfCalls(t, "ResolveAlarm", 3)
 }
 
 func TestDeleteAlarm(t *testing.T) {
@@ -209,12 +674,59 @@ func TestDeleteAlarm(t *testing.T) {
 	router := setupRouter(handler)
 
 	alarmID := uuid.New()
-	mockService.On("DeleteAlarm", alarmID).Return(nil)
+	mockService.On("DeleteAlarm", alarmID, mock.Anything).Return(nil)
 
 	req, _ := http.NewRequest(http.MethodDelete, fmt.Sprintf("/alarms/%s", alarmID.String()), nil)
 	resp := httptest.NewRecorder()
 	router.ServeHTTP(resp, req)
 
 	assert.Equal(t, http.StatusNoContent, resp.Code)
-	mockService.AssertCalled(t, "DeleteAlarm", alarmID)
+	mockService.AssertCalled(t, "DeleteAlarm", alarmID, models.Actor{Name: "anonymous", Source: "api"})
+}
+
+func TestGetAlarmHistory(t *testing.T) {
+	mockService := new(MockAlarmService)
+	handler := NewAlarmHandler(mockService)
+	router := setupRouter(handler)
+
+	alarmID := uuid.New()
+	history := []models.AlarmHistoryEntry{
+		{ID: uuid.New(), AlarmID: alarmID, Action: models.AlarmCreated, Actor: "alice", Source: "api"},
+		{ID: uuid.New(), AlarmID: alarmID, Action: models.AlarmDeleted, Actor: "alice", Source: "api"},
+	}
+	mockService.On("GetAlarmHistory", alarmID).Return(history, nil).Once()
+
+	req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("/alarms/%s/history", alarmID.String()), nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	var body map[string][]models.AlarmHistoryEntry
+	assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &body))
+	assert.Len(t, body["history"], 2)
+	assert.Equal(t, models.AlarmDeleted, body["history"][1].Action)
+
+	// An alarm without history, such as one created before it was recorded
+	quietID := uuid.New()
+	mockService.On("GetAlarmHistory", quietID).Return([]models.AlarmHistoryEntry{}, nil).Once()
+	mockService.On("GetAlarm", quietID).Return(&models.Alarm{ID: quietID}, nil).Once()
+
+	req, _ = http.NewRequest(http.MethodGet, fmt.Sprintf("/alarms/%s/history", quietID.String()), nil)
+	resp = httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.JSONEq(t, `{"history":[]}`, resp.Body.String())
+
+	// Unknown alarm
+	unknownID := uuid.New()
+	mockService.On("GetAlarmHistory", unknownID).Return([]models.AlarmHistoryEntry{}, nil).Once()
+	mockService.On("GetAlarm", unknownID).Return(nil, errors.New("alarm not found")).Once()
+
+	req, _ = http.NewRequest(http.MethodGet, fmt.Sprintf("/alarms/%s/history", unknownID.String()), nil)
+	resp = httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusNotFound, resp.Code)
 }
@@ -12,5 +12,7 @@ func RegisterRoutes(router *gin.Engine, alarmService *AlarmHandler) {
 		api.GET("/", alarmService.getAllAlarm)
 		api.DELETE("/:id", alarmService.deleteAlarm)
 		api.PUT("/:id", alarmService.updateAlarm)
+		api.POST("/:id/resolve", alarmService.resolveAlarm)
+		api.GET("/:id/history", alarmService.getAlarmHistory)
 	}
 }
@@ -2,13 +2,16 @@ package main
 
 import (
 	"context"
+	"io"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
+	"github.com/26christy/CarbonQuest/alarm-service/events"
 	"github.com/26christy/CarbonQuest/alarm-service/handlers"
 	"github.com/26christy/CarbonQuest/alarm-service/service"
 	"github.com/26christy/CarbonQuest/alarm-service/storage"
@@ -34,12 +37,35 @@ func main() {
 		port = "8080"
 	}
 
-	// Initialize dependencies
-	store := storage.NewMemoryStorage()
+	// Initialize dependencies, STORAGE_TYPE selects the backend (memory or sqlite)
+	store, err := storage.CreateStorage(os.Getenv("STORAGE_TYPE"), os.Getenv("STORAGE_PATH"))
+	if err != nil {
+		logger.Fatalf("Failed to initialize storage: %v", err)
+	}
 
-	alarmService := service.NewAlarmService(store)
+	// Alarm changes are written to the outbox and relayed to every URL in EVENT_SUBSCRIBERS (comma separated)
+	relay := events.NewRelay(store)
+	subscriberClient := &http.Client{Timeout: 10 * time.Second}
+	for _, url := range strings.Split(os.Getenv("EVENT_SUBSCRIBERS"), ",") {
+		if url = strings.TrimSpace(url); url != "" {
+			relay.Subscribe(url, "*", events.NewHTTPSubscriber(url, subscriberClient))
+			logger.Infof("Publishing alarm events to %s", url)
+		}
+	}
+
+	relay.Start()
+
+	alarmService := service.NewAlarmService(store, relay)
 	alarmHandler := handlers.NewAlarmHandler(alarmService)
 
+	// Open alarms not seen again for AUTO_RESOLVE_MINUTES are resolved, unset turns this off
+	var autoResolver *service.AutoResolver
+	if quiet := service.AutoResolveQuietPeriod(); quiet > 0 {
+		autoResolver = service.NewAutoResolver(alarmService, quiet)
+		autoResolver.Start()
+		logger.Infof("Auto-resolving alarms quiet for %s", quiet)
+	}
+
 	// Setup Gin router with middleware
 	router := gin.New()
 	// Default recovery middleware
@@ -64,6 +90,19 @@ func main() {
 
 	// Graceful shutdown
 	gracefulShutdown(server, logger)
+
+	// Stop auto-resolve and event delivery before the store goes away
+	if autoResolver != nil {
+		autoResolver.Close()
+	}
+	relay.Close()
+
+	// Release the database handle for persistent stores
+	if closer, ok := store.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			logger.Errorf("Failed to close storage: %v", err)
+		}
+	}
 }
 
 // gracefulShutdown handles clean shutdown on SIGINT or SIGTERM
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/26christy/CarbonQuest/common/models"
+)
+
+// autoResolveInterval is how often the auto-resolver looks for quiet alarms
+const autoResolveInterval = time.Minute
+
+// autoResolveActor is recorded in the history of the alarms the auto-resolver resolves
+var autoResolveActor = models.Actor{Name: "auto-resolve", Source: "alarm-service"}
+
+// AutoResolver resolves open alarms that were not seen again for the quiet
+// period, for sources that never report their alarms as cleared
+type AutoResolver struct {
+	service  *AlarmService
+	quiet    time.Duration
+	interval time.Duration
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewAutoResolver initializes an auto-resolver for alarms quiet for the period
+func NewAutoResolver(service *AlarmService, quiet time.Duration) *AutoResolver {
+	return &AutoResolver{
+		service:  service,
+		quiet:    quiet,
+		interval: autoResolveInterval,
+		done:     make(chan struct{}),
+	}
+}
+
+// Start runs the auto-resolver until Close is called
+func (a *AutoResolver) Start() {
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+
+		ticker := time.NewTicker(a.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				a.resolve(time.Now())
+			case <-a.done:
+				return
+			}
+		}
+	}()
+}
+
+// Close stops the auto-resolver
+func (a *AutoResolver) Close() error {
+	close(a.done)
+	a.wg.Wait()
+	return nil
+}
+
+// resolve makes one pass over the open alarms
+func (a *AutoResolver) resolve(now time.Time) {
+	resolved, err := a.service.ResolveQuietAlarms(now.Add(-a.quiet), autoResolveActor)
+	if err != nil {
+		fmt.Printf("[AutoResolve] Failed to resolve quiet alarms: %v\n", err)
+	}
+	if resolved > 0 {
+		fmt.Printf("[AutoResolve] Resolved %d alarms quiet for %s\n", resolved, a.quiet)
+	}
+}
+
+// AutoResolveQuietPeriod reads AUTO_RESOLVE_MINUTES, the time an open alarm must go
+// without repeats to be resolved. Zero, the default, turns auto-resolve off.
+func AutoResolveQuietPeriod() time.Duration {
+	minutes, err := strconv.Atoi(os.Getenv("AUTO_RESOLVE_MINUTES"))
+	if err != nil || minutes <= 0 {
+		return 0
+	}
+	return time.Duration(minutes) * time.Minute
+}
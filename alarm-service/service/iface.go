@@ -6,9 +6,19 @@ import (
 )
 
 type AlarmServiceInterface interface {
-	CreateAlarm(alarm models.Alarm) error
+	// CreateAlarm returns the saved alarm, created is false when the alarm
+	// was deduplicated into an open alarm with the same fingerprint
+	CreateAlarm(alarm models.Alarm, actor models.Actor) (saved *models.Alarm, created bool, err error)
 	GetAlarm(id uuid.UUID) (*models.Alarm, error)
 	GetAllAlarm() ([]models.Alarm, error)
-	DeleteAlarm(id uuid.UUID) error
-	UpdateAlarm(alarm models.Alarm) error
+	ListAlarms(query models.AlarmQuery) (models.AlarmPage, error)
+	DeleteAlarm(id uuid.UUID, actor models.Actor) error
+	// UpdateAlarm applies the changes to the alarm and returns the updated alarm,
+	// fields left empty in the changes keep their value. It returns
+	// ErrInvalidTransition when the alarm can't change to the status of the
+	// changes. allowRollback lets ack-service put an ACK alarm back to triggered.
+	UpdateAlarm(id uuid.UUID, changes models.UpdateAlarm, actor models.Actor, allowRollback bool) (*models.Alarm, error)
+	// ResolveAlarm returns ErrAlarmNotOpen when the alarm is already resolved or closed
+	ResolveAlarm(id uuid.UUID, resolution models.ResolveAlarm, actor models.Actor) (*models.Alarm, error)
+	GetAlarmHistory(id uuid.UUID) ([]models.AlarmHistoryEntry, error)
 }
@@ -1,30 +1,96 @@
 package service
 
 import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/26christy/CarbonQuest/alarm-service/events"
 	"github.com/26christy/CarbonQuest/alarm-service/storage"
 	"github.com/26christy/CarbonQuest/common/models"
 	"github.com/google/uuid"
 )
 
+// ErrAlarmNotOpen is returned for resolving an alarm that is already resolved or closed
+var ErrAlarmNotOpen = errors.New("alarm is not open")
+
+// ErrInvalidTransition is returned for an update to a status the alarm can't change to
+var ErrInvalidTransition = errors.New("invalid state transition")
+
+// validTransitions are the statuses an alarm can change to from each status.
+// Open alarms can be resolved, resolved alarms closed, and closed alarms stay closed.
+var validTransitions = map[string][]string{
+	"triggered": {"active", "ACK", "resolved"},
+	"active":    {"ACK", "resolved"},
+	"ACK":       {"active", "resolved"},
+	"resolved":  {"closed"},
+}
+
 type AlarmService struct {
-	store storage.AlarmStorage
+	store     storage.AlarmStorage
+	publisher events.EventPublisher
 }
 
 // Constructor
-func NewAlarmService(store storage.AlarmStorage) *AlarmService {
+func NewAlarmService(store storage.AlarmStorage, publisher events.EventPublisher) *AlarmService {
 	return &AlarmService{
-		store: store,
+		store:     store,
+		publisher: publisher,
 	}
 }
 
-// Create Alarm
-func (s *AlarmService) CreateAlarm(alarm models.Alarm) error {
-	err := s.store.SaveAlarm(alarm)
+// Create Alarm, or count another occurrence of the open alarm with its fingerprint.
+// A deduplicated alarm keeps its status, severity and priority, and takes the
+// annotations of the create when it has any.
+func (s *AlarmService) CreateAlarm(alarm models.Alarm, actor models.Actor) (*models.Alarm, bool, error) {
+	saved, created := &alarm, true
+	err := s.change(func(tx storage.AlarmStorage) (*models.AlarmHistoryEntry, error) {
+		if alarm.Fingerprint != "" {
+			existing, err := tx.FindOpenAlarm(alarm.Fingerprint)
+			if err != nil {
+				return nil, err
+			}
+			if existing != nil {
+				occurred, err := deduplicate(tx, *existing, alarm)
+				if err != nil {
+					return nil, err
+				}
+				saved, created = occurred, false
+				return newHistoryEntry(occurred.ID, models.AlarmDeduplicated, actor, existing, occurred), nil
+			}
+		}
+
+		if err := tx.SaveAlarm(alarm); err != nil {
+			return nil, err
+		}
+
+		return newHistoryEntry(alarm.ID, models.AlarmCreated, actor, nil, &alarm), nil
+	})
 	if err != nil {
-		return err
+		return nil, false, err
 	}
+	return saved, created, nil
+}
 
-	return nil
+// deduplicate records another occurrence of the existing alarm and returns it as stored
+func deduplicate(tx storage.AlarmStorage, existing, alarm models.Alarm) (*models.Alarm, error) {
+	occurred := existing
+	occurred.Occurrences++
+	occurred.LastSeenAt = alarm.LastSeenAt
+	if alarm.Annotations != nil {
+		occurred.Annotations = alarm.Annotations
+	}
+	if err := tx.UpdateAlarm(occurred); err != nil {
+		return nil, err
+	}
+
+	// The store owns UpdatedAt, read back what was actually written
+	updated, err := tx.GetAlarm(occurred.ID)
+	if err != nil {
+		return &occurred, nil
+	}
+	return updated, nil
 }
 
 // Get an alarm
@@ -37,12 +103,217 @@ func (s *AlarmService) GetAllAlarm() ([]models.Alarm, error) {
 	return s.store.GetAllAlarms()
 }
 
+// List alarms matching the query, one page at a time
+func (s *AlarmService) ListAlarms(query models.AlarmQuery) (models.AlarmPage, error) {
+	return s.store.ListAlarms(query)
+}
+
 // Delete an alarm
-func (s *AlarmService) DeleteAlarm(id uuid.UUID) error {
-	return s.store.DeleteAlarm(id)
+func (s *AlarmService) DeleteAlarm(id uuid.UUID, actor models.Actor) error {
+	return s.change(func(tx storage.AlarmStorage) (*models.AlarmHistoryEntry, error) {
+		existing, err := tx.GetAlarm(id)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := tx.DeleteAlarm(id); err != nil {
+			return nil, err
+		}
+
+		return newHistoryEntry(id, models.AlarmDeleted, actor, existing, nil), nil
+	})
+}
+
+// Update an alarm with the changes. They are applied and the status transition
+// is checked against the alarm as read in the transaction, so an occurrence or
+// resolve that ran since the caller last read the alarm is kept.
+func (s *AlarmService) UpdateAlarm(id uuid.UUID, changes models.UpdateAlarm, actor models.Actor, allowRollback bool) (*models.Alarm, error) {
+	var updated *models.Alarm
+	err := s.change(func(tx storage.AlarmStorage) (*models.AlarmHistoryEntry, error) {
+		existing, err := tx.GetAlarm(id)
+		if err != nil {
+			return nil, err
+		}
+
+		// An update without a status, or with the status the alarm has, leaves the status as it is
+		if changes.Status != "" && changes.Status != existing.Status &&
+			!isValidStateTransition(existing.Status, changes.Status) &&
+			!(allowRollback && isRollbackTransition(existing.Status, changes.Status)) {
+			return nil, fmt.Errorf("%w from %s to %s", ErrInvalidTransition, existing.Status, changes.Status)
+		}
+
+		alarm := applyChanges(*existing, changes, time.Now())
+		if err := tx.UpdateAlarm(alarm); err != nil {
+			return nil, err
+		}
+
+		// The store owns UpdatedAt, read back what was actually written
+		if updated, err = tx.GetAlarm(id); err != nil {
+			updated = &alarm
+		}
+
+		action := models.AlarmUpdated
+		if existing.Status != updated.Status {
+			action = models.AlarmStatusChanged
+		}
+		return newHistoryEntry(id, action, actor, existing, updated), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// Checks if the state transition is valid
+func isValidStateTransition(currentStatus, newState string) bool {
+	return slices.Contains(validTransitions[currentStatus], newState)
+}
+
+// Checks if the transition undoes an ACK that ack-service could not complete.
+// ack-service puts an alarm back to the status it had before a failed ACK, which
+// may be triggered, a transition the public API does not allow.
+func isRollbackTransition(currentStatus, newState string) bool {
+	return currentStatus == "ACK" && newState == "triggered"
 }
 
-// Update an alarm
-func (s *AlarmService) UpdateAlarm(alarm models.Alarm) error {
-	return s.store.UpdateAlarm(alarm)
+// applyChanges returns the alarm with the fields set in the changes replaced
+func applyChanges(alarm models.Alarm, changes models.UpdateAlarm, now time.Time) models.Alarm {
+	if changes.Name != "" {
+		alarm.Name = changes.Name
+	}
+	if !changes.Timestamp.IsZero() {
+		alarm.Timestamp = changes.Timestamp
+	}
+	if changes.Severity != "" {
+		alarm.Severity = changes.Severity
+	}
+	if changes.Priority != 0 {
+		alarm.Priority = changes.Priority
+	}
+	// Labels and annotations left out are kept, an empty object removes them
+	if changes.Labels != nil {
+		alarm.Labels = changes.Labels
+	}
+	if changes.Annotations != nil {
+		alarm.Annotations = changes.Annotations
+	}
+	alarm.Fingerprint = models.Fingerprint(alarm.Name, alarm.Labels)
+
+	if changes.Status != "" && changes.Status != alarm.Status {
+		alarm.Status = changes.Status
+		if alarm.Status == "resolved" {
+			alarm.Resolve(changes.ResolveReason, changes.ResolveNote, now)
+		}
+	}
+	alarm.UpdatedAt = now
+	return alarm
+}
+
+// Resolve an open alarm for the reason given in the resolution
+func (s *AlarmService) ResolveAlarm(id uuid.UUID, resolution models.ResolveAlarm, actor models.Actor) (*models.Alarm, error) {
+	return s.resolve(id, resolution.Reason, resolution.Note, actor, nil)
+}
+
+// ResolveQuietAlarms resolves the open alarms last seen before the time with
+// ResolveQuiet and returns how many it resolved
+func (s *AlarmService) ResolveQuietAlarms(before time.Time, actor models.Actor) (int, error) {
+	// An alarm seen again after it was listed is left open
+	quiet := func(alarm models.Alarm) bool { return alarm.LastSeenAt.Before(before) }
+
+	resolved := 0
+	for _, status := range models.OpenStatuses {
+		query := models.AlarmQuery{Status: status, LastSeenTo: before, SortBy: "created_at", Order: "asc", Limit: 100}
+		for {
+			page, err := s.store.ListAlarms(query)
+			if err != nil {
+				return resolved, err
+			}
+			for _, alarm := range page.Alarms {
+				_, err := s.resolve(alarm.ID, models.ResolveQuiet, "", actor, quiet)
+				switch {
+				case err == nil:
+					resolved++
+				case !errors.Is(err, ErrAlarmNotOpen):
+					return resolved, err
+				}
+			}
+			if page.Next == nil {
+				break
+			}
+			query.After = page.Next
+		}
+	}
+	return resolved, nil
+}
+
+// resolve resolves the alarm when it is open and meets the condition, if any
+func (s *AlarmService) resolve(id uuid.UUID, reason, note string, actor models.Actor, condition func(models.Alarm) bool) (*models.Alarm, error) {
+	var resolved *models.Alarm
+	err := s.change(func(tx storage.AlarmStorage) (*models.AlarmHistoryEntry, error) {
+		existing, err := tx.GetAlarm(id)
+		if err != nil {
+			return nil, err
+		}
+		if !slices.Contains(models.OpenStatuses, existing.Status) || condition != nil && !condition(*existing) {
+			return nil, ErrAlarmNotOpen
+		}
+
+		alarm := *existing
+		alarm.Resolve(reason, note, time.Now())
+		if err := tx.UpdateAlarm(alarm); err != nil {
+			return nil, err
+		}
+
+		// The store owns UpdatedAt, read back what was actually written
+		if resolved, err = tx.GetAlarm(id); err != nil {
+			resolved = &alarm
+		}
+		return newHistoryEntry(id, models.AlarmStatusChanged, actor, existing, resolved), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resolved, nil
+}
+
+// Get the change history of an alarm, oldest entry first
+func (s *AlarmService) GetAlarmHistory(id uuid.UUID) ([]models.AlarmHistoryEntry, error) {
+	return s.store.GetAlarmHistory(id)
+}
+
+// change runs an alarm write together with its history entry and outbox event
+// in one transaction, so an event is never lost or sent for a rolled back write.
+// The publisher is only woken once the transaction has committed.
+func (s *AlarmService) change(write func(tx storage.AlarmStorage) (*models.AlarmHistoryEntry, error)) error {
+	var entry *models.AlarmHistoryEntry
+	err := s.store.WithTransaction(func(tx storage.AlarmStorage) error {
+		var err error
+		if entry, err = write(tx); err != nil {
+			return err
+		}
+		if err := tx.AppendHistory(*entry); err != nil {
+			return err
+		}
+		return tx.EnqueueOutbox(*entry)
+	})
+	if err != nil {
+		return err
+	}
+
+	s.publisher.Publish(*entry)
+	return nil
+}
+
+// newHistoryEntry describes a change to the alarm, it doubles as the change event
+func newHistoryEntry(id uuid.UUID, action string, actor models.Actor, oldValue, newValue *models.Alarm) *models.AlarmHistoryEntry {
+	return &models.AlarmHistoryEntry{
+		ID:        uuid.New(),
+		AlarmID:   id,
+		Action:    action,
+		Actor:     actor.Name,
+		Source:    actor.Source,
+		OldValue:  oldValue,
+		NewValue:  newValue,
+		CreatedAt: time.Now(),
+	}
 }
@@ -2,9 +2,12 @@ package service
 
 import (
 	"errors"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/26christy/CarbonQuest/alarm-service/events"
+	"github.com/26christy/CarbonQuest/alarm-service/storage"
 	"github.com/26christy/CarbonQuest/common/models"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
@@ -37,6 +40,19 @@ func (m *MockAlarmStorage) GetAllAlarms() ([]models.Alarm, error) {
 	return nil, args.Error(1)
 }
 
+func (m *MockAlarmStorage) FindOpenAlarm(fingerprint string) (*models.Alarm, error) {
+	args := m.Called(fingerprint)
+	if alarm, ok := args.Get(0).(*models.Alarm); ok {
+		return alarm, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockAlarmStorage) ListAlarms(query models.AlarmQuery) (models.AlarmPage, error) {
+	args := m.Called(query)
+	return args.Get(0).(models.AlarmPage), args.Error(1)
+}
+
 func (m *MockAlarmStorage) DeleteAlarm(id uuid.UUID) error {
 	args := m.Called(id)
 	return args.Error(0)
@@ -47,19 +63,88 @@ func (m *MockAlarmStorage) UpdateAlarm(alarm models.Alarm) error {
 	return args.Error(0)
 }
 
+func (m *MockAlarmStorage) AppendHistory(entry models.AlarmHistoryEntry) error {
+	args := m.Called(entry)
+	return args.Error(0)
+}
+
+func (m *MockAlarmStorage) GetAlarmHistory(id uuid.UUID) ([]models.AlarmHistoryEntry, error) {
+	args := m.Called(id)
+	if entries, ok := args.Get(0).([]models.AlarmHistoryEntry); ok {
+		return entries, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockAlarmStorage) EnqueueOutbox(event models.AlarmHistoryEntry) error {
+	args := m.Called(event)
+	return args.Error(0)
+}
+
+func (m *MockAlarmStorage) GetOutboxAfter(seq int64, limit int) ([]models.OutboxEntry, error) {
+	args := m.Called(seq, limit)
+	if entries, ok := args.Get(0).([]models.OutboxEntry); ok {
+		return entries, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockAlarmStorage) PruneOutbox(seq int64) error {
+	args := m.Called(seq)
+	return args.Error(0)
+}
+
+func (m *MockAlarmStorage) GetOutboxCursor(subscriber string) (models.OutboxCursor, error) {
+	args := m.Called(subscriber)
+	return args.Get(0).(models.OutboxCursor), args.Error(1)
+}
+
+func (m *MockAlarmStorage) SaveOutboxCursor(cursor models.OutboxCursor) error {
+	args := m.Called(cursor)
+	return args.Error(0)
+}
+
+func (m *MockAlarmStorage) DeadLetterOutbox(deadLetter models.OutboxDeadLetter, cursor models.OutboxCursor) error {
+	args := m.Called(deadLetter, cursor)
+	return args.Error(0)
+}
+
+func (m *MockAlarmStorage) GetOutboxDeadLetters() ([]models.OutboxDeadLetter, error) {
+	args := m.Called()
+	if deadLetters, ok := args.Get(0).([]models.OutboxDeadLetter); ok {
+		return deadLetters, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+// WithTransaction runs fn directly against the mock, writes are checked through the expectations
+func (m *MockAlarmStorage) WithTransaction(fn func(tx storage.AlarmStorage) error) error {
+	return fn(m)
+}
+
+var testActor = models.Actor{Name: "alice", Source: "api"}
+
 // Mock Publisher
 type MockEventPublisher struct {
 	mock.Mock
 }
 
+func (m *MockEventPublisher) Publish(event models.AlarmHistoryEntry) {
+	m.Called(event)
+}
+
+func (m *MockEventPublisher) Subscribe(name, action string, handler events.Handler) {
+	m.Called(name, action, handler)
+}
+
 func createTestService() (*AlarmService, *MockAlarmStorage, *MockEventPublisher) {
 	mockStorage := new(MockAlarmStorage)
 	mockPublisher := new(MockEventPublisher)
 
 	// Ensure all methods of EventPublisher are mocked
-	mockPublisher.On("Subscribe", mock.Anything, mock.Anything).Return()
+	mockPublisher.On("Subscribe", mock.Anything, mock.Anything, mock.Anything).Return()
 
-	service := NewAlarmService(mockStorage)
+	service := NewAlarmService(mockStorage, mockPublisher)
 	return service, mockStorage, mockPublisher
 }
 
@@ -74,13 +159,76 @@ func TestCreateAlarm(t *testing.T) {
 	}
 
 	mockStorage.On("SaveAlarm", alarm).Return(nil).Once()
+	mockStorage.On("AppendHistory", mock.MatchedBy(func(entry models.AlarmHistoryEntry) bool {
+		return entry.AlarmID == alarm.ID &&
+			entry.Action == models.AlarmCreated &&
+			entry.Actor == "alice" &&
+			entry.Source == "api" &&
+			entry.OldValue == nil &&
+			entry.NewValue.Name == alarm.Name
+	})).Return(nil).Once()
+	mockStorage.On("EnqueueOutbox", mock.Anything).Return(nil).Once()
+
+	mockPublisher.On("Publish", mock.MatchedBy(func(event models.AlarmHistoryEntry) bool {
+		return event.AlarmID == alarm.ID && event.Action == models.AlarmCreated
+	})).Once()
+
+	saved, created, err := service.CreateAlarm(alarm, testActor)
+	assert.NoError(t, err)
+	assert.True(t, created)
+	assert.Equal(t, alarm, *saved)
+
+	mockStorage.AssertExpectations(t)
+	mockPublisher.AssertNumberOfCalls(t, "Publish", 1)
+}
+
+func TestCreateAlarm_Deduplicated(t *testing.T) {
+	service, mockStorage, mockPublisher := createTestService()
 
+	fingerprint := models.Fingerprint("Disk", map[string]string{"host": "db-1"})
+	existing := models.Alarm{
+		ID:          uuid.New(),
+		Name:        "Disk",
+		Status:      "ACK",
+		Severity:    models.SeverityCritical,
+		Fingerprint: fingerprint,
+		Occurrences: 2,
+		LastSeenAt:  time.Now().Add(-time.Hour),
+	}
+	alarm := models.Alarm{
+		ID:          uuid.New(),
+		Name:        "Disk",
+		Status:      "triggered",
+		Severity:    models.SeverityMajor,
+		Annotations: map[string]string{"summary": "95% full"},
+		Fingerprint: fingerprint,
+		Occurrences: 1,
+		LastSeenAt:  time.Now(),
+	}
+	occurred := existing
+	occurred.Occurrences = 3
+	occurred.LastSeenAt = alarm.LastSeenAt
+	occurred.Annotations = alarm.Annotations
+
+	mockStorage.On("FindOpenAlarm", fingerprint).Return(&existing, nil).Once()
+	mockStorage.On("UpdateAlarm", occurred).Return(nil).Once()
+	mockStorage.On("GetAlarm", existing.ID).Return(&occurred, nil).Once()
+	mockStorage.On("AppendHistory", mock.MatchedBy(func(entry models.AlarmHistoryEntry) bool {
+		return entry.AlarmID == existing.ID &&
+			entry.Action == models.AlarmDeduplicated &&
+			entry.OldValue.Occurrences == 2 &&
+			entry.NewValue.Occurrences == 3
+	})).Return(nil).Once()
+	mockStorage.On("EnqueueOutbox", mock.Anything).Return(nil).Once()
 	mockPublisher.On("Publish", mock.Anything).Once()
 
-	err := service.CreateAlarm(alarm)
+	saved, created, err := service.CreateAlarm(alarm, testActor)
 	assert.NoError(t, err)
+	assert.False(t, created)
+	assert.Equal(t, occurred, *saved, "the open alarm keeps its status and severity")
 
 	mockStorage.AssertExpectations(t)
+	mockStorage.AssertNotCalled(t, "SaveAlarm", mock.Anything)
 }
 
 func TestCreateAlarm_Error(t *testing.T) {
@@ -95,11 +243,12 @@ func TestCreateAlarm_Error(t *testing.T) {
 
 	mockStorage.On("SaveAlarm", alarm).Return(errors.New("storage error")).Once()
 
-	err := service.CreateAlarm(alarm)
+	_, _, err := service.CreateAlarm(alarm, testActor)
 	assert.Error(t, err)
 	assert.Equal(t, "storage error", err.Error())
 
-	// Ensure publisher is not called due to storage failure
+	// Ensure neither history nor publisher is touched due to storage failure
+	mockStorage.AssertNotCalled(t, "AppendHistory", mock.Anything)
 	mockPublisher.AssertNotCalled(t, "Publish", mock.Anything)
 	mockStorage.AssertExpectations(t)
 }
@@ -165,33 +314,389 @@ func TestGetAllAlarm(t *testing.T) {
 	mockStorage.AssertExpectations(t)
 }
 
-func TestDeleteAlarm(t *testing.T) {
+func TestListAlarms(t *testing.T) {
 	service, mockStorage, _ := createTestService()
 
+	query := models.AlarmQuery{Status: "triggered", Limit: 10}
+	expectedPage := models.AlarmPage{
+		Alarms: []models.Alarm{{ID: uuid.New(), Name: "Alarm 1", Status: "triggered"}},
+	}
+
+	mockStorage.On("ListAlarms", query).Return(expectedPage, nil).Once()
+
+	page, err := service.ListAlarms(query)
+	assert.NoError(t, err)
+	assert.Equal(t, expectedPage, page)
+
+	mockStorage.AssertExpectations(t)
+}
+
+func TestDeleteAlarm(t *testing.T) {
+	service, mockStorage, mockPublisher := createTestService()
+	mockPublisher.On("Publish", mock.Anything).Once()
+
 	alarmID := uuid.New()
+	existing := &models.Alarm{ID: alarmID, Name: "Doomed Alarm", Status: "active"}
 
+	mockStorage.On("GetAlarm", alarmID).Return(existing, nil).Once()
 	mockStorage.On("DeleteAlarm", alarmID).Return(nil).Once()
+	mockStorage.On("AppendHistory", mock.MatchedBy(func(entry models.AlarmHistoryEntry) bool {
+		return entry.Action == models.AlarmDeleted && entry.OldValue == existing && entry.NewValue == nil
+	})).Return(nil).Once()
+	mockStorage.On("EnqueueOutbox", mock.Anything).Return(nil).Once()
 
-	err := service.DeleteAlarm(alarmID)
+	err := service.DeleteAlarm(alarmID, testActor)
 	assert.NoError(t, err)
 
 	mockStorage.AssertExpectations(t)
 }
 
 func TestUpdateAlarm(t *testing.T) {
-	service, mockStorage, _ := createTestService()
+	service, mockStorage, mockPublisher := createTestService()
 
-	alarm := models.Alarm{
-		ID:        uuid.New(),
-		Name:      "Updated Alarm",
-		Timestamp: time.Now(),
-		Status:    "active",
+	existing := models.Alarm{
+		ID:          uuid.New(),
+		Name:        "Old Alarm",
+		Timestamp:   time.Now(),
+		Status:      "triggered",
+		Severity:    models.SeverityMinor,
+		Priority:    3,
+		Labels:      map[string]string{"team": "db"},
+		Occurrences: 4,
+	}
+	// Only the changed fields are replaced and the fingerprint follows the name
+	isUpdated := func(alarm models.Alarm) bool {
+		return alarm.Name == "Updated Alarm" && alarm.Status == "active" &&
+			alarm.Severity == models.SeverityMinor && alarm.Priority == 3 && alarm.Labels["team"] == "db" &&
+			alarm.Occurrences == 4 && alarm.Fingerprint == models.Fingerprint("Updated Alarm", alarm.Labels)
+	}
+
+	mockStorage.On("GetAlarm", existing.ID).Return(&existing, nil).Once()
+	mockStorage.On("UpdateAlarm", mock.MatchedBy(isUpdated)).Return(nil).Once()
+	mockStorage.On("GetAlarm", existing.ID).Return(nil, errors.New("read failed")).Once()
+	mockStorage.On("AppendHistory", mock.MatchedBy(func(entry models.AlarmHistoryEntry) bool {
+		return entry.Action == models.AlarmStatusChanged &&
+			entry.OldValue.Status == "triggered" &&
+			entry.NewValue.Status == "active"
+	})).Return(nil).Once()
+	mockStorage.On("EnqueueOutbox", mock.Anything).Return(nil).Once()
+
+	mockPublisher.On("Publish", mock.MatchedBy(func(event models.AlarmHistoryEntry) bool {
+		return event.Action == models.AlarmStatusChanged && event.NewValue.Status == "active"
+	})).Once()
+
+	updated, err := service.UpdateAlarm(existing.ID, models.UpdateAlarm{Name: "Updated Alarm", Status: "active"}, testActor, false)
+	assert.NoError(t, err)
+	assert.True(t, isUpdated(*updated))
+
+	mockStorage.AssertExpectations(t)
+	mockPublisher.AssertNumberOfCalls(t, "Publish", 1)
+}
+
+func TestUpdateAlarm_SameStatus(t *testing.T) {
+	service, mockStorage, mockPublisher := createTestService()
+	mockPublisher.On("Publish", mock.Anything).Once()
+
+	resolvedAt := time.Now().Add(-time.Hour)
+	existing := models.Alarm{
+		ID: uuid.New(), Name: "Old Name", Status: "resolved",
+		ResolvedAt: &resolvedAt, ResolveReason: models.ResolveDuplicate,
+	}
+
+	// The status it already has does not resolve the alarm again
+	mockStorage.On("GetAlarm", existing.ID).Return(&existing, nil).Once()
+	mockStorage.On("UpdateAlarm", mock.MatchedBy(func(alarm models.Alarm) bool {
+		return alarm.Name == "Renamed Alarm" && alarm.ResolvedAt.Equal(resolvedAt) && alarm.ResolveReason == models.ResolveDuplicate
+	})).Return(nil).Once()
+	mockStorage.On("GetAlarm", existing.ID).Return(&existing, nil).Once()
+	mockStorage.On("AppendHistory", mock.MatchedBy(func(entry models.AlarmHistoryEntry) bool {
+		return entry.Action == models.AlarmUpdated
+	})).Return(nil).Once()
+	mockStorage.On("EnqueueOutbox", mock.Anything).Return(nil).Once()
+
+	_, err := service.UpdateAlarm(existing.ID, models.UpdateAlarm{Name: "Renamed Alarm", Status: "resolved"}, testActor, false)
+	assert.NoError(t, err)
+
+	mockStorage.AssertExpectations(t)
+}
+
+func TestUpdateAlarm_Resolve(t *testing.T) {
+	service, mockStorage, mockPublisher := createTestService()
+	mockPublisher.On("Publish", mock.Anything).Once()
+
+	existing := models.Alarm{ID: uuid.New(), Name: "Disk", Status: "ACK"}
+	mockStorage.On("GetAlarm", existing.ID).Return(&existing, nil).Once()
+	mockStorage.On("UpdateAlarm", mock.MatchedBy(func(alarm models.Alarm) bool {
+		return alarm.Status == "resolved" && alarm.ResolvedAt != nil &&
+			alarm.ResolveReason == models.ResolveFalsePositive && alarm.ResolveNote == "probe misconfigured"
+	})).Return(nil).Once()
+	mockStorage.On("GetAlarm", existing.ID).Return(nil, errors.New("read failed")).Once()
+	mockStorage.On("AppendHistory", mock.Anything).Return(nil).Once()
+	mockStorage.On("EnqueueOutbox", mock.Anything).Return(nil).Once()
+
+	changes := models.UpdateAlarm{Status: "resolved", ResolveReason: "false_positive", ResolveNote: "probe misconfigured"}
+	_, err := service.UpdateAlarm(existing.ID, changes, testActor, false)
+	assert.NoError(t, err)
+
+	mockStorage.AssertExpectations(t)
+}
+
+func TestUpdateAlarm_KeepsOccurrences(t *testing.T) {
+	store := storage.NewMemoryStorage()
+	publisher := new(MockEventPublisher)
+	publisher.On("Publish", mock.Anything)
+	service := NewAlarmService(store, publisher)
+
+	alarm := models.Alarm{ID: uuid.New(), Name: "Disk", Status: "triggered", Occurrences: 1, LastSeenAt: time.Now().Add(-time.Hour)}
+	alarm.Fingerprint = models.Fingerprint(alarm.Name, nil)
+	_, _, err := service.CreateAlarm(alarm, testActor)
+	assert.NoError(t, err)
+
+	// An occurrence counted after the caller read the alarm is not overwritten by its update
+	repeat := alarm
+	repeat.ID, repeat.LastSeenAt = uuid.New(), time.Now()
+	_, created, err := service.CreateAlarm(repeat, testActor)
+	assert.NoError(t, err)
+	assert.False(t, created)
+
+	updated, err := service.UpdateAlarm(alarm.ID, models.UpdateAlarm{Severity: models.SeverityCritical}, testActor, false)
+	assert.NoError(t, err)
+	assert.Equal(t, models.SeverityCritical, updated.Severity)
+	assert.Equal(t, 2, updated.Occurrences)
+	assert.True(t, updated.LastSeenAt.Equal(repeat.LastSeenAt))
+}
+
+func TestUpdateAlarm_InvalidTransition(t *testing.T) {
+	service, mockStorage, mockPublisher := createTestService()
+
+	for _, tc := range []struct {
+		from, to      string
+		allowRollback bool
+	}{
+		{"resolved", "active", false},
+		{"closed", "resolved", false},
+		{"ACK", "triggered", false},
+		{"active", "triggered", true}, // only an ACK is rolled back
+	} {
+		existing := models.Alarm{ID: uuid.New(), Status: tc.from}
+		mockStorage.On("GetAlarm", existing.ID).Return(&existing, nil).Once()
+
+		_, err := service.UpdateAlarm(existing.ID, models.UpdateAlarm{Status: tc.to}, testActor, tc.allowRollback)
+		assert.ErrorIs(t, err, ErrInvalidTransition, "%s to %s", tc.from, tc.to)
+	}
+
+	mockStorage.AssertNotCalled(t, "UpdateAlarm", mock.Anything)
+	mockPublisher.AssertNotCalled(t, "Publish", mock.Anything)
+}
+
+func TestUpdateAlarm_Rollback(t *testing.T) {
+	service, mockStorage, mockPublisher := createTestService()
+	mockPublisher.On("Publish", mock.Anything).Once()
+
+	existing := models.Alarm{ID: uuid.New(), Status: "ACK"}
+	mockStorage.On("GetAlarm", existing.ID).Return(&existing, nil).Once()
+	mockStorage.On("UpdateAlarm", mock.MatchedBy(func(alarm models.Alarm) bool {
+		return alarm.Status == "triggered"
+	})).Return(nil).Once()
+	mockStorage.On("GetAlarm", existing.ID).Return(nil, errors.New("read failed")).Once()
+	mockStorage.On("AppendHistory", mock.Anything).Return(nil).Once()
+	mockStorage.On("EnqueueOutbox", mock.Anything).Return(nil).Once()
+
+	updated, err := service.UpdateAlarm(existing.ID, models.UpdateAlarm{Status: "triggered"}, testActor, true)
+	assert.NoError(t, err)
+	assert.Equal(t, "triggered", updated.Status)
+
+	mockStorage.AssertExpectations(t)
+}
+
+func TestUpdateAlarm_ConcurrentResolve(t *testing.T) {
+	store := storage.NewMemoryStorage()
+	publisher := new(MockEventPublisher)
+	publisher.On("Publish", mock.Anything)
+	service := NewAlarmService(store, publisher)
+
+	alarm := models.Alarm{ID: uuid.New(), Name: "Disk", Status: "ACK"}
+	assert.NoError(t, store.SaveAlarm(alarm))
+
+	// Updates flip the alarm between ACK and active while it is resolved
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			status := "active"
+			if i%2 == 0 {
+				status = "ACK"
+			}
+			_, err := service.UpdateAlarm(alarm.ID, models.UpdateAlarm{Status: status}, testActor, false)
+			if err != nil {
+				assert.ErrorIs(t, err, ErrInvalidTransition)
+			}
+		}()
 	}
+	_, err := service.ResolveAlarm(alarm.ID, models.ResolveAlarm{}, testActor)
+	assert.NoError(t, err)
+	wg.Wait()
+
+	stored, err := store.GetAlarm(alarm.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "resolved", stored.Status, "no update reopens the resolved alarm")
+
+	history, err := store.GetAlarmHistory(alarm.ID)
+	assert.NoError(t, err)
+	for _, entry := range history {
+		assert.NotEqual(t, "resolved", entry.OldValue.Status, "nothing changes a resolved alarm")
+	}
+}
+
+func TestIsValidStateTransition(t *testing.T) {
+	for _, tc := range []struct {
+		from, to string
+		valid    bool
+	}{
+		{"triggered", "ACK", true},
+		{"triggered", "resolved", true},
+		{"active", "resolved", true},
+		{"ACK", "resolved", true},
+		{"resolved", "closed", true},
+		{"triggered", "closed", false},
+		{"resolved", "active", false},
+		{"resolved", "resolved", false},
+		{"closed", "triggered", false},
+		{"closed", "resolved", false},
+	} {
+		assert.Equal(t, tc.valid, isValidStateTransition(tc.from, tc.to), "%s to %s", tc.from, tc.to)
+	}
+}
+
+func TestUpdateAlarm_NotFound(t *testing.T) {
+	service, mockStorage, mockPublisher := createTestService()
+
+	id := uuid.New()
+	mockStorage.On("GetAlarm", id).Return(nil, errors.New("alarm not found")).Once()
 
-	mockStorage.On("UpdateAlarm", alarm).Return(nil).Once()
+	_, err := service.UpdateAlarm(id, models.UpdateAlarm{Status: "active"}, testActor, false)
+	assert.Error(t, err)
+
+	mockStorage.AssertNotCalled(t, "UpdateAlarm", mock.Anything)
+	mockStorage.AssertNotCalled(t, "AppendHistory", mock.Anything)
+	mockPublisher.AssertNotCalled(t, "Publish", mock.Anything)
+}
+
+func TestResolveAlarm(t *testing.T) {
+	service, mockStorage, mockPublisher := createTestService()
+
+	existing := models.Alarm{ID: uuid.New(), Name: "Disk", Status: "ACK"}
+	mockStorage.On("GetAlarm", existing.ID).Return(&existing, nil).Once()
+	mockStorage.On("UpdateAlarm", mock.MatchedBy(func(alarm models.Alarm) bool {
+		return alarm.Status == "resolved" && alarm.ResolvedAt != nil &&
+			alarm.ResolveReason == models.ResolveWontFix && alarm.ResolveNote == "decommissioned"
+	})).Return(nil).Once()
+	mockStorage.On("GetAlarm", existing.ID).Return(nil, errors.New("read failed")).Once()
+	mockStorage.On("AppendHistory", mock.MatchedBy(func(entry models.AlarmHistoryEntry) bool {
+		return entry.Action == models.AlarmStatusChanged &&
+			entry.OldValue.Status == "ACK" &&
+			entry.NewValue.Status == "resolved"
+	})).Return(nil).Once()
+	mockStorage.On("EnqueueOutbox", mock.Anything).Return(nil).Once()
+	mockPublisher.On("Publish", mock.Anything).Once()
+
+	resolved, err := service.ResolveAlarm(existing.ID, models.ResolveAlarm{Reason: "wont_fix", Note: "decommissioned"}, testActor)
+	assert.NoError(t, err)
+	assert.Equal(t, "resolved", resolved.Status)
+	assert.Equal(t, models.ResolveWontFix, resolved.ResolveReason)
+
+	mockStorage.AssertExpectations(t)
+}
+
+func TestResolveAlarm_NotOpen(t *testing.T) {
+	service, mockStorage, mockPublisher := createTestService()
+
+	for _, status := range []string{"resolved", "closed"} {
+		existing := models.Alarm{ID: uuid.New(), Status: status}
+		mockStorage.On("GetAlarm", existing.ID).Return(&existing, nil).Once()
+
+		_, err := service.ResolveAlarm(existing.ID, models.ResolveAlarm{}, testActor)
+		assert.ErrorIs(t, err, ErrAlarmNotOpen, status)
+	}
+
+	mockStorage.AssertNotCalled(t, "UpdateAlarm", mock.Anything)
+	mockPublisher.AssertNotCalled(t, "Publish", mock.Anything)
+}
+
+func TestResolveQuietAlarms(t *testing.T) {
+	store := storage.NewMemoryStorage()
+	publisher := new(MockEventPublisher)
+	publisher.On("Publish", mock.Anything)
+	service := NewAlarmService(store, publisher)
+
+	now := time.Now()
+	alarm := func(name, status string, lastSeen time.Duration) models.Alarm {
+		alarm := models.Alarm{ID: uuid.New(), Name: name, Status: status, CreatedAt: now.Add(-2 * time.Hour), LastSeenAt: now.Add(-lastSeen)}
+		assert.NoError(t, store.SaveAlarm(alarm))
+		return alarm
+	}
+	quietTriggered := alarm("quiet triggered", "triggered", time.Hour)
+	quietACK := alarm("quiet ACK", "ACK", 90*time.Minute)
+	recent := alarm("recent", "active", time.Minute)
+	closed := alarm("closed", "closed", time.Hour)
+
+	resolved, err := service.ResolveQuietAlarms(now.Add(-30*time.Minute), autoResolveActor)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, resolved)
+
+	for _, tc := range []struct {
+		alarm  models.Alarm
+		status string
+	}{
+		{quietTriggered, "resolved"},
+		{quietACK, "resolved"},
+		{recent, "active"},
+		{closed, "closed"},
+	} {
+		stored, err := store.GetAlarm(tc.alarm.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, tc.status, stored.Status, tc.alarm.Name)
+	}
+
+	stored, _ := store.GetAlarm(quietACK.ID)
+	assert.Equal(t, models.ResolveQuiet, stored.ResolveReason)
+	history, _ := store.GetAlarmHistory(quietACK.ID)
+	assert.Len(t, history, 1)
+	assert.Equal(t, "auto-resolve", history[0].Actor)
+
+	// Nothing is left to resolve
+	resolved, err = service.ResolveQuietAlarms(now.Add(-30*time.Minute), autoResolveActor)
+	assert.NoError(t, err)
+	assert.Zero(t, resolved)
+}
+
+func TestCreateAlarm_OutboxError(t *testing.T) {
+	service, mockStorage, mockPublisher := createTestService()
+
+	alarm := models.Alarm{ID: uuid.New(), Name: "Unannounced Alarm", Status: "triggered"}
+
+	mockStorage.On("SaveAlarm", alarm).Return(nil).Once()
+	mockStorage.On("AppendHistory", mock.Anything).Return(nil).Once()
+	mockStorage.On("EnqueueOutbox", mock.Anything).Return(errors.New("outbox unavailable")).Once()
+
+	_, _, err := service.CreateAlarm(alarm, testActor)
+	assert.Error(t, err)
+
+	mockStorage.AssertExpectations(t)
+	mockPublisher.AssertNotCalled(t, "Publish", mock.Anything)
+}
+
+func TestGetAlarmHistory(t *testing.T) {
+	service, mockStorage, _ := createTestService()
+
+	alarmID := uuid.New()
+	expected := []models.AlarmHistoryEntry{{ID: uuid.New(), AlarmID: alarmID, Action: models.AlarmCreated}}
+	mockStorage.On("GetAlarmHistory", alarmID).Return(expected, nil).Once()
 
-	err := service.UpdateAlarm(alarm)
+	history, err := service.GetAlarmHistory(alarmID)
 	assert.NoError(t, err)
+	assert.Equal(t, expected, history)
 
 	mockStorage.AssertExpectations(t)
 }
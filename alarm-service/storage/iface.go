@@ -6,12 +6,35 @@ import (
 )
 
 // Storage interface for alarms.
-// Eventhough the data is stored in memory,
-// the design is kept in mind for mograting to a database in future
+// Implemented in memory (MemoryStorage) and on disk (SQLiteStorage),
+// select one with CreateStorage
 type AlarmStorage interface {
 	SaveAlarm(alarm models.Alarm) error
 	GetAlarm(id uuid.UUID) (*models.Alarm, error)
 	GetAllAlarms() ([]models.Alarm, error)
+	// FindOpenAlarm returns the latest created open alarm with the fingerprint, or nil when there is none
+	FindOpenAlarm(fingerprint string) (*models.Alarm, error)
+	// ListAlarms returns one page of alarms matching the query, in a stable
+	// order: by the sort key and then by ID when sort keys are equal.
+	ListAlarms(query models.AlarmQuery) (models.AlarmPage, error)
 	DeleteAlarm(id uuid.UUID) error
 	UpdateAlarm(alarm models.Alarm) error
+	// History is append-only and outlives the alarm it describes
+	AppendHistory(entry models.AlarmHistoryEntry) error
+	GetAlarmHistory(id uuid.UUID) ([]models.AlarmHistoryEntry, error)
+	// Outbox of change events, written in the same transaction as the change
+	// and drained by the relay worker. Entries come back oldest first.
+	EnqueueOutbox(event models.AlarmHistoryEntry) error
+	GetOutboxAfter(seq int64, limit int) ([]models.OutboxEntry, error)
+	// PruneOutbox deletes the entries up to seq, once every subscriber has passed them
+	PruneOutbox(seq int64) error
+	// GetOutboxCursor returns a zero cursor for a subscriber that has none yet
+	GetOutboxCursor(subscriber string) (models.OutboxCursor, error)
+	SaveOutboxCursor(cursor models.OutboxCursor) error
+	// DeadLetterOutbox keeps the event and moves the cursor past it together
+	DeadLetterOutbox(deadLetter models.OutboxDeadLetter, cursor models.OutboxCursor) error
+	GetOutboxDeadLetters() ([]models.OutboxDeadLetter, error)
+	// WithTransaction runs fn against a storage whose writes are all
+	// applied together, or not at all when fn returns an error
+	WithTransaction(fn func(tx AlarmStorage) error) error
 }
@@ -2,6 +2,7 @@ package storage
 
 import (
 	"errors"
+	"slices"
 	"sync"
 	"time"
 
@@ -10,14 +11,24 @@ import (
 )
 
 type MemoryStorage struct {
-	mu     sync.RWMutex
-	alarms map[string]models.Alarm
+	mu      sync.RWMutex
+	alarms  map[string]models.Alarm
+	history map[string][]models.AlarmHistoryEntry
+	outbox  []models.OutboxEntry
+	// outboxSeq is the seq of the last entry added to the outbox
+	outboxSeq         int64
+	outboxCursors     map[string]models.OutboxCursor
+	outboxDeadLetters []models.OutboxDeadLetter
+	// txMu serializes transactions
+	txMu sync.Mutex
 }
 
 // constructor
 func NewMemoryStorage() *MemoryStorage {
 	return &MemoryStorage{
-		alarms: make(map[string]models.Alarm),
+		alarms:        make(map[string]models.Alarm),
+		history:       make(map[string][]models.AlarmHistoryEntry),
+		outboxCursors: make(map[string]models.OutboxCursor),
 	}
 }
 
@@ -51,6 +62,38 @@ func (m *MemoryStorage) GetAllAlarms() ([]models.Alarm, error) {
 	return result, nil
 }
 
+// Find the open alarm with a fingerprint
+func (m *MemoryStorage) FindOpenAlarm(fingerprint string) (*models.Alarm, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var found *models.Alarm
+	for _, alarm := range m.alarms {
+		if alarm.Fingerprint != fingerprint || !slices.Contains(models.OpenStatuses, alarm.Status) {
+			continue
+		}
+		if found == nil || alarm.CreatedAt.After(found.CreatedAt) {
+			found = &alarm
+		}
+	}
+	return found, nil
+}
+
+// List alarms matching the query
+func (m *MemoryStorage) ListAlarms(query models.AlarmQuery) (models.AlarmPage, error) {
+	query = applyQueryDefaults(query)
+
+	m.mu.RLock()
+	result := []models.Alarm{}
+	for _, alarm := range m.alarms {
+		if matchesQuery(alarm, query) {
+			result = append(result, alarm)
+		}
+	}
+	m.mu.RUnlock()
+
+	return paginate(result, query), nil
+}
+
 // Delete an alarm
 func (m *MemoryStorage) DeleteAlarm(id uuid.UUID) error {
 	m.mu.Lock()
@@ -81,3 +124,192 @@ func (m *MemoryStorage) UpdateAlarm(alarm models.Alarm) error {
 	m.alarms[alarm.ID.String()] = alarm
 	return nil
 }
+
+// Append a history entry
+func (m *MemoryStorage) AppendHistory(entry models.AlarmHistoryEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.history[entry.AlarmID.String()] = append(m.history[entry.AlarmID.String()], entry)
+	return nil
+}
+
+// Get the history of an alarm, oldest entry first
+func (m *MemoryStorage) GetAlarmHistory(id uuid.UUID) ([]models.AlarmHistoryEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	entries := m.history[id.String()]
+	result := make([]models.AlarmHistoryEntry, len(entries))
+	copy(result, entries)
+	return result, nil
+}
+
+// Add an event to the outbox
+func (m *MemoryStorage) EnqueueOutbox(event models.AlarmHistoryEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.outboxSeq++
+	m.outbox = append(m.outbox, models.OutboxEntry{Seq: m.outboxSeq, Event: event})
+	return nil
+}
+
+// Get the outbox entries after seq, oldest first
+func (m *MemoryStorage) GetOutboxAfter(seq int64, limit int) ([]models.OutboxEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	result := []models.OutboxEntry{}
+	for _, entry := range m.outbox {
+		if limit > 0 && len(result) == limit {
+			break
+		}
+		if entry.Seq > seq {
+			result = append(result, entry)
+		}
+	}
+	return result, nil
+}
+
+// Delete the outbox entries up to seq
+func (m *MemoryStorage) PruneOutbox(seq int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.outbox = slices.DeleteFunc(m.outbox, func(entry models.OutboxEntry) bool {
+		return entry.Seq <= seq
+	})
+	return nil
+}
+
+// Get the position of a subscriber in the outbox
+func (m *MemoryStorage) GetOutboxCursor(subscriber string) (models.OutboxCursor, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	cursor, exists := m.outboxCursors[subscriber]
+	if !exists {
+		cursor.Subscriber = subscriber
+	}
+	return cursor, nil
+}
+
+// Save the position of a subscriber in the outbox
+func (m *MemoryStorage) SaveOutboxCursor(cursor models.OutboxCursor) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.outboxCursors[cursor.Subscriber] = cursor
+	return nil
+}
+
+// Keep an event a subscriber did not take and move its cursor past it
+func (m *MemoryStorage) DeadLetterOutbox(deadLetter models.OutboxDeadLetter, cursor models.OutboxCursor) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.outboxDeadLetters = append(m.outboxDeadLetters, deadLetter)
+	m.outboxCursors[cursor.Subscriber] = cursor
+	return nil
+}
+
+// Get the outbox dead letters, oldest first
+func (m *MemoryStorage) GetOutboxDeadLetters() ([]models.OutboxDeadLetter, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	result := make([]models.OutboxDeadLetter, len(m.outboxDeadLetters))
+	copy(result, m.outboxDeadLetters)
+	return result, nil
+}
+
+// removeOutbox must be called with the write lock held
+func (m *MemoryStorage) removeOutbox(eventID uuid.UUID) {
+	for i := range m.outbox {
+		if m.outbox[i].Event.ID == eventID {
+			m.outbox = append(m.outbox[:i], m.outbox[i+1:]...)
+			return
+		}
+	}
+}
+
+// Run fn as a transaction. Writes are visible to readers as they happen,
+// but are rolled back when fn returns an error.
+func (m *MemoryStorage) WithTransaction(fn func(tx AlarmStorage) error) error {
+	m.txMu.Lock()
+	defer m.txMu.Unlock()
+
+	tx := &memoryTx{MemoryStorage: m}
+	if err := fn(tx); err != nil {
+		tx.rollback()
+		return err
+	}
+	return nil
+}
+
+// memoryTx records how to undo every write so a failed transaction can be rolled back
+type memoryTx struct {
+	*MemoryStorage
+	undo []func()
+}
+
+func (t *memoryTx) SaveAlarm(alarm models.Alarm) error {
+	t.rememberAlarm(alarm.ID)
+	return t.MemoryStorage.SaveAlarm(alarm)
+}
+
+func (t *memoryTx) UpdateAlarm(alarm models.Alarm) error {
+	t.rememberAlarm(alarm.ID)
+	return t.MemoryStorage.UpdateAlarm(alarm)
+}
+
+func (t *memoryTx) DeleteAlarm(id uuid.UUID) error {
+	t.rememberAlarm(id)
+	return t.MemoryStorage.DeleteAlarm(id)
+}
+
+func (t *memoryTx) AppendHistory(entry models.AlarmHistoryEntry) error {
+	key := entry.AlarmID.String()
+	t.undo = append(t.undo, func() {
+		entries := t.history[key]
+		for i := range entries {
+			if entries[i].ID == entry.ID {
+				t.history[key] = append(entries[:i], entries[i+1:]...)
+				break
+			}
+		}
+		if len(t.history[key]) == 0 {
+			delete(t.history, key)
+		}
+	})
+	return t.MemoryStorage.AppendHistory(entry)
+}
+
+func (t *memoryTx) EnqueueOutbox(event models.AlarmHistoryEntry) error {
+	t.undo = append(t.undo, func() {
+		t.removeOutbox(event.ID)
+	})
+	return t.MemoryStorage.EnqueueOutbox(event)
+}
+
+// Nested transactions join the outer one
+func (t *memoryTx) WithTransaction(fn func(tx AlarmStorage) error) error {
+	return fn(t)
+}
+
+// rememberAlarm records the current value of an alarm, or its absence
+func (t *memoryTx) rememberAlarm(id uuid.UUID) {
+	key := id.String()
+	t.mu.RLock()
+	previous, existed := t.alarms[key]
+	t.mu.RUnlock()
+
+	t.undo = append(t.undo, func() {
+		if existed {
+			t.alarms[key] = previous
+		} else {
+			delete(t.alarms, key)
+		}
+	})
+}
+
+// rollback applies the undo log in reverse order
+func (t *memoryTx) rollback() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i := len(t.undo) - 1; i >= 0; i-- {
+		t.undo[i]()
+	}
+}
@@ -1,16 +1,22 @@
 package storage
 
 import (
+	"errors"
 	"testing"
 	"time"
 
 	"github.com/26christy/CarbonQuest/common/models"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestMemoryStorage(t *testing.T) {
-	storage := NewMemoryStorage()
+	testAlarmStorage(t, NewMemoryStorage())
+}
+
+// testAlarmStorage is the contract every AlarmStorage implementation must satisfy
+func testAlarmStorage(t *testing.T, storage AlarmStorage) {
 
 	// Sample alarm for testing
 	alarm := models.Alarm{
@@ -18,6 +24,9 @@ func TestMemoryStorage(t *testing.T) {
 		Name:      "Test Alarm",
 		Timestamp: time.Now(),
 		Status:    "active",
+		Severity:  models.SeverityMinor,
+		Priority:  3,
+		Labels:    map[string]string{"team": "db", "region": "eu-west"},
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 	}
@@ -31,6 +40,10 @@ func TestMemoryStorage(t *testing.T) {
 	assert.NoError(t, err, "GetAlarm should not return an error for existing alarm")
 	assert.Equal(t, alarm.ID, retrievedAlarm.ID, "Retrieved alarm ID should match the saved one")
 	assert.Equal(t, alarm.Name, retrievedAlarm.Name, "Retrieved alarm Name should match the saved one")
+	assert.Equal(t, models.SeverityMinor, retrievedAlarm.Severity)
+	assert.Equal(t, 3, retrievedAlarm.Priority)
+	assert.Equal(t, alarm.Labels, retrievedAlarm.Labels)
+	assert.Nil(t, retrievedAlarm.Annotations)
 
 	// Test GetAlarm - failure (non-existent alarm)
 	_, err = storage.GetAlarm(uuid.New())
@@ -45,11 +58,32 @@ func TestMemoryStorage(t *testing.T) {
 	// Test UpdateAlarm - success
 	updatedAlarm := alarm
 	updatedAlarm.Name = "Updated Alarm"
+	updatedAlarm.Severity = models.SeverityCritical
+	updatedAlarm.Priority = 1
+	updatedAlarm.Labels = map[string]string{"team": "web"}
+	updatedAlarm.Annotations = map[string]string{"runbook_url": "https://runbooks.example.com/test"}
 	err = storage.UpdateAlarm(updatedAlarm)
 	assert.NoError(t, err, "UpdateAlarm should not return an error")
 
 	retrievedUpdatedAlarm, _ := storage.GetAlarm(alarm.ID)
 	assert.Equal(t, "Updated Alarm", retrievedUpdatedAlarm.Name, "Alarm name should be updated")
+	assert.Equal(t, models.SeverityCritical, retrievedUpdatedAlarm.Severity, "Alarm severity should be updated")
+	assert.Equal(t, 1, retrievedUpdatedAlarm.Priority, "Alarm priority should be updated")
+	assert.Equal(t, updatedAlarm.Labels, retrievedUpdatedAlarm.Labels, "Alarm labels should be replaced")
+	assert.Equal(t, updatedAlarm.Annotations, retrievedUpdatedAlarm.Annotations, "Alarm annotations should be updated")
+	assert.Nil(t, retrievedUpdatedAlarm.ResolvedAt, "an open alarm has no resolve time")
+
+	// Test UpdateAlarm - resolving keeps the time, reason and note
+	resolvedAlarm := updatedAlarm
+	resolvedAlarm.Resolve(models.ResolveFalsePositive, "flapping probe", time.Date(2025, 3, 18, 11, 0, 0, 0, time.UTC))
+	assert.NoError(t, storage.UpdateAlarm(resolvedAlarm))
+	retrievedResolvedAlarm, _ := storage.GetAlarm(alarm.ID)
+	assert.Equal(t, "resolved", retrievedResolvedAlarm.Status)
+	if assert.NotNil(t, retrievedResolvedAlarm.ResolvedAt) {
+		assert.True(t, resolvedAlarm.ResolvedAt.Equal(*retrievedResolvedAlarm.ResolvedAt))
+	}
+	assert.Equal(t, models.ResolveFalsePositive, retrievedResolvedAlarm.ResolveReason)
+	assert.Equal(t, "flapping probe", retrievedResolvedAlarm.ResolveNote)
 	assert.NotEqual(t, alarm.UpdatedAt, retrievedUpdatedAlarm.UpdatedAt, "UpdatedAt should be modified")
 
 	// Test UpdateAlarm - failure (non-existent alarm)
@@ -75,3 +109,354 @@ func TestMemoryStorage(t *testing.T) {
 	assert.Error(t, err, "DeleteAlarm should return an error for non-existent alarm")
 	assert.Equal(t, "alarm not found", err.Error())
 }
+
+func TestMemoryStorage_ListAlarms(t *testing.T) {
+	testListAlarms(t, NewMemoryStorage())
+}
+
+// testListAlarms is the filtering and paging contract for AlarmStorage implementations
+func testListAlarms(t *testing.T, storage AlarmStorage) {
+	base := time.Date(2025, 3, 18, 10, 0, 0, 0, time.UTC)
+	fixtures := []struct {
+		name     string
+		status   string
+		severity string
+		priority int
+		labels   map[string]string
+		offset   time.Duration
+	}{
+		{"db-disk-full", "triggered", models.SeverityWarning, 4, map[string]string{"team": "db", "region": "eu-west"}, 0},
+		{"db-replica-lag", "active", models.SeverityMajor, 2, map[string]string{"team": "db", "region": "us-east"}, time.Minute},
+		{"web-latency", "ACK", models.SeverityMinor, 3, map[string]string{"team": "web", "region": "eu-west"}, 2 * time.Minute},
+		{"web-5xx", "triggered", models.SeverityCritical, 1, map[string]string{"team": "web", "app.kubernetes.io/name": "frontend"}, 3 * time.Minute},
+		// same created_at as the previous alarm, ordering falls back to ID
+		{"DB-connections", "triggered", models.SeverityMajor, 2, nil, 3 * time.Minute},
+	}
+	for _, f := range fixtures {
+		err := storage.SaveAlarm(models.Alarm{
+			ID:         uuid.New(),
+			Name:       f.name,
+			Timestamp:  base.Add(f.offset),
+			Status:     f.status,
+			Severity:   f.severity,
+			Priority:   f.priority,
+			Labels:     f.labels,
+			LastSeenAt: base.Add(2 * f.offset),
+			CreatedAt:  base.Add(f.offset),
+			UpdatedAt:  base.Add(f.offset),
+		})
+		assert.NoError(t, err)
+	}
+
+	// No limit returns everything, oldest first
+	page, err := storage.ListAlarms(models.AlarmQuery{})
+	assert.NoError(t, err)
+	assert.Len(t, page.Alarms, 5)
+	assert.Nil(t, page.Next, "there should be no next page without a limit")
+	assert.Equal(t, "db-disk-full", page.Alarms[0].Name)
+
+	// Walking the pages returns every alarm exactly once, in the same order
+	for _, query := range []models.AlarmQuery{{Order: "asc"}, {Order: "desc"}, {SortBy: "priority", Order: "asc"}} {
+		order := query.Order
+		var walked []models.Alarm
+		query.Limit = 2
+		for {
+			page, err := storage.ListAlarms(query)
+			assert.NoError(t, err)
+			assert.LessOrEqual(t, len(page.Alarms), 2)
+			walked = append(walked, page.Alarms...)
+			if page.Next == nil {
+				break
+			}
+			query.After = page.Next
+		}
+
+		full, err := storage.ListAlarms(models.AlarmQuery{SortBy: query.SortBy, Order: order})
+		assert.NoError(t, err)
+		assert.Equal(t, len(full.Alarms), len(walked))
+		for i := range walked {
+			assert.Equal(t, full.Alarms[i].ID, walked[i].ID, "page walk should match the full list (%s)", order)
+		}
+	}
+
+	// Filters
+	page, err = storage.ListAlarms(models.AlarmQuery{Status: "triggered"})
+	assert.NoError(t, err)
+	assert.Len(t, page.Alarms, 3)
+
+	page, err = storage.ListAlarms(models.AlarmQuery{Severities: []string{models.SeverityCritical, models.SeverityMajor}})
+	assert.NoError(t, err)
+	assert.Len(t, page.Alarms, 3, "an alarm should match any of the severities")
+
+	page, err = storage.ListAlarms(models.AlarmQuery{Priorities: []int{3, 4}, Status: "triggered"})
+	assert.NoError(t, err)
+	if assert.Len(t, page.Alarms, 1) {
+		assert.Equal(t, "db-disk-full", page.Alarms[0].Name)
+	}
+
+	labelTests := []struct {
+		selectors []string
+		expected  int
+	}{
+		{[]string{"team=db"}, 2},
+		{[]string{"team=db", "region=eu-west"}, 1},
+		{[]string{"region!=eu-west"}, 3},
+		{[]string{"region"}, 3},
+		{[]string{"!region"}, 2},
+		{[]string{"app.kubernetes.io/name=frontend"}, 1},
+		{[]string{"team=ops"}, 0},
+	}
+	for _, tt := range labelTests {
+		query := models.AlarmQuery{}
+		for _, label := range tt.selectors {
+			selector, err := models.ParseLabelSelector(label)
+			assert.NoError(t, err)
+			query.LabelSelectors = append(query.LabelSelectors, selector)
+		}
+		page, err = storage.ListAlarms(query)
+		assert.NoError(t, err)
+		assert.Len(t, page.Alarms, tt.expected, "label selectors %v", tt.selectors)
+	}
+
+	page, err = storage.ListAlarms(models.AlarmQuery{Name: "db-"})
+	assert.NoError(t, err)
+	assert.Len(t, page.Alarms, 3, "name filter should be a case insensitive substring match")
+
+	page, err = storage.ListAlarms(models.AlarmQuery{
+		TimestampFrom: base.Add(time.Minute),
+		TimestampTo:   base.Add(3 * time.Minute),
+	})
+	assert.NoError(t, err)
+	assert.Len(t, page.Alarms, 2, "timestamp range should include from and exclude to")
+
+	page, err = storage.ListAlarms(models.AlarmQuery{CreatedFrom: base.Add(3 * time.Minute)})
+	assert.NoError(t, err)
+	assert.Len(t, page.Alarms, 2)
+
+	page, err = storage.ListAlarms(models.AlarmQuery{LastSeenFrom: base.Add(2 * time.Minute), LastSeenTo: base.Add(6 * time.Minute)})
+	assert.NoError(t, err)
+	assert.Len(t, page.Alarms, 2, "last seen range should include from and exclude to")
+
+	// Sorting
+	page, err = storage.ListAlarms(models.AlarmQuery{SortBy: "name", Order: "asc"})
+	assert.NoError(t, err)
+	assert.Equal(t, "DB-connections", page.Alarms[0].Name)
+	assert.Equal(t, "web-latency", page.Alarms[4].Name)
+
+	page, err = storage.ListAlarms(models.AlarmQuery{SortBy: "priority", Order: "asc"})
+	assert.NoError(t, err)
+	assert.Equal(t, "web-5xx", page.Alarms[0].Name)
+	assert.Equal(t, "db-disk-full", page.Alarms[4].Name)
+}
+
+func TestMemoryStorage_FindOpenAlarm(t *testing.T) {
+	testFindOpenAlarm(t, NewMemoryStorage())
+}
+
+// testFindOpenAlarm is the deduplication contract for AlarmStorage implementations
+func testFindOpenAlarm(t *testing.T, storage AlarmStorage) {
+	base := time.Date(2025, 3, 18, 10, 0, 0, 0, time.UTC)
+	fingerprint := models.Fingerprint("db-disk-full", map[string]string{"host": "db-1"})
+
+	found, err := storage.FindOpenAlarm(fingerprint)
+	assert.NoError(t, err)
+	assert.Nil(t, found, "there is no alarm with the fingerprint yet")
+
+	older := models.Alarm{
+		ID: uuid.New(), Name: "db-disk-full", Status: "ACK", Fingerprint: fingerprint,
+		Occurrences: 3, LastSeenAt: base.Add(time.Hour), Timestamp: base, CreatedAt: base, UpdatedAt: base,
+	}
+	newer := models.Alarm{
+		ID: uuid.New(), Name: "db-disk-full", Status: "active", Fingerprint: fingerprint,
+		Occurrences: 1, LastSeenAt: base.Add(time.Minute), Timestamp: base, CreatedAt: base.Add(time.Minute), UpdatedAt: base,
+	}
+	other := models.Alarm{
+		ID: uuid.New(), Name: "db-disk-full", Status: "triggered", Fingerprint: models.Fingerprint("db-disk-full", nil),
+		Occurrences: 1, Timestamp: base, CreatedAt: base.Add(2 * time.Minute), UpdatedAt: base,
+	}
+	for _, alarm := range []models.Alarm{older, newer, other} {
+		assert.NoError(t, storage.SaveAlarm(alarm))
+	}
+
+	found, err = storage.FindOpenAlarm(fingerprint)
+	assert.NoError(t, err)
+	if assert.NotNil(t, found) {
+		assert.Equal(t, newer.ID, found.ID, "the latest open alarm with the fingerprint is found")
+		assert.Equal(t, 1, found.Occurrences)
+		assert.True(t, newer.LastSeenAt.Equal(found.LastSeenAt))
+	}
+
+	// Counting an occurrence is an update
+	occurred := *found
+	occurred.Occurrences = 2
+	occurred.LastSeenAt = base.Add(5 * time.Minute)
+	assert.NoError(t, storage.UpdateAlarm(occurred))
+	found, err = storage.FindOpenAlarm(fingerprint)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, found.Occurrences)
+	assert.True(t, occurred.LastSeenAt.Equal(found.LastSeenAt))
+
+	// A resolved alarm is no longer open, the older one is found instead
+	occurred.Resolve(models.ResolveFixed, "", base.Add(10*time.Minute))
+	assert.NoError(t, storage.UpdateAlarm(occurred))
+	found, err = storage.FindOpenAlarm(fingerprint)
+	assert.NoError(t, err)
+	if assert.NotNil(t, found) {
+		assert.Equal(t, older.ID, found.ID)
+	}
+}
+
+func TestMemoryStorage_History(t *testing.T) {
+	testAlarmHistory(t, NewMemoryStorage())
+}
+
+// testAlarmHistory is the history contract for AlarmStorage implementations
+func testAlarmHistory(t *testing.T, storage AlarmStorage) {
+	alarmID := uuid.New()
+	created := models.Alarm{ID: alarmID, Name: "Test Alarm", Status: "triggered", Timestamp: time.Now()}
+	acked := created
+	acked.Status = "ACK"
+
+	entries := []models.AlarmHistoryEntry{
+		{ID: uuid.New(), AlarmID: alarmID, Action: models.AlarmCreated, Actor: "alice", Source: "api", NewValue: &created, CreatedAt: time.Now()},
+		{ID: uuid.New(), AlarmID: alarmID, Action: models.AlarmStatusChanged, Actor: "ack-service", Source: "ack-service", OldValue: &created, NewValue: &acked, CreatedAt: time.Now()},
+		{ID: uuid.New(), AlarmID: alarmID, Action: models.AlarmDeleted, Actor: "alice", Source: "api", OldValue: &acked, CreatedAt: time.Now()},
+	}
+	for _, entry := range entries {
+		assert.NoError(t, storage.AppendHistory(entry))
+	}
+	// Entries of other alarms must not leak in
+	assert.NoError(t, storage.AppendHistory(models.AlarmHistoryEntry{ID: uuid.New(), AlarmID: uuid.New(), Action: models.AlarmCreated, CreatedAt: time.Now()}))
+
+	history, err := storage.GetAlarmHistory(alarmID)
+	assert.NoError(t, err)
+	assert.Len(t, history, 3)
+	for i, entry := range history {
+		assert.Equal(t, entries[i].ID, entry.ID, "history should be returned oldest first")
+		assert.Equal(t, entries[i].Action, entry.Action)
+		assert.Equal(t, entries[i].Actor, entry.Actor)
+	}
+	assert.Nil(t, history[0].OldValue)
+	assert.Equal(t, "triggered", history[1].OldValue.Status)
+	assert.Equal(t, "ACK", history[1].NewValue.Status)
+	assert.Nil(t, history[2].NewValue)
+
+	history, err = storage.GetAlarmHistory(uuid.New())
+	assert.NoError(t, err)
+	assert.Empty(t, history)
+}
+
+func TestMemoryStorage_Outbox(t *testing.T) {
+	testOutbox(t, NewMemoryStorage())
+}
+
+func TestMemoryStorage_Transaction(t *testing.T) {
+	testTransaction(t, NewMemoryStorage())
+}
+
+// testOutbox is the outbox contract for AlarmStorage implementations
+func testOutbox(t *testing.T, storage AlarmStorage) {
+	first := models.AlarmHistoryEntry{ID: uuid.New(), AlarmID: uuid.New(), Action: models.AlarmCreated, CreatedAt: time.Now()}
+	second := models.AlarmHistoryEntry{ID: uuid.New(), AlarmID: uuid.New(), Action: models.AlarmDeleted, CreatedAt: time.Now()}
+	assert.NoError(t, storage.EnqueueOutbox(first))
+	assert.NoError(t, storage.EnqueueOutbox(second))
+
+	entries, err := storage.GetOutboxAfter(0, 10)
+	assert.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, first.ID, entries[0].Event.ID, "entries should come back oldest first")
+	assert.Equal(t, models.AlarmDeleted, entries[1].Event.Action)
+	assert.Less(t, entries[0].Seq, entries[1].Seq)
+
+	after, err := storage.GetOutboxAfter(entries[0].Seq, 10)
+	assert.NoError(t, err)
+	if assert.Len(t, after, 1) {
+		assert.Equal(t, second.ID, after[0].Event.ID)
+	}
+	limited, err := storage.GetOutboxAfter(0, 1)
+	assert.NoError(t, err)
+	assert.Len(t, limited, 1)
+
+	// A subscriber without a cursor starts at the beginning
+	cursor, err := storage.GetOutboxCursor("http://subscriber")
+	assert.NoError(t, err)
+	assert.Equal(t, models.OutboxCursor{Subscriber: "http://subscriber"}, cursor)
+
+	retryAt := time.Now().Add(time.Minute).UTC().Truncate(time.Millisecond)
+	failing := models.OutboxCursor{Subscriber: "http://subscriber", Seq: entries[0].Seq, Attempts: 2, NextAttemptAt: retryAt, LastError: "connection refused"}
+	assert.NoError(t, storage.SaveOutboxCursor(failing))
+	cursor, err = storage.GetOutboxCursor("http://subscriber")
+	assert.NoError(t, err)
+	assert.True(t, retryAt.Equal(cursor.NextAttemptAt))
+	cursor.NextAttemptAt = retryAt
+	assert.Equal(t, failing, cursor)
+
+	// Dead-lettering moves the cursor past the event
+	passed := models.OutboxCursor{Subscriber: "http://subscriber", Seq: entries[1].Seq}
+	deadLetter := models.OutboxDeadLetter{Subscriber: "http://subscriber", Event: second, Attempts: 3, LastError: "connection refused", FailedAt: time.Now()}
+	assert.NoError(t, storage.DeadLetterOutbox(deadLetter, passed))
+	cursor, _ = storage.GetOutboxCursor("http://subscriber")
+	assert.Equal(t, entries[1].Seq, cursor.Seq)
+	deadLetters, err := storage.GetOutboxDeadLetters()
+	assert.NoError(t, err)
+	if assert.Len(t, deadLetters, 1) {
+		assert.Equal(t, second.ID, deadLetters[0].Event.ID)
+		assert.Equal(t, 3, deadLetters[0].Attempts)
+	}
+
+	assert.NoError(t, storage.PruneOutbox(entries[0].Seq))
+	remaining, err := storage.GetOutboxAfter(0, 10)
+	assert.NoError(t, err)
+	if assert.Len(t, remaining, 1) {
+		assert.Equal(t, entries[1].Seq, remaining[0].Seq)
+	}
+}
+
+// testTransaction checks that writes inside a failed transaction are rolled back
+func testTransaction(t *testing.T, storage AlarmStorage) {
+	kept := models.Alarm{ID: uuid.New(), Name: "Kept Alarm", Status: "triggered", Timestamp: time.Now(), CreatedAt: time.Now(), UpdatedAt: time.Now()}
+	assert.NoError(t, storage.SaveAlarm(kept))
+
+	created := models.Alarm{ID: uuid.New(), Name: "Rolled Back", Status: "triggered", Timestamp: time.Now(), CreatedAt: time.Now(), UpdatedAt: time.Now()}
+	event := models.AlarmHistoryEntry{ID: uuid.New(), AlarmID: created.ID, Action: models.AlarmCreated, NewValue: &created, CreatedAt: time.Now()}
+
+	err := storage.WithTransaction(func(tx AlarmStorage) error {
+		assert.NoError(t, tx.SaveAlarm(created))
+		renamed := kept
+		renamed.Name = "Renamed"
+		assert.NoError(t, tx.UpdateAlarm(renamed))
+		assert.NoError(t, tx.AppendHistory(event))
+		assert.NoError(t, tx.EnqueueOutbox(event))
+		return errors.New("downstream failure")
+	})
+	assert.Error(t, err)
+
+	_, err = storage.GetAlarm(created.ID)
+	assert.Error(t, err, "a rolled back create should not be visible")
+	alarm, err := storage.GetAlarm(kept.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "Kept Alarm", alarm.Name, "a rolled back update should restore the previous value")
+	history, _ := storage.GetAlarmHistory(created.ID)
+	assert.Empty(t, history)
+	pending, _ := storage.GetOutboxAfter(0, 10)
+	assert.Empty(t, pending)
+
+	// A successful transaction applies every write
+	err = storage.WithTransaction(func(tx AlarmStorage) error {
+		if err := tx.SaveAlarm(created); err != nil {
+			return err
+		}
+		if err := tx.AppendHistory(event); err != nil {
+			return err
+		}
+		return tx.EnqueueOutbox(event)
+	})
+	assert.NoError(t, err)
+
+	_, err = storage.GetAlarm(created.ID)
+	assert.NoError(t, err)
+	history, _ = storage.GetAlarmHistory(created.ID)
+	assert.Len(t, history, 1)
+	pending, _ = storage.GetOutboxAfter(0, 10)
+	assert.Len(t, pending, 1)
+}
@@ -0,0 +1,126 @@
+package storage
+
+import (
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/26christy/CarbonQuest/common/database"
+	"github.com/26christy/CarbonQuest/common/models"
+)
+
+// Default ordering when the query does not ask for one
+const (
+	defaultSortBy = "created_at"
+	defaultOrder  = "asc"
+)
+
+func applyQueryDefaults(query models.AlarmQuery) models.AlarmQuery {
+	if query.SortBy == "" {
+		query.SortBy = defaultSortBy
+	}
+	if query.Order == "" {
+		query.Order = defaultOrder
+	}
+	return query
+}
+
+// sortKey returns the value an alarm is ordered by. Times use the
+// database representation so cursors are interchangeable between stores.
+func sortKey(alarm models.Alarm, sortBy string) string {
+	switch sortBy {
+	case "name":
+		return alarm.Name
+	case "priority":
+		return strconv.Itoa(alarm.Priority)
+	case "timestamp":
+		return database.FormatTime(alarm.Timestamp)
+	case "updated_at":
+		return database.FormatTime(alarm.UpdatedAt)
+	default:
+		return database.FormatTime(alarm.CreatedAt)
+	}
+}
+
+// matchesQuery reports whether the alarm passes every filter in the query
+func matchesQuery(alarm models.Alarm, query models.AlarmQuery) bool {
+	if query.Status != "" && alarm.Status != query.Status {
+		return false
+	}
+	if len(query.Severities) > 0 && !slices.Contains(query.Severities, alarm.Severity) {
+		return false
+	}
+	if len(query.Priorities) > 0 && !slices.Contains(query.Priorities, alarm.Priority) {
+		return false
+	}
+	for _, selector := range query.LabelSelectors {
+		if !selector.Matches(alarm.Labels) {
+			return false
+		}
+	}
+	if query.Name != "" && !strings.Contains(strings.ToLower(alarm.Name), strings.ToLower(query.Name)) {
+		return false
+	}
+	if !query.TimestampFrom.IsZero() && alarm.Timestamp.Before(query.TimestampFrom) {
+		return false
+	}
+	if !query.TimestampTo.IsZero() && !alarm.Timestamp.Before(query.TimestampTo) {
+		return false
+	}
+	if !query.CreatedFrom.IsZero() && alarm.CreatedAt.Before(query.CreatedFrom) {
+		return false
+	}
+	if !query.CreatedTo.IsZero() && !alarm.CreatedAt.Before(query.CreatedTo) {
+		return false
+	}
+	if !query.LastSeenFrom.IsZero() && alarm.LastSeenAt.Before(query.LastSeenFrom) {
+		return false
+	}
+	if !query.LastSeenTo.IsZero() && !alarm.LastSeenAt.Before(query.LastSeenTo) {
+		return false
+	}
+	if query.After != nil && !isAfterCursor(alarm, query) {
+		return false
+	}
+	return true
+}
+
+// isAfterCursor compares on (sort key, id) so ties never repeat or skip an alarm
+func isAfterCursor(alarm models.Alarm, query models.AlarmQuery) bool {
+	key, id := sortKey(alarm, query.SortBy), alarm.ID.String()
+	if query.Order == "desc" {
+		return key < query.After.Key || (key == query.After.Key && id < query.After.ID)
+	}
+	return key > query.After.Key || (key == query.After.Key && id > query.After.ID)
+}
+
+// paginate orders the matched alarms and cuts out a single page
+func paginate(alarms []models.Alarm, query models.AlarmQuery) models.AlarmPage {
+	sort.Slice(alarms, func(i, j int) bool {
+		ki, kj := sortKey(alarms[i], query.SortBy), sortKey(alarms[j], query.SortBy)
+		if ki == kj {
+			ki, kj = alarms[i].ID.String(), alarms[j].ID.String()
+		}
+		if query.Order == "desc" {
+			return ki > kj
+		}
+		return ki < kj
+	})
+
+	page := models.AlarmPage{Alarms: alarms}
+	if query.Limit > 0 && len(alarms) > query.Limit {
+		page.Alarms = alarms[:query.Limit]
+		page.Next = cursorFor(page.Alarms[query.Limit-1], query)
+	}
+	return page
+}
+
+func cursorFor(alarm models.Alarm, query models.AlarmQuery) *models.AlarmCursor {
+	return &models.AlarmCursor{
+		SortBy: query.SortBy,
+		Order:  query.Order,
+		Key:    sortKey(alarm, query.SortBy),
+		ID:     alarm.ID.String(),
+	}
+}
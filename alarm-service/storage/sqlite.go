@@ -0,0 +1,728 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/26christy/CarbonQuest/common/database"
+	"github.com/26christy/CarbonQuest/common/models"
+	"github.com/google/uuid"
+)
+
+// migrations for the alarm schema, never edit an existing entry, always append
+var migrations = []string{
+	`CREATE TABLE IF NOT EXISTS alarms (
+		id         TEXT PRIMARY KEY,
+		name       TEXT NOT NULL,
+		timestamp  TEXT NOT NULL,
+		status     TEXT NOT NULL,
+		created_at TEXT NOT NULL,
+		updated_at TEXT NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_alarms_status ON alarms (status)`,
+	`CREATE INDEX IF NOT EXISTS idx_alarms_created_at ON alarms (created_at, id)`,
+	`CREATE INDEX IF NOT EXISTS idx_alarms_timestamp ON alarms (timestamp, id)`,
+	`CREATE TABLE IF NOT EXISTS alarm_history (
+		seq        INTEGER PRIMARY KEY AUTOINCREMENT,
+		id         TEXT NOT NULL UNIQUE,
+		alarm_id   TEXT NOT NULL,
+		action     TEXT NOT NULL,
+		actor      TEXT NOT NULL,
+		source     TEXT NOT NULL,
+		old_value  TEXT,
+		new_value  TEXT,
+		created_at TEXT NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_alarm_history_alarm_id ON alarm_history (alarm_id, seq)`,
+	`CREATE TABLE IF NOT EXISTS outbox (
+		seq             INTEGER PRIMARY KEY AUTOINCREMENT,
+		event_id        TEXT NOT NULL UNIQUE,
+		payload         TEXT NOT NULL,
+		attempts        INTEGER NOT NULL DEFAULT 0,
+		next_attempt_at TEXT NOT NULL,
+		last_error      TEXT NOT NULL DEFAULT '',
+		delivered_at    TEXT
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_outbox_pending ON outbox (delivered_at, seq)`,
+	`ALTER TABLE alarms ADD COLUMN severity TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE alarms ADD COLUMN priority INTEGER NOT NULL DEFAULT 0`,
+	`CREATE INDEX IF NOT EXISTS idx_alarms_severity ON alarms (severity)`,
+	`CREATE INDEX IF NOT EXISTS idx_alarms_priority ON alarms (priority, id)`,
+	`ALTER TABLE alarms ADD COLUMN labels TEXT NOT NULL DEFAULT '{}'`,
+	`ALTER TABLE alarms ADD COLUMN annotations TEXT NOT NULL DEFAULT '{}'`,
+	`ALTER TABLE alarms ADD COLUMN fingerprint TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE alarms ADD COLUMN occurrences INTEGER NOT NULL DEFAULT 1`,
+	`ALTER TABLE alarms ADD COLUMN last_seen_at TEXT NOT NULL DEFAULT ''`,
+	`UPDATE alarms SET last_seen_at = created_at WHERE last_seen_at = ''`,
+	`CREATE INDEX IF NOT EXISTS idx_alarms_fingerprint ON alarms (fingerprint, created_at)`,
+	`ALTER TABLE alarms ADD COLUMN resolved_at TEXT`,
+	`ALTER TABLE alarms ADD COLUMN resolve_reason TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE alarms ADD COLUMN resolve_note TEXT NOT NULL DEFAULT ''`,
+	`CREATE INDEX IF NOT EXISTS idx_alarms_last_seen_at ON alarms (last_seen_at)`,
+	// Delivery is tracked per subscriber in outbox_cursors, delivered rows are pruned
+	`DELETE FROM outbox WHERE delivered_at IS NOT NULL`,
+	`CREATE TABLE IF NOT EXISTS outbox_cursors (
+		subscriber      TEXT PRIMARY KEY,
+		seq             INTEGER NOT NULL,
+		attempts        INTEGER NOT NULL DEFAULT 0,
+		next_attempt_at TEXT NOT NULL,
+		last_error      TEXT NOT NULL DEFAULT ''
+	)`,
+	`CREATE TABLE IF NOT EXISTS outbox_dead_letters (
+		seq        INTEGER PRIMARY KEY AUTOINCREMENT,
+		subscriber TEXT NOT NULL,
+		event_id   TEXT NOT NULL,
+		payload    TEXT NOT NULL,
+		attempts   INTEGER NOT NULL,
+		last_error TEXT NOT NULL,
+		failed_at  TEXT NOT NULL
+	)`,
+}
+
+// alarmColumns are the columns scanAlarm reads, in order
+const alarmColumns = `id, name, timestamp, status, severity, priority, labels, annotations,
+	fingerprint, occurrences, last_seen_at, resolved_at, resolve_reason, resolve_note, created_at, updated_at`
+
+// queryer is satisfied by both *sql.DB and *sql.Tx
+type queryer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// SQLiteStorage is a file backed AlarmStorage, alarms survive restarts
+type SQLiteStorage struct {
+	db *sql.DB
+	// q runs the statements, it is the transaction inside WithTransaction
+	q queryer
+}
+
+// constructor, opens (or creates) the database file and runs the migrations
+func NewSQLiteStorage(path string) (*SQLiteStorage, error) {
+	db, err := database.OpenSQLite(path, migrations)
+	if err != nil {
+		return nil, err
+	}
+	return &SQLiteStorage{db: db, q: db}, nil
+}
+
+// Close releases the underlying database handle
+func (s *SQLiteStorage) Close() error {
+	return s.db.Close()
+}
+
+// save alarm
+func (s *SQLiteStorage) SaveAlarm(alarm models.Alarm) error {
+	labels, annotations, err := marshalLabels(alarm)
+	if err != nil {
+		return err
+	}
+	_, err = s.q.Exec(
+		`INSERT OR REPLACE INTO alarms (`+alarmColumns+`)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		alarm.ID.String(),
+		alarm.Name,
+		database.FormatTime(alarm.Timestamp),
+		alarm.Status,
+		alarm.Severity,
+		alarm.Priority,
+		labels,
+		annotations,
+		alarm.Fingerprint,
+		alarm.Occurrences,
+		database.FormatTime(alarm.LastSeenAt),
+		formatResolvedAt(alarm.ResolvedAt),
+		alarm.ResolveReason,
+		alarm.ResolveNote,
+		database.FormatTime(alarm.CreatedAt),
+		database.FormatTime(alarm.UpdatedAt),
+	)
+	return err
+}
+
+// Get an alarm
+func (s *SQLiteStorage) GetAlarm(id uuid.UUID) (*models.Alarm, error) {
+	row := s.q.QueryRow(
+		`SELECT `+alarmColumns+` FROM alarms WHERE id = ?`,
+		id.String(),
+	)
+
+	alarm, err := scanAlarm(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.New("alarm not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &alarm, nil
+}
+
+// Get all alarms
+func (s *SQLiteStorage) GetAllAlarms() ([]models.Alarm, error) {
+	rows, err := s.q.Query(`SELECT ` + alarmColumns + ` FROM alarms`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []models.Alarm
+	for rows.Next() {
+		alarm, err := scanAlarm(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, alarm)
+	}
+	return result, rows.Err()
+}
+
+// Find the open alarm with a fingerprint
+func (s *SQLiteStorage) FindOpenAlarm(fingerprint string) (*models.Alarm, error) {
+	args := []any{fingerprint}
+	for _, status := range models.OpenStatuses {
+		args = append(args, status)
+	}
+	row := s.q.QueryRow(
+		`SELECT `+alarmColumns+` FROM alarms
+		 WHERE fingerprint = ? AND status IN (`+placeholders(len(models.OpenStatuses))+`)
+		 ORDER BY created_at DESC LIMIT 1`,
+		args...,
+	)
+
+	alarm, err := scanAlarm(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &alarm, nil
+}
+
+// sortColumns whitelists the columns an alarm list can be ordered by
+var sortColumns = map[string]string{
+	"created_at": "created_at",
+	"updated_at": "updated_at",
+	"timestamp":  "timestamp",
+	"name":       "name",
+	"priority":   "priority",
+}
+
+// List alarms matching the query
+func (s *SQLiteStorage) ListAlarms(query models.AlarmQuery) (models.AlarmPage, error) {
+	query = applyQueryDefaults(query)
+	column, ok := sortColumns[query.SortBy]
+	if !ok {
+		return models.AlarmPage{}, fmt.Errorf("unsupported sort field: %s", query.SortBy)
+	}
+
+	var (
+		where []string
+		args  []any
+	)
+	if query.Status != "" {
+		where = append(where, "status = ?")
+		args = append(args, query.Status)
+	}
+	if len(query.Severities) > 0 {
+		where = append(where, "severity IN ("+placeholders(len(query.Severities))+")")
+		for _, severity := range query.Severities {
+			args = append(args, severity)
+		}
+	}
+	if len(query.Priorities) > 0 {
+		where = append(where, "priority IN ("+placeholders(len(query.Priorities))+")")
+		for _, priority := range query.Priorities {
+			args = append(args, priority)
+		}
+	}
+	for _, selector := range query.LabelSelectors {
+		// Keys are validated, so the JSON path cannot be escaped
+		path := `$."` + selector.Key + `"`
+		switch selector.Op {
+		case models.LabelEquals:
+			where = append(where, "json_extract(labels, ?) = ?")
+			args = append(args, path, selector.Value)
+		case models.LabelNotEquals:
+			where = append(where, "json_extract(labels, ?) IS NOT ?")
+			args = append(args, path, selector.Value)
+		case models.LabelNotExists:
+			where = append(where, "json_type(labels, ?) IS NULL")
+			args = append(args, path)
+		default:
+			where = append(where, "json_type(labels, ?) IS NOT NULL")
+			args = append(args, path)
+		}
+	}
+	if query.Name != "" {
+		where = append(where, "instr(lower(name), lower(?)) > 0")
+		args = append(args, query.Name)
+	}
+	if !query.TimestampFrom.IsZero() {
+		where = append(where, "timestamp >= ?")
+		args = append(args, database.FormatTime(query.TimestampFrom))
+	}
+	if !query.TimestampTo.IsZero() {
+		where = append(where, "timestamp < ?")
+		args = append(args, database.FormatTime(query.TimestampTo))
+	}
+	if !query.CreatedFrom.IsZero() {
+		where = append(where, "created_at >= ?")
+		args = append(args, database.FormatTime(query.CreatedFrom))
+	}
+	if !query.CreatedTo.IsZero() {
+		where = append(where, "created_at < ?")
+		args = append(args, database.FormatTime(query.CreatedTo))
+	}
+	if !query.LastSeenFrom.IsZero() {
+		where = append(where, "last_seen_at >= ?")
+		args = append(args, database.FormatTime(query.LastSeenFrom))
+	}
+	if !query.LastSeenTo.IsZero() {
+		where = append(where, "last_seen_at < ?")
+		args = append(args, database.FormatTime(query.LastSeenTo))
+	}
+
+	direction, cmp := "ASC", ">"
+	if query.Order == "desc" {
+		direction, cmp = "DESC", "<"
+	}
+	if query.After != nil {
+		where = append(where, fmt.Sprintf("(%[1]s %[2]s ? OR (%[1]s = ? AND id %[2]s ?))", column, cmp))
+		args = append(args, query.After.Key, query.After.Key, query.After.ID)
+	}
+
+	stmt := `SELECT ` + alarmColumns + ` FROM alarms`
+	if len(where) > 0 {
+		stmt += " WHERE " + strings.Join(where, " AND ")
+	}
+	stmt += fmt.Sprintf(" ORDER BY %[1]s %[2]s, id %[2]s", column, direction)
+	if query.Limit > 0 {
+		// one extra row tells whether there is a next page
+		stmt += " LIMIT ?"
+		args = append(args, query.Limit+1)
+	}
+
+	rows, err := s.q.Query(stmt, args...)
+	if err != nil {
+		return models.AlarmPage{}, err
+	}
+	defer rows.Close()
+
+	result := []models.Alarm{}
+	for rows.Next() {
+		alarm, err := scanAlarm(rows)
+		if err != nil {
+			return models.AlarmPage{}, err
+		}
+		result = append(result, alarm)
+	}
+	if err := rows.Err(); err != nil {
+		return models.AlarmPage{}, err
+	}
+
+	page := models.AlarmPage{Alarms: result}
+	if query.Limit > 0 && len(result) > query.Limit {
+		page.Alarms = result[:query.Limit]
+		page.Next = cursorFor(page.Alarms[query.Limit-1], query)
+	}
+	return page, nil
+}
+
+// Delete an alarm
+func (s *SQLiteStorage) DeleteAlarm(id uuid.UUID) error {
+	res, err := s.q.Exec(`DELETE FROM alarms WHERE id = ?`, id.String())
+	if err != nil {
+		return err
+	}
+	return requireAffected(res)
+}
+
+// Update an alarm
+func (s *SQLiteStorage) UpdateAlarm(alarm models.Alarm) error {
+	labels, annotations, err := marshalLabels(alarm)
+	if err != nil {
+		return err
+	}
+	// CreatedAt is left untouched, UpdatedAt is refreshed
+	res, err := s.q.Exec(
+		`UPDATE alarms SET name = ?, timestamp = ?, status = ?, severity = ?, priority = ?, labels = ?, annotations = ?,
+		 fingerprint = ?, occurrences = ?, last_seen_at = ?, resolved_at = ?, resolve_reason = ?, resolve_note = ?,
+		 updated_at = ? WHERE id = ?`,
+		alarm.Name,
+		database.FormatTime(alarm.Timestamp),
+		alarm.Status,
+		alarm.Severity,
+		alarm.Priority,
+		labels,
+		annotations,
+		alarm.Fingerprint,
+		alarm.Occurrences,
+		database.FormatTime(alarm.LastSeenAt),
+		formatResolvedAt(alarm.ResolvedAt),
+		alarm.ResolveReason,
+		alarm.ResolveNote,
+		database.FormatTime(time.Now()),
+		alarm.ID.String(),
+	)
+	if err != nil {
+		return err
+	}
+	return requireAffected(res)
+}
+
+// Append a history entry
+func (s *SQLiteStorage) AppendHistory(entry models.AlarmHistoryEntry) error {
+	oldValue, err := marshalAlarm(entry.OldValue)
+	if err != nil {
+		return err
+	}
+	newValue, err := marshalAlarm(entry.NewValue)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.q.Exec(
+		`INSERT INTO alarm_history (id, alarm_id, action, actor, source, old_value, new_value, created_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		entry.ID.String(),
+		entry.AlarmID.String(),
+		entry.Action,
+		entry.Actor,
+		entry.Source,
+		oldValue,
+		newValue,
+		database.FormatTime(entry.CreatedAt),
+	)
+	return err
+}
+
+// Get the history of an alarm, oldest entry first
+func (s *SQLiteStorage) GetAlarmHistory(id uuid.UUID) ([]models.AlarmHistoryEntry, error) {
+	rows, err := s.q.Query(
+		`SELECT id, action, actor, source, old_value, new_value, created_at
+		 FROM alarm_history WHERE alarm_id = ? ORDER BY seq`,
+		id.String(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []models.AlarmHistoryEntry{}
+	for rows.Next() {
+		var (
+			entry              models.AlarmHistoryEntry
+			entryID, createdAt string
+			oldValue, newValue sql.NullString
+		)
+		if err := rows.Scan(&entryID, &entry.Action, &entry.Actor, &entry.Source, &oldValue, &newValue, &createdAt); err != nil {
+			return nil, err
+		}
+		if entry.ID, err = uuid.Parse(entryID); err != nil {
+			return nil, err
+		}
+		if entry.CreatedAt, err = database.ParseTime(createdAt); err != nil {
+			return nil, err
+		}
+		if entry.OldValue, err = unmarshalAlarm(oldValue); err != nil {
+			return nil, err
+		}
+		if entry.NewValue, err = unmarshalAlarm(newValue); err != nil {
+			return nil, err
+		}
+		entry.AlarmID = id
+		result = append(result, entry)
+	}
+	return result, rows.Err()
+}
+
+// Add an event to the outbox
+func (s *SQLiteStorage) EnqueueOutbox(event models.AlarmHistoryEntry) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = s.q.Exec(
+		`INSERT INTO outbox (event_id, payload, next_attempt_at) VALUES (?, ?, ?)`,
+		event.ID.String(),
+		string(payload),
+		database.FormatTime(event.CreatedAt),
+	)
+	return err
+}
+
+// Get the outbox entries after seq, oldest first
+func (s *SQLiteStorage) GetOutboxAfter(seq int64, limit int) ([]models.OutboxEntry, error) {
+	stmt := `SELECT seq, payload FROM outbox WHERE seq > ? ORDER BY seq`
+	args := []any{seq}
+	if limit > 0 {
+		stmt += " LIMIT ?"
+		args = append(args, limit)
+	}
+
+	rows, err := s.q.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []models.OutboxEntry{}
+	for rows.Next() {
+		var (
+			entry   models.OutboxEntry
+			payload string
+		)
+		if err := rows.Scan(&entry.Seq, &payload); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(payload), &entry.Event); err != nil {
+			return nil, err
+		}
+		result = append(result, entry)
+	}
+	return result, rows.Err()
+}
+
+// Delete the outbox entries up to seq
+func (s *SQLiteStorage) PruneOutbox(seq int64) error {
+	_, err := s.q.Exec(`DELETE FROM outbox WHERE seq <= ?`, seq)
+	return err
+}
+
+// Get the position of a subscriber in the outbox
+func (s *SQLiteStorage) GetOutboxCursor(subscriber string) (models.OutboxCursor, error) {
+	cursor := models.OutboxCursor{Subscriber: subscriber}
+	var nextAttemptAt string
+	err := s.q.QueryRow(
+		`SELECT seq, attempts, next_attempt_at, last_error FROM outbox_cursors WHERE subscriber = ?`,
+		subscriber,
+	).Scan(&cursor.Seq, &cursor.Attempts, &nextAttemptAt, &cursor.LastError)
+	if errors.Is(err, sql.ErrNoRows) {
+		return cursor, nil
+	}
+	if err != nil {
+		return cursor, err
+	}
+	cursor.NextAttemptAt, err = database.ParseTime(nextAttemptAt)
+	return cursor, err
+}
+
+// Save the position of a subscriber in the outbox
+func (s *SQLiteStorage) SaveOutboxCursor(cursor models.OutboxCursor) error {
+	_, err := s.q.Exec(
+		`INSERT OR REPLACE INTO outbox_cursors (subscriber, seq, attempts, next_attempt_at, last_error)
+		 VALUES (?, ?, ?, ?, ?)`,
+		cursor.Subscriber,
+		cursor.Seq,
+		cursor.Attempts,
+		database.FormatTime(cursor.NextAttemptAt),
+		cursor.LastError,
+	)
+	return err
+}
+
+// Keep an event a subscriber did not take and move its cursor past it
+func (s *SQLiteStorage) DeadLetterOutbox(deadLetter models.OutboxDeadLetter, cursor models.OutboxCursor) error {
+	payload, err := json.Marshal(deadLetter.Event)
+	if err != nil {
+		return err
+	}
+	return s.WithTransaction(func(tx AlarmStorage) error {
+		_, err := tx.(*SQLiteStorage).q.Exec(
+			`INSERT INTO outbox_dead_letters (subscriber, event_id, payload, attempts, last_error, failed_at)
+			 VALUES (?, ?, ?, ?, ?, ?)`,
+			deadLetter.Subscriber,
+			deadLetter.Event.ID.String(),
+			string(payload),
+			deadLetter.Attempts,
+			deadLetter.LastError,
+			database.FormatTime(deadLetter.FailedAt),
+		)
+		if err != nil {
+			return err
+		}
+		return tx.SaveOutboxCursor(cursor)
+	})
+}
+
+// Get the outbox dead letters, oldest first
+func (s *SQLiteStorage) GetOutboxDeadLetters() ([]models.OutboxDeadLetter, error) {
+	rows, err := s.q.Query(`SELECT subscriber, payload, attempts, last_error, failed_at
+		 FROM outbox_dead_letters ORDER BY seq`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []models.OutboxDeadLetter{}
+	for rows.Next() {
+		var (
+			deadLetter        models.OutboxDeadLetter
+			payload, failedAt string
+		)
+		if err := rows.Scan(&deadLetter.Subscriber, &payload, &deadLetter.Attempts, &deadLetter.LastError, &failedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(payload), &deadLetter.Event); err != nil {
+			return nil, err
+		}
+		if deadLetter.FailedAt, err = database.ParseTime(failedAt); err != nil {
+			return nil, err
+		}
+		result = append(result, deadLetter)
+	}
+	return result, rows.Err()
+}
+
+// Run fn inside a database transaction, committed only when fn succeeds
+func (s *SQLiteStorage) WithTransaction(fn func(tx AlarmStorage) error) error {
+	// Nested transactions join the outer one
+	if _, nested := s.q.(*sql.Tx); nested {
+		return fn(s)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	if err := fn(&SQLiteStorage{db: s.db, q: tx}); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// marshalAlarm stores a snapshot as JSON, nil snapshots become NULL
+func marshalAlarm(alarm *models.Alarm) (sql.NullString, error) {
+	if alarm == nil {
+		return sql.NullString{}, nil
+	}
+	raw, err := json.Marshal(alarm)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	return sql.NullString{String: string(raw), Valid: true}, nil
+}
+
+func unmarshalAlarm(value sql.NullString) (*models.Alarm, error) {
+	if !value.Valid {
+		return nil, nil
+	}
+	var alarm models.Alarm
+	if err := json.Unmarshal([]byte(value.String), &alarm); err != nil {
+		return nil, err
+	}
+	return &alarm, nil
+}
+
+// marshalLabels stores the labels and annotations of an alarm as JSON objects, nil maps as {}
+func marshalLabels(alarm models.Alarm) (string, string, error) {
+	encode := func(labels map[string]string) (string, error) {
+		if labels == nil {
+			return "{}", nil
+		}
+		raw, err := json.Marshal(labels)
+		return string(raw), err
+	}
+	labels, err := encode(alarm.Labels)
+	if err != nil {
+		return "", "", err
+	}
+	annotations, err := encode(alarm.Annotations)
+	if err != nil {
+		return "", "", err
+	}
+	return labels, annotations, nil
+}
+
+// unmarshalLabels reads a JSON object of labels, an empty object is read as nil
+func unmarshalLabels(raw string) (map[string]string, error) {
+	var labels map[string]string
+	if err := json.Unmarshal([]byte(raw), &labels); err != nil {
+		return nil, err
+	}
+	if len(labels) == 0 {
+		return nil, nil
+	}
+	return labels, nil
+}
+
+// scanner is satisfied by both *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanAlarm(row scanner) (models.Alarm, error) {
+	var (
+		alarm                                     models.Alarm
+		id, timestamp, lastSeen, created, updated string
+		labels, annotations                       string
+		resolvedAt                                sql.NullString
+	)
+	if err := row.Scan(&id, &alarm.Name, &timestamp, &alarm.Status, &alarm.Severity, &alarm.Priority, &labels, &annotations,
+		&alarm.Fingerprint, &alarm.Occurrences, &lastSeen, &resolvedAt, &alarm.ResolveReason, &alarm.ResolveNote,
+		&created, &updated); err != nil {
+		return models.Alarm{}, err
+	}
+
+	var err error
+	if alarm.Labels, err = unmarshalLabels(labels); err != nil {
+		return models.Alarm{}, err
+	}
+	if alarm.Annotations, err = unmarshalLabels(annotations); err != nil {
+		return models.Alarm{}, err
+	}
+	if alarm.ID, err = uuid.Parse(id); err != nil {
+		return models.Alarm{}, err
+	}
+	if alarm.Timestamp, err = database.ParseTime(timestamp); err != nil {
+		return models.Alarm{}, err
+	}
+	if alarm.LastSeenAt, err = database.ParseTime(lastSeen); err != nil {
+		return models.Alarm{}, err
+	}
+	if resolvedAt.Valid {
+		at, err := database.ParseTime(resolvedAt.String)
+		if err != nil {
+			return models.Alarm{}, err
+		}
+		alarm.ResolvedAt = &at
+	}
+	if alarm.CreatedAt, err = database.ParseTime(created); err != nil {
+		return models.Alarm{}, err
+	}
+	if alarm.UpdatedAt, err = database.ParseTime(updated); err != nil {
+		return models.Alarm{}, err
+	}
+	return alarm, nil
+}
+
+// formatResolvedAt stores the resolve time, NULL while the alarm is not resolved
+func formatResolvedAt(at *time.Time) sql.NullString {
+	if at == nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: database.FormatTime(*at), Valid: true}
+}
+
+// placeholders returns n comma separated query parameters
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// requireAffected maps "no rows touched" to the same error MemoryStorage returns
+func requireAffected(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return errors.New("alarm not found")
+	}
+	return nil
+}
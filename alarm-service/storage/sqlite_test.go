@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/26christy/CarbonQuest/common/models"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLiteStorage(t *testing.T) {
+	storage, err := NewSQLiteStorage(filepath.Join(t.TempDir(), "alarms.db"))
+	require.NoError(t, err)
+	defer storage.Close()
+
+	testAlarmStorage(t, storage)
+}
+
+func TestSQLiteStorage_SurvivesReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "alarms.db")
+
+	storage, err := NewSQLiteStorage(path)
+	require.NoError(t, err)
+
+	alarm := models.Alarm{
+		ID:        uuid.New(),
+		Name:      "Persistent Alarm",
+		Timestamp: time.Now(),
+		Status:    "triggered",
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	require.NoError(t, storage.SaveAlarm(alarm))
+	require.NoError(t, storage.Close())
+
+	// Reopening runs the migrations again, they must be idempotent
+	reopened, err := NewSQLiteStorage(path)
+	require.NoError(t, err)
+	defer reopened.Close()
+
+	retrieved, err := reopened.GetAlarm(alarm.ID)
+	assert.NoError(t, err, "alarm should survive a reopen")
+	assert.Equal(t, alarm.Name, retrieved.Name)
+	assert.Equal(t, alarm.Status, retrieved.Status)
+	assert.True(t, alarm.Timestamp.Equal(retrieved.Timestamp), "timestamp should round trip")
+}
+
+func TestCreateStorage(t *testing.T) {
+	store, err := CreateStorage("memory", "")
+	assert.NoError(t, err)
+	assert.IsType(t, &MemoryStorage{}, store)
+
+	_, err = CreateStorage("sqlite", "")
+	assert.Error(t, err, "sqlite requires a database path")
+
+	_, err = CreateStorage("unknown", "")
+	assert.Error(t, err)
+}
+
+func TestSQLiteStorage_ListAlarms(t *testing.T) {
+	storage, err := NewSQLiteStorage(filepath.Join(t.TempDir(), "alarms.db"))
+	require.NoError(t, err)
+	defer storage.Close()
+
+	testListAlarms(t, storage)
+}
+
+func TestSQLiteStorage_FindOpenAlarm(t *testing.T) {
+	storage, err := NewSQLiteStorage(filepath.Join(t.TempDir(), "alarms.db"))
+	require.NoError(t, err)
+	defer storage.Close()
+
+	testFindOpenAlarm(t, storage)
+}
+
+func TestSQLiteStorage_History(t *testing.T) {
+	storage, err := NewSQLiteStorage(filepath.Join(t.TempDir(), "alarms.db"))
+	require.NoError(t, err)
+	defer storage.Close()
+
+	testAlarmHistory(t, storage)
+}
+
+func TestSQLiteStorage_Outbox(t *testing.T) {
+	storage, err := NewSQLiteStorage(filepath.Join(t.TempDir(), "alarms.db"))
+	require.NoError(t, err)
+	defer storage.Close()
+
+	testOutbox(t, storage)
+}
+
+func TestSQLiteStorage_Transaction(t *testing.T) {
+	storage, err := NewSQLiteStorage(filepath.Join(t.TempDir(), "alarms.db"))
+	require.NoError(t, err)
+	defer storage.Close()
+
+	testTransaction(t, storage)
+}
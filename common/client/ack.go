@@ -0,0 +1,31 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/26christy/CarbonQuest/common/models"
+)
+
+// ACKClient calls the ack-service API
+type ACKClient struct {
+	base
+}
+
+func NewACKClient(config Config) *ACKClient {
+	return &ACKClient{base: newBase(config)}
+}
+
+// ACKAlarm acknowledges an alarm. It is a POST and therefore never retried.
+func (c *ACKClient) ACKAlarm(ctx context.Context, alarmID string) error {
+	return c.do(ctx, http.MethodPost, "/ack/"+url.PathEscape(alarmID), nil, nil, nil)
+}
+
+// GetACKState fetches the ACK state of an alarm. An alarm that was never
+// acknowledged has a zero ACKedAt and ShouldNotify set.
+func (c *ACKClient) GetACKState(ctx context.Context, alarmID string) (models.ACKState, error) {
+	var ackState models.ACKState
+	err := c.do(ctx, http.MethodGet, "/ack/"+url.PathEscape(alarmID), nil, nil, &ackState)
+	return ackState, err
+}
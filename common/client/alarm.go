@@ -0,0 +1,108 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/26christy/CarbonQuest/common/models"
+	"github.com/google/uuid"
+)
+
+// AlarmClient calls the alarm-service API
+type AlarmClient struct {
+	base
+}
+
+func NewAlarmClient(config Config) *AlarmClient {
+	return &AlarmClient{base: newBase(config)}
+}
+
+// AlarmList is one page of GET /alarms, NextCursor is empty on the last page
+type AlarmList struct {
+	Alarms     []models.Alarm `json:"alarms"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+}
+
+// GetAlarm fetches a single alarm, ErrNotFound if it does not exist
+func (c *AlarmClient) GetAlarm(ctx context.Context, id string) (models.Alarm, error) {
+	var alarm models.Alarm
+	err := c.do(ctx, http.MethodGet, "/alarms/"+url.PathEscape(id), nil, nil, &alarm)
+	return alarm, err
+}
+
+// ListAlarms fetches alarms matching the query. Without a limit every alarm is returned.
+func (c *AlarmClient) ListAlarms(ctx context.Context, query models.AlarmQuery) (AlarmList, error) {
+	var list AlarmList
+	err := c.do(ctx, http.MethodGet, "/alarms", alarmQueryValues(query), nil, &list)
+	return list, err
+}
+
+// UpdateAlarmStatus sets the status of an alarm and returns the updated alarm.
+// An alarm already in the status counts as updated: the PUT is retried, and a
+// retry whose first attempt went through but lost its response is refused as
+// a transition to the same status.
+func (c *AlarmClient) UpdateAlarmStatus(ctx context.Context, id, status string) (models.UpdateAlarm, error) {
+	var updated models.UpdateAlarm
+	path := "/alarms/" + url.PathEscape(id)
+	payload := map[string]string{"status": status}
+	err := c.do(ctx, http.MethodPut, path, nil, payload, &updated)
+	if errors.Is(err, ErrBadRequest) {
+		var current models.UpdateAlarm
+		if c.do(ctx, http.MethodGet, path, nil, nil, &current) == nil && current.Status == status {
+			return current, nil
+		}
+	}
+	return updated, err
+}
+
+// GetAlarmHistory fetches the change history of an alarm, oldest entry first
+func (c *AlarmClient) GetAlarmHistory(ctx context.Context, id uuid.UUID) ([]models.AlarmHistoryEntry, error) {
+	var resp struct {
+		History []models.AlarmHistoryEntry `json:"history"`
+	}
+	err := c.do(ctx, http.MethodGet, "/alarms/"+id.String()+"/history", nil, nil, &resp)
+	return resp.History, err
+}
+
+func alarmQueryValues(query models.AlarmQuery) url.Values {
+	values := url.Values{}
+	set := func(key, value string) {
+		if value != "" {
+			values.Set(key, value)
+		}
+	}
+	setTime := func(key string, t time.Time) {
+		if !t.IsZero() {
+			values.Set(key, t.Format(time.RFC3339Nano))
+		}
+	}
+
+	set("status", query.Status)
+	for _, severity := range query.Severities {
+		values.Add("severity", severity)
+	}
+	for _, priority := range query.Priorities {
+		values.Add("priority", strconv.Itoa(priority))
+	}
+	for _, label := range query.Labels {
+		values.Add("label", label)
+	}
+	set("name", query.Name)
+	setTime("timestamp_from", query.TimestampFrom)
+	setTime("timestamp_to", query.TimestampTo)
+	setTime("created_from", query.CreatedFrom)
+	setTime("created_to", query.CreatedTo)
+	setTime("last_seen_from", query.LastSeenFrom)
+	setTime("last_seen_to", query.LastSeenTo)
+	set("sort_by", query.SortBy)
+	set("order", query.Order)
+	if query.Limit > 0 {
+		values.Set("limit", strconv.Itoa(query.Limit))
+	}
+	set("cursor", query.Cursor)
+	return values
+}
@@ -0,0 +1,172 @@
+// Package client holds typed HTTP clients for calls between the services.
+// Each client adds the service actor headers, a per attempt timeout and
+// retries with exponential backoff for idempotent requests.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	defaultTimeout    = 10 * time.Second
+	defaultMaxRetries = 3
+	defaultRetryDelay = 200 * time.Millisecond
+	maxRetryDelay     = 5 * time.Second
+)
+
+// Config configures a service client, zero values fall back to the defaults
+type Config struct {
+	// BaseURL of the target service, e.g. http://localhost:8080
+	BaseURL string
+	// HTTPClient sends the requests, http.DefaultClient if nil
+	HTTPClient *http.Client
+	// Timeout bounds a single attempt, 10s by default
+	Timeout time.Duration
+	// MaxRetries is the number of retries after the first attempt, 3 by default.
+	// Use a negative value to disable retries.
+	MaxRetries int
+	// RetryDelay is the wait before the first retry, doubled on every further retry
+	RetryDelay time.Duration
+	// ServiceName is sent in the X-Actor and X-Source-Service headers
+	ServiceName string
+	// InternalToken is sent in the X-Internal-Token header, alarm-service
+	// only trusts X-Source-Service for some changes together with it
+	InternalToken string
+}
+
+// BaseURLFromEnv returns the base URL in urlKey, or builds one from HOST and portKey.
+// For example BaseURLFromEnv("ALARM_SERVICE_URL", "ALARM_SERVICE_PORT").
+func BaseURLFromEnv(urlKey, portKey string) string {
+	if baseURL := os.Getenv(urlKey); baseURL != "" {
+		return baseURL
+	}
+	host := os.Getenv("HOST")
+	if host == "" {
+		host = "localhost"
+	}
+	return fmt.Sprintf("http://%s:%s", host, os.Getenv(portKey))
+}
+
+// base does the request plumbing shared by the service clients
+type base struct {
+	config Config
+}
+
+func newBase(config Config) base {
+	config.BaseURL = strings.TrimRight(config.BaseURL, "/")
+	if config.HTTPClient == nil {
+		config.HTTPClient = http.DefaultClient
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaultTimeout
+	}
+	if config.MaxRetries == 0 {
+		config.MaxRetries = defaultMaxRetries
+	}
+	if config.RetryDelay <= 0 {
+		config.RetryDelay = defaultRetryDelay
+	}
+	return base{config: config}
+}
+
+// do sends the request and decodes a 2xx response into out, if out is not nil.
+// Idempotent requests are retried on network errors and retryable status codes.
+func (b base) do(ctx context.Context, method, path string, query url.Values, in, out any) error {
+	endpoint := b.config.BaseURL + path
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+
+	var payload []byte
+	if in != nil {
+		var err error
+		if payload, err = json.Marshal(in); err != nil {
+			return err
+		}
+	}
+
+	retries := b.config.MaxRetries
+	if retries < 0 || !idempotent(method) {
+		retries = 0
+	}
+
+	delay := b.config.RetryDelay
+	for attempt := 0; ; attempt++ {
+		err := b.attempt(ctx, method, endpoint, payload, out)
+		if err == nil || attempt >= retries || !retryable(err) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay = min(2*delay, maxRetryDelay)
+	}
+}
+
+func (b base) attempt(ctx context.Context, method, endpoint string, payload []byte, out any) error {
+	ctx, cancel := context.WithTimeout(ctx, b.config.Timeout)
+	defer cancel()
+
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, body)
+	if err != nil {
+		return err
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if b.config.ServiceName != "" {
+		// Recorded by alarm-service in the alarm history
+		req.Header.Set("X-Actor", b.config.ServiceName)
+		req.Header.Set("X-Source-Service", b.config.ServiceName)
+	}
+	if b.config.InternalToken != "" {
+		req.Header.Set("X-Internal-Token", b.config.InternalToken)
+	}
+
+	resp, err := b.config.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return newHTTPError(method, endpoint, resp.StatusCode, respBody)
+	}
+
+	if out == nil || len(respBody) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return fmt.Errorf("decoding response from %s %s: %w", method, endpoint, err)
+	}
+	return nil
+}
+
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,228 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/26christy/CarbonQuest/common/models"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testConfig(server *httptest.Server) Config {
+	return Config{
+		BaseURL:     server.URL + "/",
+		Timeout:     time.Second,
+		RetryDelay:  time.Millisecond,
+		ServiceName: "test-service",
+	}
+}
+
+func TestAlarmClient_GetAlarm(t *testing.T) {
+	id := uuid.New()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method)
+		assert.Equal(t, "/alarms/"+id.String(), r.URL.Path)
+		w.Write([]byte(`{"id":"` + id.String() + `","name":"Boiler","status":"active"}`))
+	}))
+	defer server.Close()
+
+	alarm, err := NewAlarmClient(testConfig(server)).GetAlarm(context.Background(), id.String())
+	require.NoError(t, err)
+	assert.Equal(t, id, alarm.ID)
+	assert.Equal(t, "active", alarm.Status)
+}
+
+func TestAlarmClient_ListAlarmsQuery(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		assert.Equal(t, "triggered", query.Get("status"))
+		assert.Equal(t, []string{"critical", "major"}, query["severity"])
+		assert.Equal(t, []string{"team=db", "!muted"}, query["label"])
+		assert.Equal(t, "50", query.Get("limit"))
+		assert.Equal(t, "2025-03-18T10:00:00Z", query.Get("created_from"))
+		assert.False(t, query.Has("name"), "empty filters are left out")
+		w.Write([]byte(`{"alarms":[{"name":"Boiler"}],"next_cursor":"abc"}`))
+	}))
+	defer server.Close()
+
+	list, err := NewAlarmClient(testConfig(server)).ListAlarms(context.Background(), models.AlarmQuery{
+		Status:      "triggered",
+		Severities:  []string{"critical", "major"},
+		Labels:      []string{"team=db", "!muted"},
+		Limit:       50,
+		CreatedFrom: time.Date(2025, 3, 18, 10, 0, 0, 0, time.UTC),
+	})
+	require.NoError(t, err)
+	assert.Len(t, list.Alarms, 1)
+	assert.Equal(t, "abc", list.NextCursor)
+}
+
+func TestAlarmClient_UpdateAlarmStatusSendsActor(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPut, r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.Equal(t, "test-service", r.Header.Get("X-Actor"))
+		assert.Equal(t, "test-service", r.Header.Get("X-Source-Service"))
+		w.Write([]byte(`{"status":"ACK"}`))
+	}))
+	defer server.Close()
+
+	updated, err := NewAlarmClient(testConfig(server)).UpdateAlarmStatus(context.Background(), "id", "ACK")
+	require.NoError(t, err)
+	assert.Equal(t, "ACK", updated.Status)
+}
+
+func TestAlarmClient_UpdateAlarmStatusResponseLost(t *testing.T) {
+	var puts atomic.Int32
+	status := "active"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet:
+			w.Write([]byte(`{"status":"` + status + `"}`))
+		case puts.Add(1) == 1:
+			// The change is applied but the response never arrives
+			status = "ACK"
+			conn, _, err := w.(http.Hijacker).Hijack()
+			require.NoError(t, err)
+			conn.Close()
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"invalid state transition"}`))
+		}
+	}))
+	defer server.Close()
+
+	updated, err := NewAlarmClient(testConfig(server)).UpdateAlarmStatus(context.Background(), "id", "ACK")
+	require.NoError(t, err, "the retry finds the alarm already in the status")
+	assert.Equal(t, "ACK", updated.Status)
+	assert.Equal(t, int32(2), puts.Load())
+
+	// A refused change to a status the alarm is not in stays an error
+	_, err = NewAlarmClient(testConfig(server)).UpdateAlarmStatus(context.Background(), "id", "closed")
+	assert.ErrorIs(t, err, ErrBadRequest)
+}
+
+func TestTypedErrors(t *testing.T) {
+	tests := []struct {
+		statusCode int
+		want       error
+	}{
+		{http.StatusBadRequest, ErrBadRequest},
+		{http.StatusForbidden, ErrUnauthorized},
+		{http.StatusNotFound, ErrNotFound},
+		{http.StatusConflict, ErrConflict},
+		{http.StatusInternalServerError, ErrServer},
+	}
+
+	for _, tt := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tt.statusCode)
+			w.Write([]byte(`{"error":"failed to fetch alarm","details":"boom"}`))
+		}))
+
+		_, err := NewAlarmClient(testConfig(server)).GetAlarm(context.Background(), "id")
+		server.Close()
+
+		assert.ErrorIs(t, err, tt.want, "status %d", tt.statusCode)
+
+		var httpErr *HTTPError
+		require.ErrorAs(t, err, &httpErr)
+		assert.Equal(t, tt.statusCode, httpErr.StatusCode)
+		assert.Equal(t, "failed to fetch alarm", httpErr.Message)
+		assert.Equal(t, "boom", httpErr.Details)
+	}
+}
+
+func TestRetriesUnavailable(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"status":"active"}`))
+	}))
+	defer server.Close()
+
+	alarm, err := NewAlarmClient(testConfig(server)).GetAlarm(context.Background(), "id")
+	require.NoError(t, err)
+	assert.Equal(t, "active", alarm.Status)
+	assert.Equal(t, int32(3), calls.Load())
+}
+
+func TestRetriesGiveUp(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	config := testConfig(server)
+	config.MaxRetries = 2
+	_, err := NewAlarmClient(config).GetAlarm(context.Background(), "id")
+	assert.ErrorIs(t, err, ErrUnavailable)
+	assert.Equal(t, int32(3), calls.Load(), "one attempt plus two retries")
+}
+
+func TestNoRetry(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if r.Method == http.MethodPost {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	err := NewACKClient(testConfig(server)).ACKAlarm(context.Background(), "id")
+	assert.ErrorIs(t, err, ErrUnavailable)
+	assert.Equal(t, int32(1), calls.Load(), "a POST is not idempotent and must not be retried")
+
+	calls.Store(0)
+	_, err = NewAlarmClient(testConfig(server)).GetAlarm(context.Background(), "id")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Equal(t, int32(1), calls.Load(), "a 404 will not change on retry")
+}
+
+func TestTimeoutAndContext(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	config := testConfig(server)
+	config.Timeout = 20 * time.Millisecond
+	config.MaxRetries = -1
+	_, err := NewAlarmClient(config).GetAlarm(context.Background(), "id")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = NewAlarmClient(testConfig(server)).GetAlarm(ctx, "id")
+	assert.True(t, errors.Is(err, context.Canceled))
+}
+
+func TestBaseURLFromEnv(t *testing.T) {
+	t.Setenv("HOST", "alarms.internal")
+	t.Setenv("ALARM_SERVICE_PORT", "8080")
+	t.Setenv("ALARM_SERVICE_URL", "")
+	assert.Equal(t, "http://alarms.internal:8080", BaseURLFromEnv("ALARM_SERVICE_URL", "ALARM_SERVICE_PORT"))
+
+	t.Setenv("ALARM_SERVICE_URL", "https://alarms.example.com")
+	assert.Equal(t, "https://alarms.example.com", BaseURLFromEnv("ALARM_SERVICE_URL", "ALARM_SERVICE_PORT"))
+}
@@ -0,0 +1,97 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// Errors an HTTPError matches with errors.Is, by status code
+var (
+	ErrBadRequest   = errors.New("bad request")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrNotFound     = errors.New("not found")
+	ErrConflict     = errors.New("conflict")
+	ErrRateLimited  = errors.New("rate limited")
+	ErrServer       = errors.New("server error")
+	ErrUnavailable  = errors.New("service unavailable")
+)
+
+// HTTPError is returned for any response outside 2xx
+type HTTPError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	// Message is the "error" field of the response body, or the raw body
+	Message string
+	Details string
+}
+
+func newHTTPError(method, url string, statusCode int, body []byte) *HTTPError {
+	httpErr := &HTTPError{Method: method, URL: url, StatusCode: statusCode}
+
+	var apiErr struct {
+		Error   string `json:"error"`
+		Details string `json:"details"`
+	}
+	if json.Unmarshal(body, &apiErr) == nil && apiErr.Error != "" {
+		httpErr.Message, httpErr.Details = apiErr.Error, apiErr.Details
+	} else {
+		httpErr.Message = string(body)
+	}
+	return httpErr
+}
+
+func (e *HTTPError) Error() string {
+	msg := fmt.Sprintf("%s %s: %d %s", e.Method, e.URL, e.StatusCode, http.StatusText(e.StatusCode))
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	if e.Details != "" {
+		msg += " (" + e.Details + ")"
+	}
+	return msg
+}
+
+// Unwrap maps the status code to one of the package errors
+func (e *HTTPError) Unwrap() error {
+	switch {
+	case e.StatusCode == http.StatusBadRequest || e.StatusCode == http.StatusUnprocessableEntity:
+		return ErrBadRequest
+	case e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden:
+		return ErrUnauthorized
+	case e.StatusCode == http.StatusNotFound:
+		return ErrNotFound
+	case e.StatusCode == http.StatusConflict:
+		return ErrConflict
+	case e.StatusCode == http.StatusTooManyRequests:
+		return ErrRateLimited
+	case e.StatusCode == http.StatusBadGateway ||
+		e.StatusCode == http.StatusServiceUnavailable ||
+		e.StatusCode == http.StatusGatewayTimeout:
+		return ErrUnavailable
+	case e.StatusCode >= 500:
+		return ErrServer
+	}
+	return nil
+}
+
+// retryable reports whether another attempt may succeed
+func retryable(err error) bool {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return errors.Is(err, ErrUnavailable) || errors.Is(err, ErrRateLimited)
+	}
+	// The caller gave up, only a per attempt timeout is worth retrying
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
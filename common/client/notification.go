@@ -0,0 +1,32 @@
+package client
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/26christy/CarbonQuest/common/models"
+)
+
+// NotificationClient calls the notification-service API
+type NotificationClient struct {
+	base
+}
+
+func NewNotificationClient(config Config) *NotificationClient {
+	return &NotificationClient{base: newBase(config)}
+}
+
+// Notify sends a notification for the alarm through every registered notifier
+func (c *NotificationClient) Notify(ctx context.Context, alarm models.AlarmEvent) error {
+	return c.do(ctx, http.MethodPost, "/notify/", nil, alarm, nil)
+}
+
+// SendAlarmChange delivers an alarm change event, receivers drop event IDs they have already seen
+func (c *NotificationClient) SendAlarmChange(ctx context.Context, event models.AlarmHistoryEntry) error {
+	return c.do(ctx, http.MethodPost, "/notify/events", nil, event, nil)
+}
+
+// RegisterNotifier adds a notifier to notification-service
+func (c *NotificationClient) RegisterNotifier(ctx context.Context, config models.NotifierConfig) error {
+	return c.do(ctx, http.MethodPost, "/notify/register-notifier", nil, config, nil)
+}
@@ -0,0 +1,73 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// TimeFormat stores timestamps as fixed width UTC text so that
+// lexical ordering in SQL matches chronological ordering.
+const TimeFormat = "2006-01-02T15:04:05.000000000Z07:00"
+
+// OpenSQLite opens (or creates) the database file at path and applies
+// the migrations that have not run yet. Migrations are applied in order,
+// each exactly once, so callers must only ever append to their list.
+func OpenSQLite(path string, migrations []string) (*sql.DB, error) {
+	dsn := fmt.Sprintf("file:%s?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)", path)
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	// sqlite allows a single writer, serialize access through one connection
+	db.SetMaxOpenConns(1)
+
+	if err := migrate(db, migrations); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to migrate database: %w", err)
+	}
+	return db, nil
+}
+
+// migrate brings the schema up to the latest version
+func migrate(db *sql.DB, migrations []string) error {
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY)`); err != nil {
+		return err
+	}
+
+	var current int
+	if err := db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+		return err
+	}
+
+	for i := current; i < len(migrations); i++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(migrations[i]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d: %w", i+1, err)
+		}
+		if _, err := tx.Exec(`INSERT INTO schema_migrations (version) VALUES (?)`, i+1); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FormatTime converts t to its stored representation
+func FormatTime(t time.Time) string {
+	return t.UTC().Format(TimeFormat)
+}
+
+// ParseTime reverses FormatTime
+func ParseTime(s string) (time.Time, error) {
+	return time.Parse(TimeFormat, s)
+}
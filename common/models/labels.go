@@ -0,0 +1,82 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// labelKeyPattern is the format of label and annotation keys, such as team, app.kubernetes.io/name or runbook_url
+var labelKeyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_./-]{0,62}$`)
+
+// ValidLabelKey reports whether key can name a label or an annotation
+func ValidLabelKey(key string) bool {
+	return labelKeyPattern.MatchString(key)
+}
+
+// Fingerprint identifies an alarm by its name and labels, the order of the labels does not matter
+func Fingerprint(name string, labels map[string]string) string {
+	if len(labels) == 0 {
+		labels = nil
+	}
+	// json sorts the map keys, and quoting keeps the name and labels apart
+	raw, _ := json.Marshal([]any{name, labels})
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// Label selector operators
+const (
+	LabelEquals    = "="
+	LabelNotEquals = "!="
+	LabelExists    = "exists"
+	LabelNotExists = "!exists"
+)
+
+// LabelSelector is one requirement on the labels of an alarm, written as
+// "key=value", "key!=value", "key" for alarms that have the label
+// or "!key" for alarms that do not. An alarm without the label matches "key!=value".
+type LabelSelector struct {
+	Key   string
+	Op    string
+	Value string
+}
+
+// ParseLabelSelector parses a selector written as in LabelSelector
+func ParseLabelSelector(selector string) (LabelSelector, error) {
+	var parsed LabelSelector
+	switch key, value, found := strings.Cut(selector, "="); {
+	case found && strings.HasSuffix(key, "!"):
+		parsed = LabelSelector{Key: strings.TrimSuffix(key, "!"), Op: LabelNotEquals, Value: value}
+	case found:
+		parsed = LabelSelector{Key: key, Op: LabelEquals, Value: value}
+	case strings.HasPrefix(selector, "!"):
+		parsed = LabelSelector{Key: strings.TrimPrefix(selector, "!"), Op: LabelNotExists}
+	default:
+		parsed = LabelSelector{Key: selector, Op: LabelExists}
+	}
+
+	parsed.Key = strings.TrimSpace(parsed.Key)
+	if !ValidLabelKey(parsed.Key) {
+		return LabelSelector{}, errors.New("invalid label selector " + selector + ", expected key=value, key!=value, key or !key")
+	}
+	return parsed, nil
+}
+
+// Matches reports whether the labels meet the selector
+func (l LabelSelector) Matches(labels map[string]string) bool {
+	value, exists := labels[l.Key]
+	switch l.Op {
+	case LabelEquals:
+		return exists && value == l.Value
+	case LabelNotEquals:
+		return !exists || value != l.Value
+	case LabelNotExists:
+		return !exists
+	default:
+		return exists
+	}
+}
@@ -1,6 +1,8 @@
 package models
 
 import (
+	"net/url"
+	"slices"
 	"time"
 
 	"github.com/google/uuid"
@@ -11,24 +13,109 @@ type Alarm struct {
 	Name      string    `json:"name" validate:"required,min=3,max=100"`
 	Timestamp time.Time `json:"timestamp" validate:"required"`
 	Status    string    `json:"status"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	Severity  string    `json:"severity" validate:"omitempty,oneof=critical major minor warning info"`
+	// Priority ranks alarms from 1, the most urgent, to 5
+	Priority int `json:"priority" validate:"min=0,max=5"`
+	// Labels identify the alarm and can be selected on, such as team=db or region=eu-west
+	Labels map[string]string `json:"labels,omitempty" validate:"max=20,dive,keys,label_key,endkeys,max=200"`
+	// Annotations carry free-form details such as a runbook URL or a description
+	Annotations map[string]string `json:"annotations,omitempty" validate:"max=20,dive,keys,label_key,endkeys,max=2000"`
+	// Fingerprint identifies the condition the alarm is raised for, see Fingerprint
+	Fingerprint string `json:"fingerprint"`
+	// Occurrences counts the creates of the alarm, including those deduplicated into it
+	Occurrences int       `json:"occurrences"`
+	LastSeenAt  time.Time `json:"last_seen_at"`
+	// ResolvedAt, ResolveReason and ResolveNote are set once the alarm is resolved
+	ResolvedAt    *time.Time `json:"resolved_at,omitempty"`
+	ResolveReason string     `json:"resolve_reason,omitempty"`
+	ResolveNote   string     `json:"resolve_note,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// Resolve marks the alarm as resolved at the time, for the reason or for ResolveFixed without one
+func (a *Alarm) Resolve(reason, note string, at time.Time) {
+	if reason == "" {
+		reason = ResolveFixed
+	}
+	a.Status = "resolved"
+	a.ResolvedAt = &at
+	a.ResolveReason = reason
+	a.ResolveNote = note
 }
 
 type UpdateAlarm struct {
 	ID        uuid.UUID `json:"id"`
 	Name      string    `json:"name"`
 	Timestamp time.Time `json:"timestamp"`
-	Status    string    `json:"status" validate:"oneof=triggered active ACK"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	Status    string    `json:"status" validate:"omitempty,oneof=triggered active ACK resolved closed"`
+	Severity  string    `json:"severity" validate:"omitempty,oneof=critical major minor warning info"`
+	Priority  int       `json:"priority" validate:"min=0,max=5"`
+	// ResolveReason and ResolveNote are kept when the update resolves the alarm
+	ResolveReason string `json:"resolve_reason" validate:"omitempty,oneof=fixed false_positive duplicate wont_fix"`
+	ResolveNote   string `json:"resolve_note" validate:"max=500"`
+	// Labels and Annotations replace those of the alarm when set, an empty map removes them
+	Labels      map[string]string `json:"labels" validate:"max=20,dive,keys,label_key,endkeys,max=200"`
+	Annotations map[string]string `json:"annotations" validate:"max=20,dive,keys,label_key,endkeys,max=2000"`
+	CreatedAt   time.Time         `json:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+}
+
+// OpenStatuses are the statuses of an alarm that is still open, a create
+// with the fingerprint of an open alarm is deduplicated into it. An open
+// alarm is resolved once its condition is gone, and a resolved alarm is
+// closed when nothing more is to be done about it.
+var OpenStatuses = []string{"triggered", "active", "ACK"}
+
+// Reasons an alarm is resolved for, ResolveQuiet is set by auto-resolve
+const (
+	ResolveFixed         = "fixed"
+	ResolveFalsePositive = "false_positive"
+	ResolveDuplicate     = "duplicate"
+	ResolveWontFix       = "wont_fix"
+	ResolveQuiet         = "quiet"
+)
+
+// ResolveAlarm is the body of POST /alarms/{id}/resolve, the reason defaults to fixed
+type ResolveAlarm struct {
+	Reason string `json:"reason" validate:"omitempty,oneof=fixed false_positive duplicate wont_fix"`
+	Note   string `json:"note" validate:"max=500"`
+}
+
+// Alarm severities, from the most to the least severe
+const (
+	SeverityCritical = "critical"
+	SeverityMajor    = "major"
+	SeverityMinor    = "minor"
+	SeverityWarning  = "warning"
+	SeverityInfo     = "info"
+)
+
+// Severities lists the alarm severities from the most to the least severe
+var Severities = []string{SeverityCritical, SeverityMajor, SeverityMinor, SeverityWarning, SeverityInfo}
+
+// DefaultSeverity is the severity of an alarm created without one
+const DefaultSeverity = SeverityMajor
+
+// SeverityRank returns 1 for critical up to 5 for info, and 0 for an unknown severity
+func SeverityRank(severity string) int {
+	return slices.Index(Severities, severity) + 1
+}
+
+// DefaultPriority is the priority of an alarm created without one, the rank of its severity
+func DefaultPriority(severity string) int {
+	return SeverityRank(severity)
 }
 
 type AlarmEvent struct {
-	AlarmID   string    `json:"alarm_id" validate:"required"`
-	Name      string    `json:"name" validate:"required"`
-	Type      string    `json:"type" validate:"required"`
-	Timestamp time.Time `json:"timestamp" validate:"required"`
+	AlarmID     string            `json:"alarm_id" validate:"required"`
+	Name        string            `json:"name" validate:"required"`
+	Type        string            `json:"type" validate:"required"`
+	Timestamp   time.Time         `json:"timestamp" validate:"required"`
+	Severity    string            `json:"severity,omitempty" validate:"omitempty,oneof=critical major minor warning info"`
+	Priority    int               `json:"priority,omitempty" validate:"min=0,max=5"`
+	Labels      map[string]string `json:"labels,omitempty" validate:"max=20,dive,keys,label_key,endkeys,max=200"`
+	Annotations map[string]string `json:"annotations,omitempty" validate:"max=20,dive,keys,label_key,endkeys,max=2000"`
 }
 
 type ACKState struct {
@@ -37,3 +124,297 @@ type ACKState struct {
 	NextNotificationAt time.Time `json:"next_notification_at"`
 	ShouldNotify       bool      `json:"should_notify"`
 }
+
+// PendingACK marks an acknowledgement that was started but not yet confirmed,
+// PreviousStatus is what the alarm is restored to if the ACK is rolled back
+type PendingACK struct {
+	AlarmID        string    `json:"alarm_id"`
+	PreviousStatus string    `json:"previous_status"`
+	MarkedAt       time.Time `json:"marked_at"`
+}
+
+type NotificationState struct {
+	FirstNotificationSent bool      `json:"first_notification_sent"`
+	LastNotificationAt    time.Time `json:"last_notification_at"`
+	// Reminders counts the notifications sent after the first one
+	Reminders int `json:"reminders"`
+	// RecoverySent is set once the alarm was resolved and the recovery notification went out
+	RecoverySent bool `json:"recovery_sent"`
+}
+
+// NotifierConfig describes a registered notifier. Type and Param identify
+// what it sends to, so no two notifiers share them.
+type NotifierConfig struct {
+	ID      uuid.UUID `json:"id"`
+	Name    string    `json:"name"`
+	Type    string    `json:"type"`
+	Param   string    `json:"param"`
+	Enabled bool      `json:"enabled"`
+	// MaxAttempts limits the delivery attempts per notification, 0 uses the service default
+	MaxAttempts int `json:"max_attempts,omitempty"`
+	// Rules select the alarms sent through the notifier, without rules it receives every alarm
+	Rules []RoutingRule `json:"rules,omitempty" validate:"max=50,dive"`
+	// Template replaces the default payload of the notifier
+	Template *NotificationTemplate `json:"template,omitempty"`
+	// Secret signs webhook payloads, it is stored but never returned by the API
+	Secret string `json:"-"`
+	// HTTP configures the requests of a webhook notifier
+	HTTP *HTTPOptions `json:"http,omitempty"`
+	// Breaker overrides the circuit breaker settings of the service for this notifier
+	Breaker *BreakerSettings `json:"breaker,omitempty"`
+	// Circuit is the current state of the circuit breaker, it is reported by the API and not stored
+	Circuit *CircuitStatus `json:"circuit,omitempty"`
+}
+
+// BreakerSettings configure a circuit breaker, zero values use the service defaults
+type BreakerSettings struct {
+	// FailureThreshold is the number of failed attempts in a row that opens the circuit
+	FailureThreshold int `json:"failure_threshold,omitempty" validate:"min=0,max=1000"`
+	// OpenSeconds is how long the circuit stays open before a trial delivery is let through
+	OpenSeconds int `json:"open_seconds,omitempty" validate:"min=0,max=86400"`
+	// HalfOpenSuccesses is the number of successful trial deliveries that close the circuit
+	HalfOpenSuccesses int `json:"half_open_successes,omitempty" validate:"min=0,max=100"`
+}
+
+// Circuit breaker states. A closed circuit lets deliveries through, an open circuit
+// rejects them and a half open circuit lets one trial delivery through at a time.
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half_open"
+)
+
+// CircuitStatus is the state of the circuit breaker of a notifier
+type CircuitStatus struct {
+	State string `json:"state"`
+	// ConsecutiveFailures counts the failed attempts since the last success
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	OpenedAt            *time.Time `json:"opened_at,omitempty"`
+	// RetryAt is when an open circuit lets the next trial delivery through
+	RetryAt *time.Time `json:"retry_at,omitempty"`
+}
+
+// HTTPOptions configure how a webhook notifier makes its requests. Certificates and
+// the client key are PEM encoded. The bearer token and client key are write only.
+type HTTPOptions struct {
+	// Method is POST by default
+	Method  string            `json:"method,omitempty" validate:"omitempty,oneof=POST PUT PATCH"`
+	Headers map[string]string `json:"headers,omitempty" validate:"max=20,dive,keys,required,max=100,endkeys,max=1000"`
+	// BearerToken is sent in the Authorization header
+	BearerToken string `json:"bearer_token,omitempty" validate:"max=4096"`
+	// TimeoutSeconds bounds a request, NOTIFY_TIMEOUT still applies when it is shorter
+	TimeoutSeconds int `json:"timeout_seconds,omitempty" validate:"min=0,max=300"`
+	// AcceptedStatuses are the response statuses that count as delivered, any 2xx by default
+	AcceptedStatuses []int  `json:"accepted_statuses,omitempty" validate:"max=20,dive,min=100,max=599"`
+	CACert           string `json:"ca_cert,omitempty" validate:"max=65536"`
+	ClientCert       string `json:"client_cert,omitempty" validate:"max=65536"`
+	ClientKey        string `json:"client_key,omitempty" validate:"max=65536"`
+}
+
+// Redacted returns a copy of the config without the write only credentials.
+// Header values may carry credentials too, such as an API key, so only the
+// header names are kept.
+func (c NotifierConfig) Redacted() NotifierConfig {
+	c.Secret = ""
+	if c.HTTP != nil {
+		options := *c.HTTP
+		options.BearerToken = ""
+		options.ClientKey = ""
+		options.Headers = redactedHeaders(options.Headers)
+		c.HTTP = &options
+	}
+	if c.Template != nil {
+		template := *c.Template
+		template.Headers = redactedHeaders(template.Headers)
+		c.Template = &template
+	}
+	return c
+}
+
+func redactedHeaders(headers map[string]string) map[string]string {
+	if headers == nil {
+		return nil
+	}
+	redacted := make(map[string]string, len(headers))
+	for name := range headers {
+		redacted[name] = "REDACTED"
+	}
+	return redacted
+}
+
+// RedactedParam returns the param with the path and query of a URL left out, Slack
+// and Teams keep the credential of their webhooks there. Params that are not URLs,
+// like email addresses, are returned as they are.
+func (c NotifierConfig) RedactedParam() string {
+	target, err := url.Parse(c.Param)
+	if err != nil {
+		return "REDACTED"
+	}
+	if target.Scheme == "" || target.Host == "" {
+		return c.Param
+	}
+	redacted := target.Scheme + "://" + target.Host
+	if target.User != nil || (target.Path != "" && target.Path != "/") || target.RawQuery != "" || target.Fragment != "" {
+		redacted += "/REDACTED"
+	}
+	return redacted
+}
+
+// NotificationTemplate is the body and headers a notifier sends, written as Go
+// text/template. Headers are only sent by notifiers that make a request,
+// the HTML body only by the email notifier.
+type NotificationTemplate struct {
+	Body    string            `json:"body,omitempty"`
+	HTML    string            `json:"html,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// RoutingRule matches an alarm when every field that is set matches.
+// Name is a glob pattern such as "db-*", Labels must all be present with the same value.
+// MinSeverity matches alarms of that severity or a more severe one.
+type RoutingRule struct {
+	Name        string            `json:"name,omitempty" validate:"max=100"`
+	Statuses    []string          `json:"statuses,omitempty" validate:"dive,oneof=triggered active ACK resolved closed"`
+	Severities  []string          `json:"severities,omitempty" validate:"dive,oneof=critical major minor warning info"`
+	MinSeverity string            `json:"min_severity,omitempty" validate:"omitempty,oneof=critical major minor warning info"`
+	Labels      map[string]string `json:"labels,omitempty" validate:"dive,keys,required,max=100,endkeys,max=200"`
+}
+
+// Routing policies, fan out sends an alarm to every matching notifier
+// while first match sends it only to the first notifier whose rules match
+const (
+	RoutingFanOut     = "fan_out"
+	RoutingFirstMatch = "first_match"
+)
+
+// DeadLetter is a notification that kept failing after every attempt
+type DeadLetter struct {
+	ID        uuid.UUID      `json:"id"`
+	Alarm     AlarmEvent     `json:"alarm"`
+	Notifier  NotifierConfig `json:"notifier"`
+	Attempts  int            `json:"attempts"`
+	LastError string         `json:"last_error"`
+	FailedAt  time.Time      `json:"failed_at"`
+}
+
+// DeliveryRecord is the receipt of one attempt to send a notification.
+// StatusCode is the response status of notifiers that make a request, 0 otherwise.
+type DeliveryRecord struct {
+	ID            uuid.UUID `json:"id"`
+	AlarmID       string    `json:"alarm_id"`
+	NotifierID    uuid.UUID `json:"notifier_id"`
+	NotifierType  string    `json:"notifier_type"`
+	NotifierParam string    `json:"notifier_param"`
+	Attempt       int       `json:"attempt"`
+	LatencyMS     int64     `json:"latency_ms"`
+	StatusCode    int       `json:"status_code,omitempty"`
+	Delivered     bool      `json:"delivered"`
+	Error         string    `json:"error,omitempty"`
+	Timestamp     time.Time `json:"timestamp"`
+}
+
+// DeliveryQuery filters the delivery log, which is returned newest first.
+// The time range is half open, from is inclusive and to exclusive.
+type DeliveryQuery struct {
+	AlarmID       string    `form:"alarm_id"`
+	NotifierID    string    `form:"notifier_id" validate:"omitempty,uuid"`
+	NotifierType  string    `form:"notifier_type"`
+	NotifierParam string    `form:"notifier_param"`
+	Status        string    `form:"status" validate:"omitempty,oneof=delivered failed"`
+	From          time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	To            time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+	Limit         int       `form:"limit" validate:"min=0,max=1000"`
+}
+
+// AlarmQuery filters, orders and pages the alarm list.
+// Ranges are half open, the _from bound is inclusive and the _to bound exclusive.
+// An alarm matches Severities and Priorities when it has any of them,
+// and Labels when it meets every selector in it.
+type AlarmQuery struct {
+	Status        string       `form:"status" validate:"omitempty,oneof=triggered active ACK resolved closed"`
+	Name          string       `form:"name" validate:"max=100"`
+	Severities    []string     `form:"severity" validate:"max=5,dive,oneof=critical major minor warning info"`
+	Priorities    []int        `form:"priority" validate:"max=5,dive,min=1,max=5"`
+	Labels        []string     `form:"label" validate:"max=20"`
+	TimestampFrom time.Time    `form:"timestamp_from" time_format:"2006-01-02T15:04:05Z07:00"`
+	TimestampTo   time.Time    `form:"timestamp_to" time_format:"2006-01-02T15:04:05Z07:00"`
+	CreatedFrom   time.Time    `form:"created_from" time_format:"2006-01-02T15:04:05Z07:00"`
+	CreatedTo     time.Time    `form:"created_to" time_format:"2006-01-02T15:04:05Z07:00"`
+	LastSeenFrom  time.Time    `form:"last_seen_from" time_format:"2006-01-02T15:04:05Z07:00"`
+	LastSeenTo    time.Time    `form:"last_seen_to" time_format:"2006-01-02T15:04:05Z07:00"`
+	SortBy        string       `form:"sort_by" validate:"omitempty,oneof=created_at updated_at timestamp name priority"`
+	Order         string       `form:"order" validate:"omitempty,oneof=asc desc"`
+	Limit         int          `form:"limit" validate:"min=0,max=1000"`
+	Cursor        string       `form:"cursor"`
+	After         *AlarmCursor `form:"-"`
+	// LabelSelectors are the parsed Labels
+	LabelSelectors []LabelSelector `form:"-"`
+}
+
+// AlarmCursor marks the last alarm of a page, the next page starts right after it
+type AlarmCursor struct {
+	SortBy string `json:"sort_by"`
+	Order  string `json:"order"`
+	Key    string `json:"key"`
+	ID     string `json:"id"`
+}
+
+type AlarmPage struct {
+	Alarms []Alarm
+	Next   *AlarmCursor
+}
+
+// Actions recorded in the alarm history
+const (
+	AlarmCreated       = "created"
+	AlarmUpdated       = "updated"
+	AlarmStatusChanged = "status_changed"
+	AlarmDeduplicated  = "deduplicated"
+	AlarmDeleted       = "deleted"
+)
+
+// Actor identifies who made a change and from which service
+type Actor struct {
+	Name   string `json:"actor"`
+	Source string `json:"source"`
+}
+
+// AlarmHistoryEntry is one append-only record of a change to an alarm.
+// OldValue is nil on create and NewValue is nil on delete.
+type AlarmHistoryEntry struct {
+	ID        uuid.UUID `json:"id"`
+	AlarmID   uuid.UUID `json:"alarm_id"`
+	Action    string    `json:"action"`
+	Actor     string    `json:"actor"`
+	Source    string    `json:"source"`
+	OldValue  *Alarm    `json:"old_value"`
+	NewValue  *Alarm    `json:"new_value"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// OutboxEntry is a change event waiting to be delivered to subscribers.
+// Seq orders the outbox, subscribers keep their position in it as a cursor.
+type OutboxEntry struct {
+	Seq   int64             `json:"seq"`
+	Event AlarmHistoryEntry `json:"event"`
+}
+
+// OutboxCursor is how far one subscriber got through the outbox, it has taken
+// every event up to Seq. Attempts, NextAttemptAt and LastError describe the
+// next event while the subscriber keeps failing it.
+type OutboxCursor struct {
+	Subscriber    string    `json:"subscriber"`
+	Seq           int64     `json:"seq"`
+	Attempts      int       `json:"attempts"`
+	NextAttemptAt time.Time `json:"next_attempt_at"`
+	LastError     string    `json:"last_error"`
+}
+
+// OutboxDeadLetter is an event a subscriber still failed after the last attempt
+type OutboxDeadLetter struct {
+	Subscriber string            `json:"subscriber"`
+	Event      AlarmHistoryEntry `json:"event"`
+	Attempts   int               `json:"attempts"`
+	LastError  string            `json:"last_error"`
+	FailedAt   time.Time         `json:"failed_at"`
+}
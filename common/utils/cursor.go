@@ -0,0 +1,24 @@
+package utils
+
+import (
+	"encoding/base64"
+	"encoding/json"
+)
+
+// EncodeCursor serializes a pagination position into an opaque, URL safe token
+func EncodeCursor(v interface{}) (string, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// DecodeCursor reverses EncodeCursor
+func DecodeCursor(token string, v interface{}) error {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, v)
+}
@@ -1,10 +1,20 @@
 package utils
 
 import (
+	"github.com/26christy/CarbonQuest/common/models"
 	"github.com/go-playground/validator/v10"
 )
 
-var validate = validator.New()
+var validate = newValidator()
+
+// newValidator registers the tags the models use on top of the built in ones
+func newValidator() *validator.Validate {
+	v := validator.New()
+	v.RegisterValidation("label_key", func(fl validator.FieldLevel) bool {
+		return models.ValidLabelKey(fl.Field().String())
+	})
+	return v
+}
 
 func ValidateStruct(s interface{}) error {
 	return validate.Struct(s)
@@ -0,0 +1,154 @@
+// Package webhooksig signs webhook payloads and verifies them on the receiving end.
+// The sender signs the delivery ID, the timestamp and the body with HMAC-SHA256
+// and a shared secret. A receiver rejects requests with a bad signature or an
+// old timestamp, and drops deliveries it has already handled.
+package webhooksig
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Headers set on a signed webhook request
+const (
+	// SignatureHeader carries "sha256=" and the hex HMAC of the delivery ID, the timestamp and the body, joined by dots
+	SignatureHeader = "X-CarbonQuest-Signature"
+	// TimestampHeader carries the Unix time in seconds the request was signed at
+	TimestampHeader = "X-CarbonQuest-Timestamp"
+	// DeliveryHeader carries the delivery ID, it stays the same when a delivery is retried
+	DeliveryHeader = "X-CarbonQuest-Delivery"
+)
+
+// DefaultTolerance is how far the timestamp may be from the receiver's clock
+const DefaultTolerance = 5 * time.Minute
+
+// signaturePrefix names the algorithm in the signature header
+const signaturePrefix = "sha256="
+
+var (
+	ErrMissingHeaders   = errors.New("webhook signature, timestamp or delivery header is missing")
+	ErrInvalidTimestamp = errors.New("webhook timestamp is not a Unix time")
+	ErrExpired          = errors.New("webhook timestamp is outside the tolerance")
+	ErrInvalidSignature = errors.New("webhook signature does not match")
+	ErrReplayed         = errors.New("webhook delivery was already handled")
+)
+
+// Sign returns the signature header value of body sent as deliveryID at timestamp
+func Sign(secret, deliveryID string, timestamp time.Time, body []byte) string {
+	return signaturePrefix + hex.EncodeToString(mac(secret, deliveryID, strconv.FormatInt(timestamp.Unix(), 10), body))
+}
+
+// SetHeaders signs body and sets the signature, timestamp and delivery headers
+func SetHeaders(header http.Header, secret, deliveryID string, timestamp time.Time, body []byte) {
+	header.Set(SignatureHeader, Sign(secret, deliveryID, timestamp, body))
+	header.Set(TimestampHeader, strconv.FormatInt(timestamp.Unix(), 10))
+	header.Set(DeliveryHeader, deliveryID)
+}
+
+// Verify checks the signature of body and that its timestamp is within tolerance of now.
+// It does not detect deliveries that were already handled, use a Verifier for that.
+func Verify(secret string, header http.Header, body []byte, tolerance time.Duration, now time.Time) error {
+	signature, timestamp, deliveryID := header.Get(SignatureHeader), header.Get(TimestampHeader), header.Get(DeliveryHeader)
+	if signature == "" || timestamp == "" || deliveryID == "" {
+		return ErrMissingHeaders
+	}
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidTimestamp
+	}
+	if skew := now.Sub(time.Unix(unix, 0)); skew > tolerance || skew < -tolerance {
+		return ErrExpired
+	}
+
+	hexMAC, found := strings.CutPrefix(signature, signaturePrefix)
+	got, err := hex.DecodeString(hexMAC)
+	if !found || err != nil || !hmac.Equal(got, mac(secret, deliveryID, timestamp, body)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// mac is the HMAC-SHA256 of the delivery ID, the timestamp and the body, joined by dots
+func mac(secret, deliveryID, timestamp string, body []byte) []byte {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(deliveryID + "." + timestamp + "."))
+	h.Write(body)
+	return h.Sum(nil)
+}
+
+// Verifier verifies signed requests and rejects deliveries marked as handled with Done.
+// A replayed request is either too old or carries the ID of a handled delivery,
+// while a retry of a delivery the receiver failed to handle is still accepted.
+// It is safe for concurrent use.
+type Verifier struct {
+	secret    string
+	tolerance time.Duration
+	now       func() time.Time
+
+	mu sync.Mutex
+	// handled maps delivery IDs to when they can be forgotten,
+	// by then a replay of the delivery fails the timestamp check
+	handled map[string]time.Time
+}
+
+// NewVerifier returns a Verifier for secret, a tolerance of 0 uses DefaultTolerance
+func NewVerifier(secret string, tolerance time.Duration) *Verifier {
+	if tolerance <= 0 {
+		tolerance = DefaultTolerance
+	}
+	return &Verifier{secret: secret, tolerance: tolerance, now: time.Now, handled: make(map[string]time.Time)}
+}
+
+// Verify checks the request headers and body and returns the delivery ID
+func (v *Verifier) Verify(header http.Header, body []byte) (string, error) {
+	now := v.now()
+	if err := Verify(v.secret, header, body, v.tolerance, now); err != nil {
+		return "", err
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	deliveryID := header.Get(DeliveryHeader)
+	if forgetAt, handled := v.handled[deliveryID]; handled && now.Before(forgetAt) {
+		return deliveryID, ErrReplayed
+	}
+	return deliveryID, nil
+}
+
+// VerifyRequest reads and verifies the body of r and returns the delivery ID.
+// The body is left readable on r, so a handler can decode it after the check.
+func (v *Verifier) VerifyRequest(r *http.Request) (string, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return "", err
+	}
+	r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	return v.Verify(r.Header, body)
+}
+
+// Done marks a delivery as handled, later requests with its ID are rejected as replays
+func (v *Verifier) Done(deliveryID string) {
+	now := v.now()
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	for id, forgetAt := range v.handled {
+		if !now.Before(forgetAt) {
+			delete(v.handled, id)
+		}
+	}
+	// A request signed up to tolerance ago, or ahead, is accepted for up to twice the tolerance
+	v.handled[deliveryID] = now.Add(2 * v.tolerance)
+}
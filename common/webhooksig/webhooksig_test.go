@@ -0,0 +1,117 @@
+package webhooksig
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const secret = "0123456789abcdef"
+
+func signed(deliveryID string, timestamp time.Time, body string) http.Header {
+	header := http.Header{}
+	SetHeaders(header, secret, deliveryID, timestamp, []byte(body))
+	return header
+}
+
+func TestVerify(t *testing.T) {
+	now := time.Now()
+	header := signed("d-1", now, `{"alarm_id":"123"}`)
+	assert.True(t, strings.HasPrefix(header.Get(SignatureHeader), "sha256="))
+	assert.Equal(t, strconv.FormatInt(now.Unix(), 10), header.Get(TimestampHeader))
+	assert.Equal(t, "d-1", header.Get(DeliveryHeader))
+
+	assert.NoError(t, Verify(secret, header, []byte(`{"alarm_id":"123"}`), DefaultTolerance, now))
+}
+
+func TestVerify_Rejects(t *testing.T) {
+	now := time.Now()
+	body := []byte(`{"alarm_id":"123"}`)
+
+	tests := []struct {
+		name   string
+		header func() http.Header
+		body   []byte
+		secret string
+		want   error
+	}{
+		{"missing headers", func() http.Header { return http.Header{} }, body, secret, ErrMissingHeaders},
+		{"tampered body", func() http.Header { return signed("d-1", now, string(body)) }, []byte(`{"alarm_id":"456"}`), secret, ErrInvalidSignature},
+		{"wrong secret", func() http.Header { return signed("d-1", now, string(body)) }, body, "another-secret-value", ErrInvalidSignature},
+		{"swapped delivery ID", func() http.Header {
+			header := signed("d-1", now, string(body))
+			header.Set(DeliveryHeader, "d-2")
+			return header
+		}, body, secret, ErrInvalidSignature},
+		{"changed timestamp", func() http.Header {
+			header := signed("d-1", now, string(body))
+			header.Set(TimestampHeader, strconv.FormatInt(now.Unix()+1, 10))
+			return header
+		}, body, secret, ErrInvalidSignature},
+		{"no algorithm", func() http.Header {
+			header := signed("d-1", now, string(body))
+			header.Set(SignatureHeader, strings.TrimPrefix(header.Get(SignatureHeader), "sha256="))
+			return header
+		}, body, secret, ErrInvalidSignature},
+		{"invalid timestamp", func() http.Header {
+			header := signed("d-1", now, string(body))
+			header.Set(TimestampHeader, "yesterday")
+			return header
+		}, body, secret, ErrInvalidTimestamp},
+		{"too old", func() http.Header { return signed("d-1", now.Add(-10*time.Minute), string(body)) }, body, secret, ErrExpired},
+		{"too far ahead", func() http.Header { return signed("d-1", now.Add(10*time.Minute), string(body)) }, body, secret, ErrExpired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorIs(t, Verify(tt.secret, tt.header(), tt.body, DefaultTolerance, now), tt.want)
+		})
+	}
+}
+
+func TestVerifier_Replay(t *testing.T) {
+	verifier := NewVerifier(secret, time.Minute)
+	now := time.Now()
+	verifier.now = func() time.Time { return now }
+
+	first := signed("d-1", now, "body")
+	deliveryID, err := verifier.Verify(first, []byte("body"))
+	require.NoError(t, err)
+	assert.Equal(t, "d-1", deliveryID)
+
+	// The receiver failed to handle the first request, the retry is accepted
+	_, err = verifier.Verify(signed("d-1", now, "body"), []byte("body"))
+	require.NoError(t, err)
+	verifier.Done(deliveryID)
+
+	_, err = verifier.Verify(first, []byte("body"))
+	assert.ErrorIs(t, err, ErrReplayed)
+	_, err = verifier.Verify(signed("d-2", now, "body"), []byte("body"))
+	assert.NoError(t, err, "another delivery should be accepted")
+
+	// Once the handled delivery is forgotten its replay is too old
+	now = now.Add(2*time.Minute + time.Second)
+	verifier.Done("d-2")
+	assert.NotContains(t, verifier.handled, "d-1")
+	_, err = verifier.Verify(first, []byte("body"))
+	assert.ErrorIs(t, err, ErrExpired)
+}
+
+func TestVerifier_VerifyRequest(t *testing.T) {
+	verifier := NewVerifier(secret, 0)
+	request := httptest.NewRequest(http.MethodPost, "/hook", strings.NewReader("body"))
+	request.Header = signed("d-1", time.Now(), "body")
+
+	deliveryID, err := verifier.VerifyRequest(request)
+	require.NoError(t, err)
+	assert.Equal(t, "d-1", deliveryID)
+
+	body := make([]byte, 4)
+	n, _ := request.Body.Read(body)
+	assert.Equal(t, "body", string(body[:n]), "the body should be readable again")
+}
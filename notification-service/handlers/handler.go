@@ -1,14 +1,18 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"reflect"
 
 	"github.com/26christy/CarbonQuest/common/models"
 	"github.com/26christy/CarbonQuest/common/utils"
 	"github.com/26christy/CarbonQuest/notification-service/notifiers"
 	"github.com/26christy/CarbonQuest/notification-service/service"
+	"github.com/26christy/CarbonQuest/notification-service/storage"
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
 // NotificationHandler handles API requests
@@ -24,8 +28,20 @@ func NewNotificationHandler(service service.NotificationService) *NotificationHa
 // RegisterNotifier API to register WebHooks
 func (h *NotificationHandler) RegisterNotifier(c *gin.Context) {
 	var request struct {
-		Type  string `json:"type" binding:"required"`
-		Param string `json:"param"` // URL for webhook, email for email notifier, etc.
+		Name        string               `json:"name" validate:"max=100"`
+		Type        string               `json:"type" binding:"required"`
+		Param       string               `json:"param"` // URL for webhook, recipients for email notifier, etc.
+		MaxAttempts int                  `json:"max_attempts" validate:"min=0,max=20"`
+		Enabled     *bool                `json:"enabled"`
+		Rules       []models.RoutingRule `json:"rules"`
+		// Template replaces the default payload, see PreviewTemplate
+		Template *models.NotificationTemplate `json:"template"`
+		// Secret signs the payloads of a webhook so the receiver can verify them
+		Secret string `json:"secret" validate:"omitempty,min=16,max=256"`
+		// HTTP sets the method, headers, timeout, accepted statuses and TLS of a webhook
+		HTTP *models.HTTPOptions `json:"http"`
+		// Breaker overrides the circuit breaker settings of the service
+		Breaker *models.BreakerSettings `json:"breaker"`
 	}
 
 	if err := c.ShouldBindJSON(&request); err != nil {
@@ -45,7 +61,20 @@ func (h *NotificationHandler) RegisterNotifier(c *gin.Context) {
 		return
 	}
 
-	notifier, err := notifiers.CreateNotifier(request.Type, request.Param)
+	config := models.NotifierConfig{
+		Name:        request.Name,
+		Type:        request.Type,
+		Param:       request.Param,
+		MaxAttempts: request.MaxAttempts,
+		Enabled:     request.Enabled == nil || *request.Enabled, // enabled unless asked otherwise
+		Rules:       request.Rules,
+		Template:    request.Template,
+		Secret:      request.Secret,
+		HTTP:        request.HTTP,
+		Breaker:     request.Breaker,
+	}
+
+	notifier, err := notifiers.CreateNotifier(config)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error":   "failed to create notifier instance",
@@ -54,8 +83,150 @@ func (h *NotificationHandler) RegisterNotifier(c *gin.Context) {
 		return
 	}
 
-	h.service.RegisterNotifier(notifier)
-	c.JSON(http.StatusOK, gin.H{"message": "Notifier registered successfully"})
+	config, err = h.service.AddNotifier(config, notifier)
+	if err != nil {
+		notifierError(c, "failed to register notifier", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Notifier registered successfully", "notifier": config.Redacted()})
+}
+
+// GetNotifiers lists the registered notifiers and the routing policy
+func (h *NotificationHandler) GetNotifiers(c *gin.Context) {
+	configs := h.service.GetNotifiers()
+	for i := range configs {
+		configs[i] = configs[i].Redacted()
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"notifiers":      configs,
+		"routing_policy": h.service.RoutingPolicy(),
+	})
+}
+
+// GetNotifier returns a single notifier
+func (h *NotificationHandler) GetNotifier(c *gin.Context) {
+	id, ok := parseID(c, "notifier")
+	if !ok {
+		return
+	}
+
+	config, err := h.service.GetNotifier(id)
+	if err != nil {
+		notifierError(c, "failed to fetch notifier", err)
+		return
+	}
+	c.JSON(http.StatusOK, config.Redacted())
+}
+
+// UpdateNotifier changes a notifier, fields left out of the request keep their value.
+// Set enabled to false to stop sending through a notifier without removing it.
+func (h *NotificationHandler) UpdateNotifier(c *gin.Context) {
+	id, ok := parseID(c, "notifier")
+	if !ok {
+		return
+	}
+
+	var request struct {
+		Name        string  `json:"name" validate:"max=100"`
+		Type        string  `json:"type"`
+		Param       *string `json:"param"`
+		MaxAttempts *int    `json:"max_attempts" validate:"omitempty,min=0,max=20"`
+		Enabled     *bool   `json:"enabled"`
+		// Rules replace the existing rules, an empty list removes them
+		Rules *[]models.RoutingRule `json:"rules"`
+		// Template replaces the existing template, an empty template removes it
+		Template *models.NotificationTemplate `json:"template"`
+		// Secret replaces the signing secret, an empty secret stops signing
+		Secret *string `json:"secret" validate:"omitempty,min=16,max=256"`
+		// HTTP replaces the HTTP options, empty options remove them
+		HTTP *models.HTTPOptions `json:"http"`
+		// Breaker replaces the circuit breaker settings, empty settings use the service defaults
+		Breaker *models.BreakerSettings `json:"breaker"`
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if err := utils.ValidateStruct(request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "request body validation failed",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	config, err := h.service.GetNotifier(id)
+	if err != nil {
+		notifierError(c, "failed to fetch notifier", err)
+		return
+	}
+
+	// Fill missing fields with existing values
+	if request.Name != "" {
+		config.Name = request.Name
+	}
+	if request.Type != "" {
+		config.Type = request.Type
+	}
+	if request.Param != nil {
+		config.Param = *request.Param
+	}
+	if request.MaxAttempts != nil {
+		config.MaxAttempts = *request.MaxAttempts
+	}
+	if request.Enabled != nil {
+		config.Enabled = *request.Enabled
+	}
+	if request.Rules != nil {
+		config.Rules = *request.Rules
+	}
+	if request.Template != nil {
+		config.Template = request.Template
+		if request.Template.Body == "" && request.Template.HTML == "" && len(request.Template.Headers) == 0 {
+			config.Template = nil
+		}
+	}
+	if request.Secret != nil {
+		config.Secret = *request.Secret
+	}
+	if request.HTTP != nil {
+		config.HTTP = request.HTTP
+		if reflect.ValueOf(*request.HTTP).IsZero() {
+			config.HTTP = nil
+		}
+	}
+	if request.Breaker != nil {
+		config.Breaker = request.Breaker
+		if *request.Breaker == (models.BreakerSettings{}) {
+			config.Breaker = nil
+		}
+	}
+
+	config, err = h.service.UpdateNotifier(config)
+	if err != nil {
+		notifierError(c, "failed to update notifier", err)
+		return
+	}
+	c.JSON(http.StatusOK, config.Redacted())
+}
+
+// DeleteNotifier removes a notifier
+func (h *NotificationHandler) DeleteNotifier(c *gin.Context) {
+	id, ok := parseID(c, "notifier")
+	if !ok {
+		return
+	}
+
+	if err := h.service.DeleteNotifier(id); err != nil {
+		notifierError(c, "failed to delete notifier", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Notifier deleted successfully"})
 }
 
 func (h *NotificationHandler) NotificationHandler(c *gin.Context) {
@@ -79,7 +250,219 @@ func (h *NotificationHandler) NotificationHandler(c *gin.Context) {
 	}
 
 	fmt.Println("[Notification Service] Received event:", alarmEvent)
-	h.service.SendNotification(alarmEvent)
+	h.service.SendNotification(c.Request.Context(), alarmEvent)
 
 	c.JSON(http.StatusOK, gin.H{"message": "Notification received successfully"})
 }
+
+// AlarmChangeHandler receives alarm change events pushed by alarm-service
+func (h *NotificationHandler) AlarmChangeHandler(c *gin.Context) {
+	var change models.AlarmHistoryEntry
+
+	if err := c.ShouldBindJSON(&change); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if change.ID == uuid.Nil || change.AlarmID == uuid.Nil || change.Action == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "event id, alarm_id and action are required",
+		})
+		return
+	}
+
+	h.service.HandleAlarmChange(change)
+	c.JSON(http.StatusOK, gin.H{"message": "Event processed successfully"})
+}
+
+// GetDeadLetters lists the notifications that failed every delivery attempt
+func (h *NotificationHandler) GetDeadLetters(c *gin.Context) {
+	deadLetters, err := h.service.GetDeadLetters()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "failed to fetch dead letters",
+			"details": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"dead_letters": deadLetters})
+}
+
+// GetDeadLetter returns a single dead letter
+func (h *NotificationHandler) GetDeadLetter(c *gin.Context) {
+	id, ok := parseID(c, "dead letter")
+	if !ok {
+		return
+	}
+
+	deadLetter, err := h.service.GetDeadLetter(id)
+	if err != nil {
+		deadLetterError(c, "failed to fetch dead letter", err)
+		return
+	}
+	c.JSON(http.StatusOK, deadLetter)
+}
+
+// ReplayDeadLetter sends a dead letter again, it is removed once delivered
+func (h *NotificationHandler) ReplayDeadLetter(c *gin.Context) {
+	id, ok := parseID(c, "dead letter")
+	if !ok {
+		return
+	}
+
+	if err := h.service.ReplayDeadLetter(c.Request.Context(), id); err != nil {
+		deadLetterError(c, "failed to replay dead letter", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Dead letter delivered successfully"})
+}
+
+// DiscardDeadLetter drops a dead letter without sending it
+func (h *NotificationHandler) DiscardDeadLetter(c *gin.Context) {
+	id, ok := parseID(c, "dead letter")
+	if !ok {
+		return
+	}
+
+	if err := h.service.DiscardDeadLetter(id); err != nil {
+		deadLetterError(c, "failed to discard dead letter", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Dead letter discarded successfully"})
+}
+
+// PreviewTemplate renders a template against the alarm in the request, or against
+// a sample alarm, so a template can be tried out before it is registered
+func (h *NotificationHandler) PreviewTemplate(c *gin.Context) {
+	var request struct {
+		Template models.NotificationTemplate `json:"template" binding:"required"`
+		Alarm    *models.AlarmEvent          `json:"alarm"`
+		Reminder int                         `json:"reminder" validate:"min=0"`
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if err := utils.ValidateStruct(request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "request body validation failed",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	tmpl, err := notifiers.ParseTemplate(request.Template)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "invalid template",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	alarm := notifiers.SampleAlarm()
+	if request.Alarm != nil {
+		alarm = *request.Alarm
+	}
+	ctx := notifiers.WithDelivery(c.Request.Context(), notifiers.Delivery{Reminder: request.Reminder})
+	rendered, err := tmpl.Render(ctx, alarm)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "failed to render template",
+			"details": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, rendered)
+}
+
+// GetDeliveries lists the delivery attempts, newest first, filtered by the query string
+func (h *NotificationHandler) GetDeliveries(c *gin.Context) {
+	var query models.DeliveryQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "invalid query parameters",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if err := utils.ValidateStruct(query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "query parameter validation failed",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	deliveries, err := h.service.GetDeliveries(query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "failed to fetch deliveries",
+			"details": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"deliveries": deliveries})
+}
+
+// GetMetrics reports the deliveries and circuit breaker state of every notifier
+func (h *NotificationHandler) GetMetrics(c *gin.Context) {
+	c.JSON(http.StatusOK, h.service.Metrics())
+}
+
+// parseID reads the :id path parameter, kind names the resource in the error
+func parseID(c *gin.Context, kind string) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "invalid " + kind + " id",
+			"details": err.Error(),
+		})
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// notifierError maps registry errors to a status code
+func notifierError(c *gin.Context, message string, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, storage.ErrNotifierNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, service.ErrDuplicateNotifier):
+		status = http.StatusConflict
+	case errors.Is(err, service.ErrInvalidNotifier), errors.Is(err, service.ErrInvalidRule):
+		status = http.StatusBadRequest
+	}
+	c.JSON(status, gin.H{
+		"error":   message,
+		"details": err.Error(),
+	})
+}
+
+// deadLetterError maps service errors to a status code
+func deadLetterError(c *gin.Context, message string, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, storage.ErrDeadLetterNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, service.ErrNotifierNotRegistered), errors.Is(err, service.ErrNotifierDisabled),
+		errors.Is(err, service.ErrCircuitOpen):
+		status = http.StatusConflict
+	case errors.Is(err, service.ErrDeliveryFailed):
+		status = http.StatusBadGateway
+	}
+	c.JSON(status, gin.H{
+		"error":   message,
+		"details": err.Error(),
+	})
+}
@@ -11,6 +11,18 @@ func RegisterRoutes(router *gin.Engine, notificationService service.Notification
 	notifyGroup := router.Group("/notify")
 	{
 		notifyGroup.POST("/register-notifier", handler.RegisterNotifier)
+		notifyGroup.GET("/notifiers", handler.GetNotifiers)
+		notifyGroup.GET("/notifiers/:id", handler.GetNotifier)
+		notifyGroup.PUT("/notifiers/:id", handler.UpdateNotifier)
+		notifyGroup.DELETE("/notifiers/:id", handler.DeleteNotifier)
+		notifyGroup.POST("/templates/preview", handler.PreviewTemplate)
 		notifyGroup.POST("/", handler.NotificationHandler)
+		notifyGroup.POST("/events", handler.AlarmChangeHandler)
+		notifyGroup.GET("/dead-letters", handler.GetDeadLetters)
+		notifyGroup.GET("/dead-letters/:id", handler.GetDeadLetter)
+		notifyGroup.POST("/dead-letters/:id/replay", handler.ReplayDeadLetter)
+		notifyGroup.DELETE("/dead-letters/:id", handler.DiscardDeadLetter)
+		notifyGroup.GET("/deliveries", handler.GetDeliveries)
+		notifyGroup.GET("/metrics", handler.GetMetrics)
 	}
 }
@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"errors"
+	"io"
 	"log"
 	"net/http"
 	"os"
@@ -9,10 +11,13 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/26christy/CarbonQuest/common/client"
+	"github.com/26christy/CarbonQuest/common/models"
 	"github.com/26christy/CarbonQuest/middleware"
 	"github.com/26christy/CarbonQuest/notification-service/handlers"
 	"github.com/26christy/CarbonQuest/notification-service/notifiers"
 	"github.com/26christy/CarbonQuest/notification-service/service"
+	"github.com/26christy/CarbonQuest/notification-service/storage"
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
 	"github.com/sirupsen/logrus"
@@ -35,10 +40,24 @@ func main() {
 		port = "8081"
 	}
 
-	// Use the default HTTP client
-	httpClient := &http.Client{}
+	// ALARM_SERVICE_URL overrides the alarm-service address built from HOST and ALARM_SERVICE_PORT
+	alarmClient := client.NewAlarmClient(client.Config{
+		BaseURL:     client.BaseURLFromEnv("ALARM_SERVICE_URL", "ALARM_SERVICE_PORT"),
+		ServiceName: os.Getenv("SERVICE_NAME"),
+	})
+
+	// Initialize storage, STORAGE_TYPE selects the backend (memory or sqlite)
+	store, err := storage.CreateStorage(os.Getenv("STORAGE_TYPE"), os.Getenv("STORAGE_PATH"))
+	if err != nil {
+		logger.Fatalf("Failed to initialize storage: %v", err)
+	}
+
+	notificationService := service.NewNotificationService(alarmClient, store)
+	// Reload notification state and registered notifiers before the scheduler runs
+	if err := notificationService.RestoreState(); err != nil {
+		logger.Fatalf("Failed to restore notification state: %v", err)
+	}
 
-	notificationService := service.NewNotificationService(httpClient)
 	// Start background scheduler
 	notificationService.StartNotificationScheduler()
 
@@ -68,18 +87,45 @@ func main() {
 
 	// Graceful shutdown
 	gracefulShutdown(server, logger)
+
+	// Let notifications in flight finish, cancel whatever is left after the timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := notificationService.Shutdown(ctx); err != nil {
+		logger.Errorf("Notifications still running at shutdown were cancelled: %v", err)
+	}
+
+	// Release the database handle for persistent stores
+	if closer, ok := store.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			logger.Errorf("Failed to close storage: %v", err)
+		}
+	}
 }
 
 // registerDefaultNotifier registers a default notifier on startup
-func registerDefaultNotifier(service service.NotificationService) {
+func registerDefaultNotifier(notificationService service.NotificationService) {
 	log.Println("Registering default notifier...")
 
-	defaultNotifier, err := notifiers.CreateNotifier(os.Getenv("NOTIFIER_TYPE"), os.Getenv("NOTIFIER_PARAMS"))
+	config := models.NotifierConfig{
+		Name:    "default",
+		Type:    os.Getenv("NOTIFIER_TYPE"),
+		Param:   os.Getenv("NOTIFIER_PARAMS"),
+		Enabled: true,
+	}
+	defaultNotifier, err := notifiers.CreateNotifier(config)
 	if err != nil {
 		log.Fatalf("Failed to create default notifier: %v", err)
 	}
 
-	service.RegisterNotifier(defaultNotifier)
+	// The same notifier may already have been added through the API
+	if _, err := notificationService.RegisterNotifier(config, defaultNotifier); err != nil {
+		if errors.Is(err, service.ErrDuplicateNotifier) {
+			log.Printf("Default notifier is already registered: %v", err)
+			return
+		}
+		log.Fatalf("Failed to register default notifier: %v", err)
+	}
 	log.Println("Default notifier registered successfully")
 }
 
@@ -0,0 +1,50 @@
+package notifiers
+
+import (
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/26christy/CarbonQuest/common/models"
+)
+
+// alarmIDPlaceholder is replaced with the alarm ID in ACK_LINK_URL
+const alarmIDPlaceholder = "{alarm_id}"
+
+// statusStyle is how chat messages show an alarm status
+type statusStyle struct {
+	color string // hex colour of the Slack attachment bar
+	teams string // Adaptive Card container style
+}
+
+// statusStyles colour alarms red until someone looks at them, green once
+// acknowledged and blue once resolved
+var statusStyles = map[string]statusStyle{
+	"triggered": {color: "#D32F2F", teams: "attention"},
+	"active":    {color: "#F57C00", teams: "warning"},
+	"ACK":       {color: "#388E3C", teams: "good"},
+	"resolved":  {color: "#1976D2", teams: "accent"},
+}
+
+// styleOf returns the style of a status, grey for statuses without one
+func styleOf(status string) statusStyle {
+	if style, ok := statusStyles[status]; ok {
+		return style
+	}
+	return statusStyle{color: "#757575", teams: "default"}
+}
+
+// ACKLinkFromEnv returns the ACK link pattern in ACK_LINK_URL. Without it
+// messages have no Acknowledge button: a button opens its link with GET, and
+// ack-service only acknowledges on POST, so it needs a page that does that.
+func ACKLinkFromEnv() string {
+	return os.Getenv("ACK_LINK_URL")
+}
+
+// ackLink fills the alarm ID into the link pattern, there is only a link for an alarm waiting to be acknowledged
+func ackLink(pattern string, alarm models.AlarmEvent) string {
+	if pattern == "" || alarm.Type != "triggered" && alarm.Type != "active" {
+		return ""
+	}
+	return strings.ReplaceAll(pattern, alarmIDPlaceholder, url.PathEscape(alarm.AlarmID))
+}
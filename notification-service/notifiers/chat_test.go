@@ -0,0 +1,160 @@
+package notifiers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/26christy/CarbonQuest/common/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testACKLink = "https://alarms.example.com/ack/{alarm_id}"
+
+// chatStandIn records the JSON bodies posted to it, as a Slack or Teams webhook would receive them
+func chatStandIn(t *testing.T, statusCode int) (*httptest.Server, *[]map[string]any) {
+	var bodies []map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		var body map[string]any
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		bodies = append(bodies, body)
+		w.WriteHeader(statusCode)
+	}))
+	t.Cleanup(server.Close)
+	return server, &bodies
+}
+
+// lookup walks a decoded JSON document by map keys and slice indexes
+func lookup(t *testing.T, doc any, path ...any) any {
+	for _, step := range path {
+		switch key := step.(type) {
+		case string:
+			object, ok := doc.(map[string]any)
+			require.True(t, ok, "expected an object at %v", step)
+			doc = object[key]
+		case int:
+			list, ok := doc.([]any)
+			require.True(t, ok, "expected a list at %v", step)
+			require.Greater(t, len(list), key)
+			doc = list[key]
+		}
+	}
+	return doc
+}
+
+func TestSlackNotifier_Notify(t *testing.T) {
+	server, bodies := chatStandIn(t, http.StatusOK)
+
+	alarm := SampleAlarm()
+	alarm.Name = "db <primary> & replica"
+	require.NoError(t, NewSlackNotifier(server.URL, testACKLink).Notify(context.Background(), alarm))
+
+	require.Len(t, *bodies, 1)
+	body := (*bodies)[0]
+	assert.Equal(t, "[triggered] db &lt;primary&gt; &amp; replica", body["text"])
+
+	attachment := lookup(t, body, "attachments", 0)
+	assert.Equal(t, "#D32F2F", lookup(t, attachment, "color"))
+	assert.Equal(t, "*db &lt;primary&gt; &amp; replica* is *triggered*", lookup(t, attachment, "blocks", 0, "text", "text"))
+
+	assert.Equal(t, "*runbook_url*\nhttps://runbooks.example.com/db-replication-lag", lookup(t, attachment, "blocks", 1, "fields", 0, "text"))
+	assert.Equal(t, "Labels: `region=eu-west` `team=db`", lookup(t, attachment, "blocks", 2, "elements", 1, "text"))
+
+	button := lookup(t, attachment, "blocks", 3, "elements", 0)
+	assert.Equal(t, "button", lookup(t, button, "type"))
+	assert.Equal(t, "https://alarms.example.com/ack/554e76e4-fc22-4eea-b6c6-616e5d4c8caf", lookup(t, button, "url"))
+}
+
+func TestSlackNotifier_ColourByStatus(t *testing.T) {
+	server, bodies := chatStandIn(t, http.StatusOK)
+	notifier := NewSlackNotifier(server.URL, testACKLink)
+
+	for _, status := range []string{"triggered", "active", "ACK", "resolved"} {
+		alarm := SampleAlarm()
+		alarm.Type = status
+		require.NoError(t, notifier.Notify(context.Background(), alarm))
+	}
+
+	var colours []any
+	for _, body := range *bodies {
+		colours = append(colours, lookup(t, body, "attachments", 0, "color"))
+	}
+	assert.Equal(t, []any{"#D32F2F", "#F57C00", "#388E3C", "#1976D2"}, colours)
+	assert.Len(t, lookup(t, (*bodies)[2], "attachments", 0, "blocks"), 3, "an acknowledged alarm has no ACK button")
+	assert.Len(t, lookup(t, (*bodies)[3], "attachments", 0, "blocks"), 3, "a resolved alarm has no ACK button")
+}
+
+func TestTeamsNotifier_Notify(t *testing.T) {
+	server, bodies := chatStandIn(t, http.StatusOK)
+
+	require.NoError(t, NewTeamsNotifier(server.URL, testACKLink).Notify(context.Background(), SampleAlarm()))
+
+	require.Len(t, *bodies, 1)
+	body := (*bodies)[0]
+	assert.Equal(t, "message", body["type"])
+	assert.Equal(t, "application/vnd.microsoft.card.adaptive", lookup(t, body, "attachments", 0, "contentType"))
+
+	card := lookup(t, body, "attachments", 0, "content")
+	assert.Equal(t, "AdaptiveCard", lookup(t, card, "type"))
+	assert.Equal(t, "attention", lookup(t, card, "body", 0, "style"))
+	assert.Equal(t, "db-replication-lag is triggered", lookup(t, card, "body", 0, "items", 0, "text"))
+	assert.Equal(t, map[string]any{"title": "Labels", "value": "region=eu-west, team=db"}, lookup(t, card, "body", 1, "facts", 3))
+	assert.Equal(t, "runbook_url", lookup(t, card, "body", 1, "facts", 4, "title"))
+	assert.Equal(t, "Action.OpenUrl", lookup(t, card, "actions", 0, "type"))
+	assert.Equal(t, "https://alarms.example.com/ack/554e76e4-fc22-4eea-b6c6-616e5d4c8caf", lookup(t, card, "actions", 0, "url"))
+
+	alarm := SampleAlarm()
+	alarm.Type = "ACK"
+	require.NoError(t, NewTeamsNotifier(server.URL, testACKLink).Notify(context.Background(), alarm))
+	card = lookup(t, (*bodies)[1], "attachments", 0, "content")
+	assert.Equal(t, "good", lookup(t, card, "body", 0, "style"))
+	assert.Nil(t, lookup(t, card, "actions"), "an acknowledged alarm has no ACK action")
+}
+
+func TestChatNotifiers_Failure(t *testing.T) {
+	server, _ := chatStandIn(t, http.StatusBadRequest)
+
+	ctx, receipt := WithReceipt(context.Background())
+	err := NewSlackNotifier(server.URL, "").Notify(ctx, SampleAlarm())
+	assert.ErrorContains(t, err, "SlackNotifier")
+	assert.Equal(t, http.StatusBadRequest, receipt.StatusCode)
+
+	err = NewTeamsNotifier(server.URL, "").Notify(context.Background(), SampleAlarm())
+	assert.ErrorContains(t, err, "TeamsNotifier")
+}
+
+func TestCreateNotifier_Chat(t *testing.T) {
+	server, bodies := chatStandIn(t, http.StatusOK)
+	t.Setenv("ACK_LINK_URL", testACKLink)
+
+	notifier, err := CreateNotifier(models.NotifierConfig{Type: "slack", Param: server.URL})
+	require.NoError(t, err)
+	assert.Equal(t, testACKLink, notifier.(*SlackNotifier).ACKLink)
+
+	// Without ACK_LINK_URL there is no button, ack-service does not acknowledge on GET
+	t.Setenv("ACK_LINK_URL", "")
+	t.Setenv("ACK_SERVICE_URL", "http://ack.internal/")
+	notifier, err = CreateNotifier(models.NotifierConfig{Type: "slack", Param: server.URL})
+	require.NoError(t, err)
+	require.NoError(t, notifier.Notify(context.Background(), SampleAlarm()))
+	assert.Len(t, lookup(t, (*bodies)[0], "attachments", 0, "blocks"), 3, "there is no ACK button")
+
+	// A template replaces the default message
+	notifier, err = CreateNotifier(models.NotifierConfig{
+		Type:     "teams",
+		Param:    server.URL,
+		Template: &models.NotificationTemplate{Body: `{"text": {{json .Alarm.Name}}}`},
+	})
+	require.NoError(t, err)
+	require.NoError(t, notifier.Notify(context.Background(), SampleAlarm()))
+	assert.Equal(t, map[string]any{"text": "db-replication-lag"}, (*bodies)[1])
+
+	_, err = CreateNotifier(models.NotifierConfig{Type: "slack"})
+	assert.Error(t, err)
+	_, err = CreateNotifier(models.NotifierConfig{Type: "teams"})
+	assert.Error(t, err)
+}
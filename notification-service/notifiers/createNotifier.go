@@ -1,19 +1,78 @@
 package notifiers
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+
+	"github.com/26christy/CarbonQuest/common/models"
+)
 
 // CreateNotifier is a factory function that returns a Notifier instance based on type
 // Add the notification type as per the requirement
-func CreateNotifier(notifierType, param string) (Notifier, error) {
-	switch notifierType {
+func CreateNotifier(config models.NotifierConfig) (Notifier, error) {
+	var tmpl *Template
+	if config.Template != nil {
+		var err error
+		if tmpl, err = ParseTemplate(*config.Template); err != nil {
+			return nil, fmt.Errorf("invalid template: %w", err)
+		}
+	}
+
+	// Only an email has somewhere to put an HTML body
+	if config.Type != "email" && config.Template != nil && config.Template.HTML != "" {
+		return nil, errors.New(config.Type + " notifier does not send HTML")
+	}
+
+	// Only a webhook receiver can check a signature or needs its own HTTP options
+	if config.Type != "webhook" && config.Secret != "" {
+		return nil, errors.New(config.Type + " notifier does not sign payloads")
+	}
+	if config.Type != "webhook" && config.HTTP != nil {
+		return nil, errors.New(config.Type + " notifier does not take HTTP options")
+	}
+
+	switch config.Type {
 	case "webhook":
-		if param == "" {
+		if config.Param == "" {
 			return nil, errors.New("webhook URL is missing")
 		}
-		return NewWebHookNotifier(param), nil
+		options, err := newRequestOptions(config.HTTP)
+		if err != nil {
+			return nil, fmt.Errorf("invalid HTTP options: %w", err)
+		}
+		return &WebHookNotifier{URL: config.Param, Template: tmpl, Secret: config.Secret, options: options}, nil
+	case "slack":
+		if config.Param == "" {
+			return nil, errors.New("slack webhook URL is missing")
+		}
+		notifier := NewSlackNotifier(config.Param, ACKLinkFromEnv())
+		notifier.Template = tmpl
+		return notifier, nil
+	case "teams":
+		if config.Param == "" {
+			return nil, errors.New("teams webhook URL is missing")
+		}
+		notifier := NewTeamsNotifier(config.Param, ACKLinkFromEnv())
+		notifier.Template = tmpl
+		return notifier, nil
+	case "email":
+		if config.Template != nil {
+			if err := validEmailHeaders(config.Template.Headers); err != nil {
+				return nil, err
+			}
+		}
+		notifier, err := NewEmailNotifier(SMTPConfigFromEnv(), config.Param)
+		if err != nil {
+			return nil, err
+		}
+		notifier.Template = tmpl
+		return notifier, nil
 	case "log":
 		// LogNotifier does not require a param
-		return NewLogNotifier(), nil
+		if config.Template != nil && len(config.Template.Headers) > 0 {
+			return nil, errors.New("log notifier does not send headers")
+		}
+		return &LogNotifier{Template: tmpl}, nil
 	default:
 		return nil, errors.New("unsupported notifier type")
 	}
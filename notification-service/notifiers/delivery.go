@@ -0,0 +1,68 @@
+package notifiers
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"time"
+)
+
+// NotifyTimeout reads NOTIFY_TIMEOUT, the seconds a single notifier may take
+func NotifyTimeout() time.Duration {
+	seconds, err := strconv.Atoi(os.Getenv("NOTIFY_TIMEOUT"))
+	if err != nil || seconds <= 0 {
+		seconds = 10 // default is 10 seconds
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// Delivery describes the notification being sent. The service sets it
+// on the context so notifiers and their templates can refer to it.
+type Delivery struct {
+	// Reminder is 0 for the first notification of an alarm and counts the reminders after it
+	Reminder int
+	// ID identifies the delivery to one notifier, it stays the same across retries
+	ID string
+}
+
+// Receipt collects what a notifier learned while delivering, such as the
+// response status of a webhook. Notifiers fill it in through the context.
+type Receipt struct {
+	StatusCode int
+}
+
+type (
+	deliveryKey struct{}
+	receiptKey  struct{}
+)
+
+// WithDelivery returns a context that carries the delivery to the notifier
+func WithDelivery(ctx context.Context, delivery Delivery) context.Context {
+	return context.WithValue(ctx, deliveryKey{}, delivery)
+}
+
+// WithDeliveryID returns a context whose delivery carries id, keeping the rest of the delivery of ctx
+func WithDeliveryID(ctx context.Context, id string) context.Context {
+	delivery := deliveryFrom(ctx)
+	delivery.ID = id
+	return WithDelivery(ctx, delivery)
+}
+
+// deliveryFrom returns the delivery of ctx, or the zero Delivery when none was set
+func deliveryFrom(ctx context.Context) Delivery {
+	delivery, _ := ctx.Value(deliveryKey{}).(Delivery)
+	return delivery
+}
+
+// WithReceipt returns a context that notifiers record their receipt into
+func WithReceipt(ctx context.Context) (context.Context, *Receipt) {
+	receipt := &Receipt{}
+	return context.WithValue(ctx, receiptKey{}, receipt), receipt
+}
+
+// recordStatus stores the response status when the caller asked for a receipt
+func recordStatus(ctx context.Context, statusCode int) {
+	if receipt, ok := ctx.Value(receiptKey{}).(*Receipt); ok {
+		receipt.StatusCode = statusCode
+	}
+}
@@ -0,0 +1,423 @@
+package notifiers
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"maps"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/26christy/CarbonQuest/common/models"
+)
+
+// maxRecipients caps the recipients of one email notifier
+const maxRecipients = 50
+
+// smtpTimeout bounds sending a batch, which is not tied to any one notification
+const smtpTimeout = 30 * time.Second
+
+// reservedEmailHeaders are set by the email notifier and can't be templated
+var reservedEmailHeaders = []string{
+	"From", "To", "Cc", "Bcc", "Date", "Mime-Version", "Content-Type", "Content-Transfer-Encoding",
+}
+
+// SMTPConfig is the mail server the email notifier sends through
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string // no authentication when empty
+	Password string
+	From     string
+	// StartTLS requires the server to upgrade the connection before anything is sent
+	StartTLS bool
+	// TLSConfig verifies the server on STARTTLS, the system roots are used when nil
+	TLSConfig *tls.Config
+	// BatchWindow collects the alarms notified within the window into one email, 0 sends each alarm on its own
+	BatchWindow time.Duration
+	// BatchSize sends a batch early once it holds this many alarms
+	BatchSize int
+}
+
+// SMTPConfigFromEnv reads the mail server from SMTP_* environment variables
+func SMTPConfigFromEnv() SMTPConfig {
+	config := SMTPConfig{
+		Host:     os.Getenv("SMTP_HOST"),
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		From:     os.Getenv("SMTP_FROM"),
+	}
+
+	var err error
+	if config.Port, err = strconv.Atoi(os.Getenv("SMTP_PORT")); err != nil {
+		config.Port = 587 // default is the submission port
+	}
+	if config.StartTLS, err = strconv.ParseBool(os.Getenv("SMTP_STARTTLS")); err != nil {
+		config.StartTLS = true // default is to require STARTTLS
+	}
+	batchWindow, err := strconv.Atoi(os.Getenv("SMTP_BATCH_WINDOW"))
+	if err != nil {
+		batchWindow = 0 // default is no batching
+	}
+	config.BatchWindow = time.Duration(batchWindow) * time.Second
+	// A batch goes out within the NOTIFY_TIMEOUT of its first alarm, a longer
+	// window would time every alarm out before anything is sent
+	if limit := NotifyTimeout() / 2; config.BatchWindow > limit {
+		fmt.Printf("[INFO] SMTP_BATCH_WINDOW of %v is cut to %v, half of NOTIFY_TIMEOUT\n", config.BatchWindow, limit)
+		config.BatchWindow = limit
+	}
+	if config.BatchSize, err = strconv.Atoi(os.Getenv("SMTP_BATCH_SIZE")); err != nil || config.BatchSize < 1 {
+		config.BatchSize = 20
+	}
+	return config
+}
+
+// EmailNotifier sends notifications as email over SMTP.
+// Without a template it sends a plain text summary of the alarm,
+// a "Subject" header in the template replaces the default subject.
+type EmailNotifier struct {
+	SMTP       SMTPConfig
+	Recipients []*mail.Address
+	Template   *Template
+
+	mu      sync.Mutex
+	pending *emailBatch
+}
+
+// emailBatch is the alarms waiting to go out in one email
+type emailBatch struct {
+	emails     []email
+	sent       bool
+	done       chan struct{}
+	statusCode int
+	err        error
+}
+
+// email is one alarm rendered for sending, ctx is the context of its Notify call
+type email struct {
+	ctx     context.Context
+	subject string
+	text    string
+	html    string
+	headers map[string]string
+}
+
+// NewEmailNotifier initializes an EmailNotifier, recipients is a comma separated address list
+func NewEmailNotifier(config SMTPConfig, recipients string) (*EmailNotifier, error) {
+	if config.Host == "" {
+		return nil, errors.New("SMTP host is missing")
+	}
+	if _, err := mail.ParseAddress(config.From); err != nil {
+		return nil, fmt.Errorf("invalid sender address: %w", err)
+	}
+	if strings.TrimSpace(recipients) == "" {
+		return nil, errors.New("email recipients are missing")
+	}
+	addresses, err := mail.ParseAddressList(recipients)
+	if err != nil {
+		return nil, fmt.Errorf("invalid recipients: %w", err)
+	}
+	if len(addresses) > maxRecipients {
+		return nil, fmt.Errorf("more than %d recipients", maxRecipients)
+	}
+	return &EmailNotifier{SMTP: config, Recipients: addresses}, nil
+}
+
+// Notify emails the alarm. With a batch window it waits for the batch the alarm
+// joined to be sent, the alarm is left out of the batch when ctx is done first.
+func (e *EmailNotifier) Notify(ctx context.Context, alarm models.AlarmEvent) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	msg, err := e.render(ctx, alarm)
+	if err != nil {
+		return err
+	}
+
+	if e.SMTP.BatchWindow <= 0 {
+		err := e.send(ctx, []email{msg})
+		recordStatus(ctx, smtpStatus(err))
+		return err
+	}
+
+	batch := e.enqueue(msg)
+	select {
+	case <-batch.done:
+		recordStatus(ctx, batch.statusCode)
+		return batch.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// enqueue adds the email to the pending batch, starting a new batch when there is none
+func (e *EmailNotifier) enqueue(msg email) *emailBatch {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	batch := e.pending
+	if batch == nil {
+		batch = &emailBatch{done: make(chan struct{})}
+		e.pending = batch
+		time.AfterFunc(e.SMTP.BatchWindow, func() { e.flush(batch) })
+	}
+	batch.emails = append(batch.emails, msg)
+	if len(batch.emails) >= e.SMTP.BatchSize {
+		go e.flush(batch)
+	}
+	return batch
+}
+
+// flush sends the batch once, whether the window or the batch size triggers it first
+func (e *EmailNotifier) flush(batch *emailBatch) {
+	e.mu.Lock()
+	if e.pending == batch {
+		e.pending = nil
+	}
+	if batch.sent {
+		e.mu.Unlock()
+		return
+	}
+	batch.sent = true
+	var emails []email
+	for _, msg := range batch.emails {
+		if msg.ctx.Err() == nil {
+			emails = append(emails, msg)
+		}
+	}
+	e.mu.Unlock()
+
+	if len(emails) > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), smtpTimeout)
+		batch.err = e.send(ctx, emails)
+		cancel()
+		batch.statusCode = smtpStatus(batch.err)
+	}
+	close(batch.done)
+}
+
+// render builds the email of one alarm from the template, or from the default summary
+func (e *EmailNotifier) render(ctx context.Context, alarm models.AlarmEvent) (email, error) {
+	msg := email{
+		ctx:     ctx,
+		subject: fmt.Sprintf("[%s] %s", alarm.Type, alarm.Name),
+		text: fmt.Sprintf("Alarm: %s\nStatus: %s\nID: %s\nTime: %s\n",
+			alarm.Name, alarm.Type, alarm.AlarmID, alarm.Timestamp.Format(time.RFC3339)),
+	}
+	if len(alarm.Labels) > 0 {
+		msg.text += "Labels: " + strings.Join(labelPairs(alarm.Labels), ", ") + "\n"
+	}
+	for _, key := range slices.Sorted(maps.Keys(alarm.Annotations)) {
+		msg.text += fmt.Sprintf("\n%s: %s\n", key, alarm.Annotations[key])
+	}
+	if e.Template == nil {
+		return msg, nil
+	}
+
+	rendered, err := e.Template.Render(ctx, alarm)
+	if err != nil {
+		return email{}, err
+	}
+	msg.text, msg.html, msg.headers = rendered.Body, rendered.HTML, rendered.Headers
+	for name, value := range rendered.Headers {
+		if strings.EqualFold(name, "Subject") {
+			msg.subject = value
+			delete(msg.headers, name)
+		}
+	}
+	return msg, nil
+}
+
+// send delivers the emails as one message to every recipient
+func (e *EmailNotifier) send(ctx context.Context, emails []email) error {
+	message, err := e.message(emails)
+	if err != nil {
+		return err
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(e.SMTP.Host, strconv.Itoa(e.SMTP.Port)))
+	if err != nil {
+		return err
+	}
+	// Unblock the SMTP conversation as soon as ctx is done
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	client, err := smtp.NewClient(conn, e.SMTP.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if e.SMTP.StartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return errors.New("EmailNotifier: server does not support STARTTLS")
+		}
+		tlsConfig := &tls.Config{ServerName: e.SMTP.Host}
+		if e.SMTP.TLSConfig != nil {
+			tlsConfig = e.SMTP.TLSConfig.Clone()
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return err
+		}
+	}
+	if e.SMTP.Username != "" {
+		// PlainAuth refuses to send the password over an unencrypted connection, except to localhost
+		if err := client.Auth(smtp.PlainAuth("", e.SMTP.Username, e.SMTP.Password, e.SMTP.Host)); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(e.SMTP.From); err != nil {
+		return err
+	}
+	for _, recipient := range e.Recipients {
+		if err := client.Rcpt(recipient.Address); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(message); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	if err := client.Quit(); err != nil {
+		return err
+	}
+
+	fmt.Printf("Email Notification sent to %d recipients\n", len(e.Recipients))
+	return nil
+}
+
+// message formats the emails as one MIME message. A batch is sent under the
+// subject of its first alarm, with the bodies one after the other.
+func (e *EmailNotifier) message(emails []email) ([]byte, error) {
+	first := emails[0]
+	subject := first.subject
+	if len(emails) > 1 {
+		subject = fmt.Sprintf("%s (+%d more)", subject, len(emails)-1)
+	}
+
+	var texts, htmls []string
+	hasHTML := false
+	for _, msg := range emails {
+		texts = append(texts, msg.text)
+		if msg.html != "" {
+			hasHTML = true
+			htmls = append(htmls, msg.html)
+		} else {
+			htmls = append(htmls, "<pre>"+html.EscapeString(msg.text)+"</pre>")
+		}
+	}
+
+	to := make([]string, len(e.Recipients))
+	for i, recipient := range e.Recipients {
+		to[i] = recipient.String()
+	}
+
+	var buf bytes.Buffer
+	header := func(name, value string) {
+		fmt.Fprintf(&buf, "%s: %s\r\n", name, value)
+	}
+	header("From", e.SMTP.From)
+	header("To", strings.Join(to, ", "))
+	header("Subject", mime.QEncoding.Encode("utf-8", subject))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("MIME-Version", "1.0")
+	for name, value := range first.headers {
+		header(name, mime.QEncoding.Encode("utf-8", value))
+	}
+
+	text := strings.Join(texts, "\n\n----\n\n")
+	if !hasHTML {
+		header("Content-Type", "text/plain; charset=utf-8")
+		header("Content-Transfer-Encoding", "quoted-printable")
+		buf.WriteString("\r\n")
+		if err := writeQuotedPrintable(&buf, text); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	parts := multipart.NewWriter(&buf)
+	header("Content-Type", "multipart/alternative; boundary="+parts.Boundary())
+	buf.WriteString("\r\n")
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", text},
+		{"text/html; charset=utf-8", strings.Join(htmls, "\n<hr>\n")},
+	} {
+		w, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := writeQuotedPrintable(w, part.body); err != nil {
+			return nil, err
+		}
+	}
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeQuotedPrintable encodes body, the encoder also turns line endings into CRLF as SMTP expects
+func writeQuotedPrintable(w io.Writer, body string) error {
+	qp := quotedprintable.NewWriter(w)
+	if _, err := io.WriteString(qp, body); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+// smtpStatus is the reply code of the server for the delivery log, 250 when it was sent
+func smtpStatus(err error) int {
+	var reply *textproto.Error
+	switch {
+	case err == nil:
+		return 250
+	case errors.As(err, &reply):
+		return reply.Code
+	default:
+		return 0
+	}
+}
+
+// validEmailHeaders rejects template headers the email notifier sets itself
+func validEmailHeaders(headers map[string]string) error {
+	for name := range headers {
+		for _, reserved := range reservedEmailHeaders {
+			if strings.EqualFold(name, reserved) {
+				return fmt.Errorf("header %s is set by the email notifier", name)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,260 @@
+package notifiers
+
+import (
+	"context"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/26christy/CarbonQuest/common/models"
+	"github.com/26christy/CarbonQuest/notification-service/notifiers/smtptest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestEmailNotifier sends through server to two recipients
+func newTestEmailNotifier(t *testing.T, server *smtptest.Server) *EmailNotifier {
+	notifier, err := NewEmailNotifier(SMTPConfig{
+		Host:      server.Host,
+		Port:      server.Port,
+		From:      "alarms@example.com",
+		TLSConfig: server.ClientTLSConfig(),
+		BatchSize: 20,
+	}, "oncall@example.com, Database Team <db@example.com>")
+	require.NoError(t, err)
+	return notifier
+}
+
+// readEmail parses a message the server received into its headers and decoded text and HTML bodies
+func readEmail(t *testing.T, message smtptest.Message) (mail.Header, string, string) {
+	msg, err := mail.ReadMessage(strings.NewReader(string(message.Data)))
+	require.NoError(t, err)
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	require.NoError(t, err)
+	if !strings.HasPrefix(mediaType, "multipart/") {
+		text, err := io.ReadAll(quotedprintable.NewReader(msg.Body))
+		require.NoError(t, err)
+		return msg.Header, string(text), ""
+	}
+
+	// NextPart decodes quoted-printable parts
+	var text, html string
+	parts := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := parts.NextPart()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		body, err := io.ReadAll(part)
+		require.NoError(t, err)
+		if strings.HasPrefix(part.Header.Get("Content-Type"), "text/html") {
+			html = string(body)
+		} else {
+			text = string(body)
+		}
+	}
+	return msg.Header, text, html
+}
+
+func TestEmailNotifier_Notify(t *testing.T) {
+	server := smtptest.NewUnstartedServer()
+	server.Username, server.Password = "alarms", "secret"
+	server.StartTLS()
+	defer server.Close()
+
+	notifier := newTestEmailNotifier(t, server)
+	notifier.SMTP.StartTLS = true
+	notifier.SMTP.Username, notifier.SMTP.Password = "alarms", "secret"
+
+	ctx, receipt := WithReceipt(context.Background())
+	require.NoError(t, notifier.Notify(ctx, SampleAlarm()))
+	assert.Equal(t, 250, receipt.StatusCode)
+
+	messages := server.Messages()
+	require.Len(t, messages, 1)
+	assert.Equal(t, "alarms@example.com", messages[0].From)
+	assert.Equal(t, []string{"oncall@example.com", "db@example.com"}, messages[0].To)
+
+	header, text, html := readEmail(t, messages[0])
+	assert.Equal(t, "[triggered] db-replication-lag", header.Get("Subject"))
+	assert.Contains(t, header.Get("To"), `"Database Team" <db@example.com>`)
+	assert.Contains(t, text, "Alarm: db-replication-lag\r\nStatus: triggered")
+	assert.Contains(t, text, "Labels: region=eu-west, team=db\r\n")
+	assert.Contains(t, text, "runbook_url: https://runbooks.example.com/db-replication-lag")
+	assert.Empty(t, html)
+}
+
+func TestEmailNotifier_Template(t *testing.T) {
+	server := smtptest.NewServer()
+	defer server.Close()
+
+	notifier := newTestEmailNotifier(t, server)
+	notifier.Template, _ = ParseTemplate(models.NotificationTemplate{
+		Body:    "{{.Alarm.Name}} needs attention",
+		HTML:    "<p>{{.Alarm.Name}} is <b>{{.Status}}</b> {{index .Alarm.Labels \"team\"}}</p>",
+		Headers: map[string]string{"Subject": "Alarm {{.Alarm.Name}} – {{upper .Status}}", "X-Priority": "1"},
+	})
+	require.NotNil(t, notifier.Template)
+
+	alarm := SampleAlarm()
+	alarm.Labels = map[string]string{"team": "<db>"}
+	require.NoError(t, notifier.Notify(context.Background(), alarm))
+
+	messages := server.Messages()
+	require.Len(t, messages, 1)
+	header, text, html := readEmail(t, messages[0])
+	subject, err := new(mime.WordDecoder).DecodeHeader(header.Get("Subject"))
+	assert.NoError(t, err)
+	assert.Equal(t, "Alarm db-replication-lag – TRIGGERED", subject)
+	assert.Equal(t, "1", header.Get("X-Priority"))
+	assert.Equal(t, "db-replication-lag needs attention", text)
+	assert.Equal(t, "<p>db-replication-lag is <b>triggered</b> &lt;db&gt;</p>", html, "the HTML template escapes alarm fields")
+}
+
+func TestEmailNotifier_Batch(t *testing.T) {
+	server := smtptest.NewServer()
+	defer server.Close()
+
+	notifier := newTestEmailNotifier(t, server)
+	notifier.SMTP.BatchWindow = 50 * time.Millisecond
+
+	var wg sync.WaitGroup
+	for _, name := range []string{"disk-full", "cpu-high", "db-down"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, receipt := WithReceipt(context.Background())
+			assert.NoError(t, notifier.Notify(ctx, models.AlarmEvent{AlarmID: name, Name: name, Type: "triggered"}))
+			assert.Equal(t, 250, receipt.StatusCode)
+		}()
+	}
+	wg.Wait()
+
+	messages := server.Messages()
+	require.Len(t, messages, 1, "alarms within the window should go out in one email")
+	header, text, _ := readEmail(t, messages[0])
+	assert.Contains(t, header.Get("Subject"), "(+2 more)")
+	for _, name := range []string{"disk-full", "cpu-high", "db-down"} {
+		assert.Contains(t, text, "Alarm: "+name)
+	}
+}
+
+func TestEmailNotifier_BatchSize(t *testing.T) {
+	server := smtptest.NewServer()
+	defer server.Close()
+
+	notifier := newTestEmailNotifier(t, server)
+	notifier.SMTP.BatchWindow = time.Hour
+	notifier.SMTP.BatchSize = 2
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for _, name := range []string{"disk-full", "cpu-high"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, notifier.Notify(ctx, models.AlarmEvent{AlarmID: name, Name: name, Type: "triggered"}))
+		}()
+	}
+	wg.Wait()
+
+	assert.Len(t, server.Messages(), 1, "a full batch should be sent without waiting for the window")
+}
+
+func TestEmailNotifier_Errors(t *testing.T) {
+	tests := []struct {
+		name       string
+		setup      func(server *smtptest.Server, notifier *EmailNotifier)
+		statusCode int
+	}{
+		{
+			name: "server without STARTTLS",
+			setup: func(server *smtptest.Server, notifier *EmailNotifier) {
+				notifier.SMTP.StartTLS = true
+			},
+		},
+		{
+			name: "rejected recipient",
+			setup: func(server *smtptest.Server, notifier *EmailNotifier) {
+				server.RejectRecipients = []string{"db@example.com"}
+			},
+			statusCode: 550,
+		},
+		{
+			name: "wrong password",
+			setup: func(server *smtptest.Server, notifier *EmailNotifier) {
+				server.Username, server.Password = "alarms", "secret"
+				notifier.SMTP.Username, notifier.SMTP.Password = "alarms", "guess"
+			},
+			statusCode: 535,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := smtptest.NewUnstartedServer()
+			notifier := newTestEmailNotifier(t, server)
+			tt.setup(server, notifier)
+			server.Start()
+			defer server.Close()
+
+			ctx, receipt := WithReceipt(context.Background())
+			assert.Error(t, notifier.Notify(ctx, SampleAlarm()))
+			assert.Equal(t, tt.statusCode, receipt.StatusCode)
+			assert.Empty(t, server.Messages())
+		})
+	}
+}
+
+func TestCreateNotifier_Email(t *testing.T) {
+	t.Setenv("SMTP_HOST", "127.0.0.1")
+	t.Setenv("SMTP_FROM", "alarms@example.com")
+
+	notifier, err := CreateNotifier(models.NotifierConfig{Type: "email", Param: "a@example.com,b@example.com"})
+	require.NoError(t, err)
+	assert.Len(t, notifier.(*EmailNotifier).Recipients, 2)
+	assert.Equal(t, 587, notifier.(*EmailNotifier).SMTP.Port)
+	assert.True(t, notifier.(*EmailNotifier).SMTP.StartTLS)
+
+	invalid := []models.NotifierConfig{
+		{Type: "email"},
+		{Type: "email", Param: "not an address"},
+		{Type: "email", Param: "a@example.com", Template: &models.NotificationTemplate{Headers: map[string]string{"bcc": "x@example.com"}}},
+		{Type: "webhook", Param: "http://example.com", Template: &models.NotificationTemplate{HTML: "<p>{{.Alarm.Name}}</p>"}},
+	}
+	for _, config := range invalid {
+		_, err := CreateNotifier(config)
+		assert.Error(t, err, "%+v", config)
+	}
+
+	t.Setenv("SMTP_HOST", "")
+	_, err = CreateNotifier(models.NotifierConfig{Type: "email", Param: "a@example.com"})
+	assert.ErrorContains(t, err, "SMTP host is missing")
+}
+
+func TestSMTPConfigFromEnv_BatchWindow(t *testing.T) {
+	t.Setenv("NOTIFY_TIMEOUT", "10")
+
+	t.Setenv("SMTP_BATCH_WINDOW", "3")
+	assert.Equal(t, 3*time.Second, SMTPConfigFromEnv().BatchWindow)
+
+	// A window as long as the timeout would never send anything
+	for _, window := range []string{"10", "60"} {
+		t.Setenv("SMTP_BATCH_WINDOW", window)
+		assert.Equal(t, 5*time.Second, SMTPConfigFromEnv().BatchWindow, "window %s", window)
+	}
+
+	t.Setenv("NOTIFY_TIMEOUT", "")
+	t.Setenv("SMTP_BATCH_WINDOW", "10")
+	assert.Equal(t, 5*time.Second, SMTPConfigFromEnv().BatchWindow, "the default timeout is 10 seconds")
+}
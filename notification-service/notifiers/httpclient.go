@@ -0,0 +1,112 @@
+package notifiers
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/26christy/CarbonQuest/common/models"
+)
+
+// requestOptions is how a notifier makes its requests, the zero value POSTs
+// with http.DefaultClient and accepts any 2xx status
+type requestOptions struct {
+	client   *http.Client
+	method   string
+	headers  map[string]string
+	accepted []int
+}
+
+// newRequestOptions checks the HTTP options of a webhook and builds its client.
+// A client of its own is only built when the options need a timeout or TLS settings.
+func newRequestOptions(options *models.HTTPOptions) (requestOptions, error) {
+	if options == nil {
+		return requestOptions{}, nil
+	}
+
+	headers := make(map[string]string, len(options.Headers)+1)
+	for name, value := range options.Headers {
+		if !validHeaderName(name) {
+			return requestOptions{}, errors.New("invalid header name " + name)
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			return requestOptions{}, errors.New("header " + name + " must be a single line")
+		}
+		headers[http.CanonicalHeaderKey(name)] = value
+	}
+	if options.BearerToken != "" {
+		if _, found := headers["Authorization"]; found {
+			return requestOptions{}, errors.New("set either a bearer token or an Authorization header")
+		}
+		if strings.ContainsAny(options.BearerToken, "\r\n") {
+			return requestOptions{}, errors.New("bearer token must be a single line")
+		}
+		headers["Authorization"] = "Bearer " + options.BearerToken
+	}
+
+	tlsConfig, err := newTLSConfig(options)
+	if err != nil {
+		return requestOptions{}, err
+	}
+	client := http.DefaultClient
+	if tlsConfig != nil || options.TimeoutSeconds > 0 {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		if tlsConfig != nil {
+			transport.TLSClientConfig = tlsConfig
+		}
+		client = &http.Client{
+			Transport: transport,
+			Timeout:   time.Duration(options.TimeoutSeconds) * time.Second,
+		}
+	}
+
+	return requestOptions{
+		client:   client,
+		method:   options.Method,
+		headers:  headers,
+		accepted: options.AcceptedStatuses,
+	}, nil
+}
+
+// newTLSConfig trusts the CA bundle on top of the system roots and presents the
+// client certificate for mTLS, it returns nil when neither is set
+func newTLSConfig(options *models.HTTPOptions) (*tls.Config, error) {
+	if options.CACert == "" && options.ClientCert == "" && options.ClientKey == "" {
+		return nil, nil
+	}
+
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if options.CACert != "" {
+		roots, err := x509.SystemCertPool()
+		if err != nil {
+			roots = x509.NewCertPool()
+		}
+		if !roots.AppendCertsFromPEM([]byte(options.CACert)) {
+			return nil, errors.New("ca_cert has no PEM encoded certificate")
+		}
+		config.RootCAs = roots
+	}
+	if options.ClientCert != "" || options.ClientKey != "" {
+		if options.ClientCert == "" || options.ClientKey == "" {
+			return nil, errors.New("client_cert and client_key must be set together")
+		}
+		certificate, err := tls.X509KeyPair([]byte(options.ClientCert), []byte(options.ClientKey))
+		if err != nil {
+			return nil, errors.New("invalid client certificate: " + err.Error())
+		}
+		config.Certificates = []tls.Certificate{certificate}
+	}
+	return config, nil
+}
+
+// accepts reports whether the response status counts as delivered
+func (o requestOptions) accepts(statusCode int) bool {
+	if len(o.accepted) == 0 {
+		return statusCode >= 200 && statusCode < 300
+	}
+	return slices.Contains(o.accepted, statusCode)
+}
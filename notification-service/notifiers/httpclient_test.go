@@ -0,0 +1,154 @@
+package notifiers
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/26christy/CarbonQuest/common/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// webhookWith creates a webhook notifier for url with the HTTP options
+func webhookWith(t *testing.T, url string, options models.HTTPOptions) Notifier {
+	notifier, err := CreateNotifier(models.NotifierConfig{Type: "webhook", Param: url, HTTP: &options})
+	require.NoError(t, err)
+	return notifier
+}
+
+func TestWebHookNotifier_HTTPOptions(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPut, r.Method)
+		assert.Equal(t, "Bearer token-123", r.Header.Get("Authorization"))
+		assert.Equal(t, "carbonquest", r.Header.Get("X-Source"))
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	notifier := webhookWith(t, server.URL, models.HTTPOptions{
+		Method:      http.MethodPut,
+		Headers:     map[string]string{"x-source": "carbonquest"},
+		BearerToken: "token-123",
+	})
+	assert.NoError(t, notifier.Notify(context.Background(), models.AlarmEvent{AlarmID: "123"}))
+}
+
+func TestWebHookNotifier_AcceptedStatuses(t *testing.T) {
+	status := http.StatusNoContent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	// Any 2xx is delivered by default
+	assert.NoError(t, NewWebHookNotifier(server.URL).Notify(context.Background(), models.AlarmEvent{AlarmID: "123"}))
+
+	notifier := webhookWith(t, server.URL, models.HTTPOptions{AcceptedStatuses: []int{http.StatusOK, http.StatusConflict}})
+	assert.Error(t, notifier.Notify(context.Background(), models.AlarmEvent{AlarmID: "123"}), "204 is not in the accepted statuses")
+	status = http.StatusConflict
+	assert.NoError(t, notifier.Notify(context.Background(), models.AlarmEvent{AlarmID: "123"}))
+}
+
+func TestWebHookNotifier_Timeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	start := time.Now()
+	err := webhookWith(t, server.URL, models.HTTPOptions{TimeoutSeconds: 1}).Notify(context.Background(), models.AlarmEvent{AlarmID: "123"})
+	assert.Error(t, err)
+	assert.Less(t, time.Since(start), 3*time.Second)
+}
+
+func TestWebHookNotifier_MutualTLS(t *testing.T) {
+	clientCert, clientKey := selfSignedCertificate(t)
+	clientPool := x509.NewCertPool()
+	require.True(t, clientPool.AppendCertsFromPEM(clientCert))
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientPool}
+	server.StartTLS()
+	defer server.Close()
+	serverCA := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+
+	withCert := webhookWith(t, server.URL, models.HTTPOptions{
+		CACert:     string(serverCA),
+		ClientCert: string(clientCert),
+		ClientKey:  string(clientKey),
+	})
+	assert.NoError(t, withCert.Notify(context.Background(), models.AlarmEvent{AlarmID: "123"}))
+
+	withoutCert := webhookWith(t, server.URL, models.HTTPOptions{CACert: string(serverCA)})
+	assert.Error(t, withoutCert.Notify(context.Background(), models.AlarmEvent{AlarmID: "123"}), "the server requires a client certificate")
+
+	assert.Error(t, NewWebHookNotifier(server.URL).Notify(context.Background(), models.AlarmEvent{AlarmID: "123"}), "the server certificate is not trusted by default")
+}
+
+func TestCreateNotifier_InvalidHTTPOptions(t *testing.T) {
+	clientCert, _ := selfSignedCertificate(t)
+	tests := []struct {
+		name    string
+		options models.HTTPOptions
+	}{
+		{"header name", models.HTTPOptions{Headers: map[string]string{"X Source": "x"}}},
+		{"multi-line header", models.HTTPOptions{Headers: map[string]string{"X-Source": "a\r\nb"}}},
+		{"token and Authorization", models.HTTPOptions{BearerToken: "x", Headers: map[string]string{"authorization": "Basic eA=="}}},
+		{"ca_cert", models.HTTPOptions{CACert: "not a certificate"}},
+		{"client_cert without key", models.HTTPOptions{ClientCert: string(clientCert)}},
+		{"client key mismatch", models.HTTPOptions{ClientCert: string(clientCert), ClientKey: "not a key"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := CreateNotifier(models.NotifierConfig{Type: "webhook", Param: "https://example.com", HTTP: &tt.options})
+			assert.ErrorContains(t, err, "invalid HTTP options")
+		})
+	}
+
+	_, err := CreateNotifier(models.NotifierConfig{Type: "slack", Param: "https://example.com", HTTP: &models.HTTPOptions{Method: "PUT"}})
+	assert.Error(t, err, "only webhooks take HTTP options")
+}
+
+// selfSignedCertificate returns a PEM encoded client certificate and its key
+func selfSignedCertificate(t *testing.T) ([]byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "notification-service"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
@@ -1,10 +1,15 @@
 package notifiers
 
-import "github.com/26christy/CarbonQuest/common/models"
+import (
+	"context"
+
+	"github.com/26christy/CarbonQuest/common/models"
+)
 
 // Generic interface for integrating any new notification system.
 // All notifiers should implement a common interface,
 // making the service agnostic to the type of notification.
+// Notify must give up and return once ctx is done.
 type Notifier interface {
-	Notify(alarm models.AlarmEvent) error
+	Notify(ctx context.Context, alarm models.AlarmEvent) error
 }
@@ -1,13 +1,17 @@
 package notifiers
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/26christy/CarbonQuest/common/models"
 )
 
-// LogNotifier logs the alarm event to the console
-type LogNotifier struct{}
+// LogNotifier logs the alarm event to the console,
+// with the template body instead of the default line when it has one
+type LogNotifier struct {
+	Template *Template
+}
 
 // NewLogNotifier initializes a LogNotifier
 func NewLogNotifier() *LogNotifier {
@@ -15,7 +19,19 @@ func NewLogNotifier() *LogNotifier {
 }
 
 // Notify logs the alarm event
-func (l *LogNotifier) Notify(alarm models.AlarmEvent) error {
-	fmt.Printf("Log Notification: Alarm [%s] - Status: %s\n", alarm.Name, alarm.Type)
+func (l *LogNotifier) Notify(ctx context.Context, alarm models.AlarmEvent) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if l.Template == nil {
+		fmt.Printf("Log Notification: Alarm [%s] - Status: %s\n", alarm.Name, alarm.Type)
+		return nil
+	}
+
+	rendered, err := l.Template.Render(ctx, alarm)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Log Notification: %s\n", rendered.Body)
 	return nil
 }
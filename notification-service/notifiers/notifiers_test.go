@@ -0,0 +1,208 @@
+package notifiers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/26christy/CarbonQuest/common/models"
+	"github.com/26christy/CarbonQuest/common/webhooksig"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebHookNotifier_Notify(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	err := NewWebHookNotifier(server.URL).Notify(context.Background(), models.AlarmEvent{AlarmID: "123"})
+	assert.NoError(t, err)
+}
+
+func TestWebHookNotifier_RecordsStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	ctx, receipt := WithReceipt(context.Background())
+	err := NewWebHookNotifier(server.URL).Notify(ctx, models.AlarmEvent{AlarmID: "123"})
+
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, receipt.StatusCode)
+}
+
+func TestWebHookNotifier_HungWebHook(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := NewWebHookNotifier(server.URL).Notify(ctx, models.AlarmEvent{AlarmID: "123"})
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestLogNotifier_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := NewLogNotifier().Notify(ctx, models.AlarmEvent{AlarmID: "123"})
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestWebHookNotifier_Template(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"text": "DB-REPLICATION-LAG is triggered (reminder 2)"}`, string(body))
+		assert.Equal(t, "554e76e4-fc22-4eea-b6c6-616e5d4c8caf", r.Header.Get("X-Alarm-ID"))
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	notifier, err := CreateNotifier(models.NotifierConfig{
+		Type:  "webhook",
+		Param: server.URL,
+		Template: &models.NotificationTemplate{
+			Body:    `{"text": "{{upper .Alarm.Name}} is {{.Status}}{{if .Reminder}} (reminder {{.Reminder}}){{end}}"}`,
+			Headers: map[string]string{"X-Alarm-ID": "{{.Alarm.AlarmID}}"},
+		},
+	})
+	require.NoError(t, err)
+
+	ctx := WithDelivery(context.Background(), Delivery{Reminder: 2})
+	assert.NoError(t, notifier.Notify(ctx, SampleAlarm()))
+}
+
+func TestTemplate_Render(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		reminder int
+		expected string
+	}{
+		{"fields", "{{.Alarm.Name}} {{.Status}} {{.ACKed}} {{.Resolved}}", 0, "db-replication-lag triggered false false"},
+		{"labels", `{{index .Alarm.Labels "team"}}`, 0, "db"},
+		{"missing label", `{{index .Alarm.Labels "owner"}}`, 0, ""},
+		{"default", `{{default "none" (index .Alarm.Labels "owner")}}`, 0, "none"},
+		{"annotations", `{{.Alarm.Annotations.runbook_url}}`, 0, "https://runbooks.example.com/db-replication-lag"},
+		{"pairs", `{{join (pairs .Alarm.Labels) ","}}`, 0, "region=eu-west,team=db"},
+		{"formatTime", `{{formatTime "2006-01-02 15:04" .Alarm.Timestamp}}`, 0, "2025-03-19 11:07"},
+		{"truncate", `{{truncate 2 .Alarm.Name}}`, 0, "db"},
+		{"json", `{{json .Alarm.Name}}`, 0, `"db-replication-lag"`},
+		{"reminder", "{{.Reminder}}", 3, "3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := ParseTemplate(models.NotificationTemplate{Body: tt.body})
+			require.NoError(t, err)
+
+			rendered, err := tmpl.Render(WithDelivery(context.Background(), Delivery{Reminder: tt.reminder}), SampleAlarm())
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, rendered.Body)
+		})
+	}
+}
+
+func TestParseTemplate_Invalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		template models.NotificationTemplate
+	}{
+		{"syntax", models.NotificationTemplate{Body: "{{.Alarm.Name"}},
+		{"unknown field", models.NotificationTemplate{Body: "{{.Alarm.Owner}}"}},
+		{"unknown function", models.NotificationTemplate{Body: "{{shout .Alarm.Name}}"}},
+		{"header name", models.NotificationTemplate{Headers: map[string]string{"X Alarm": "x"}}},
+		{"multi-line header", models.NotificationTemplate{Headers: map[string]string{"X-Alarm": "a\nb"}}},
+		{"body too long", models.NotificationTemplate{Body: strings.Repeat("x", maxTemplateBody+1)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseTemplate(tt.template)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestCreateNotifier_InvalidTemplate(t *testing.T) {
+	_, err := CreateNotifier(models.NotifierConfig{
+		Type:     "log",
+		Template: &models.NotificationTemplate{Body: "{{.Alarm.Owner}}"},
+	})
+	assert.ErrorContains(t, err, "invalid template")
+
+	_, err = CreateNotifier(models.NotifierConfig{
+		Type:     "log",
+		Template: &models.NotificationTemplate{Headers: map[string]string{"X-Alarm": "x"}},
+	})
+	assert.Error(t, err, "log notifier has no headers to send")
+}
+
+func TestWebHookNotifier_Signed(t *testing.T) {
+	verifier := webhooksig.NewVerifier("0123456789abcdef", 0)
+	var deliveryIDs []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		deliveryID, err := verifier.VerifyRequest(r)
+		assert.NoError(t, err)
+		deliveryIDs = append(deliveryIDs, deliveryID)
+
+		var alarm models.AlarmEvent
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&alarm), "the body should still be readable after verifying")
+		assert.Equal(t, "123", alarm.AlarmID)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	notifier := &WebHookNotifier{URL: server.URL, Secret: "0123456789abcdef"}
+	ctx := WithDeliveryID(context.Background(), "delivery-1")
+	require.NoError(t, notifier.Notify(ctx, models.AlarmEvent{AlarmID: "123"}))
+	// A retry keeps the delivery ID and is accepted until the receiver marks it done
+	require.NoError(t, notifier.Notify(ctx, models.AlarmEvent{AlarmID: "123"}))
+	require.NoError(t, notifier.Notify(context.Background(), models.AlarmEvent{AlarmID: "123"}))
+
+	require.Len(t, deliveryIDs, 3)
+	assert.Equal(t, []string{"delivery-1", "delivery-1"}, deliveryIDs[:2])
+	assert.NotEmpty(t, deliveryIDs[2], "a delivery ID should be generated when the context has none")
+}
+
+func TestWebHookNotifier_Unsigned(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Empty(t, r.Header.Get(webhooksig.SignatureHeader))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	assert.NoError(t, NewWebHookNotifier(server.URL).Notify(context.Background(), models.AlarmEvent{AlarmID: "123"}))
+}
+
+func TestCreateNotifier_Secret(t *testing.T) {
+	notifier, err := CreateNotifier(models.NotifierConfig{Type: "webhook", Param: "http://example.com", Secret: "0123456789abcdef"})
+	require.NoError(t, err)
+	assert.Equal(t, "0123456789abcdef", notifier.(*WebHookNotifier).Secret)
+
+	_, err = CreateNotifier(models.NotifierConfig{Type: "slack", Param: "http://example.com", Secret: "0123456789abcdef"})
+	assert.Error(t, err, "only webhooks sign payloads")
+}
@@ -0,0 +1,98 @@
+package notifiers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/26christy/CarbonQuest/common/models"
+)
+
+// slackEscaper escapes the characters Slack reserves for links and mentions in mrkdwn
+var slackEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// SlackNotifier posts alarms to a Slack incoming webhook as Block Kit messages,
+// in an attachment coloured by status. A template replaces the message.
+type SlackNotifier struct {
+	URL      string
+	Template *Template
+	// ACKLink is the URL of the Acknowledge button, {alarm_id} is replaced with the alarm ID
+	ACKLink string
+}
+
+// NewSlackNotifier initializes a SlackNotifier
+func NewSlackNotifier(url, ackLink string) *SlackNotifier {
+	return &SlackNotifier{URL: url, ACKLink: ackLink}
+}
+
+// Notify posts the alarm to the Slack webhook, the request is aborted when ctx is done
+func (s *SlackNotifier) Notify(ctx context.Context, alarm models.AlarmEvent) error {
+	payload, headers, err := renderPayload(ctx, s.Template, alarm, func() ([]byte, error) {
+		return json.Marshal(s.message(alarm))
+	})
+	if err != nil {
+		return err
+	}
+	if err := post(ctx, requestOptions{}, s.URL, payload, headers); err != nil {
+		return fmt.Errorf("SlackNotifier: %w", err)
+	}
+
+	fmt.Printf("Slack Notification sent for alarm %s\n", alarm.AlarmID)
+	return nil
+}
+
+// message is the Slack message of an alarm, text is the fallback shown in notifications
+func (s *SlackNotifier) message(alarm models.AlarmEvent) map[string]any {
+	name := slackEscaper.Replace(alarm.Name)
+	blocks := []map[string]any{{
+		"type": "section",
+		"text": map[string]any{"type": "mrkdwn", "text": fmt.Sprintf("*%s* is *%s*", name, alarm.Type)},
+	}}
+	// Annotations are shown as fields under the title, Slack allows at most 10
+	if len(alarm.Annotations) > 0 {
+		var fields []map[string]any
+		for _, key := range slices.Sorted(maps.Keys(alarm.Annotations))[:min(len(alarm.Annotations), 10)] {
+			fields = append(fields, map[string]any{
+				"type": "mrkdwn",
+				"text": fmt.Sprintf("*%s*\n%s", slackEscaper.Replace(key), slackEscaper.Replace(alarm.Annotations[key])),
+			})
+		}
+		blocks = append(blocks, map[string]any{"type": "section", "fields": fields})
+	}
+	elements := []map[string]any{{
+		"type": "mrkdwn",
+		// Slack shows the date in the reader's time zone, the text after | is the fallback
+		"text": fmt.Sprintf("Alarm `%s` · <!date^%d^{date_short_pretty} {time}|%s>",
+			slackEscaper.Replace(alarm.AlarmID), alarm.Timestamp.Unix(), alarm.Timestamp.Format(time.RFC3339)),
+	}}
+	if len(alarm.Labels) > 0 {
+		elements = append(elements, map[string]any{
+			"type": "mrkdwn",
+			"text": "Labels: `" + slackEscaper.Replace(strings.Join(labelPairs(alarm.Labels), "` `")) + "`",
+		})
+	}
+	blocks = append(blocks, map[string]any{"type": "context", "elements": elements})
+	if link := ackLink(s.ACKLink, alarm); link != "" {
+		blocks = append(blocks, map[string]any{
+			"type": "actions",
+			"elements": []map[string]any{{
+				"type":  "button",
+				"text":  map[string]any{"type": "plain_text", "text": "Acknowledge"},
+				"url":   link,
+				"style": "primary",
+			}},
+		})
+	}
+
+	return map[string]any{
+		"text": fmt.Sprintf("[%s] %s", alarm.Type, name),
+		"attachments": []map[string]any{{
+			"color":  styleOf(alarm.Type).color,
+			"blocks": blocks,
+		}},
+	}
+}
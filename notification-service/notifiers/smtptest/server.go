@@ -0,0 +1,308 @@
+// Package smtptest runs an SMTP server in process for tests, in the spirit of net/http/httptest.
+// It speaks enough SMTP for net/smtp: EHLO, STARTTLS, AUTH PLAIN, MAIL, RCPT, DATA, RSET, NOOP and QUIT.
+package smtptest
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"net"
+	"net/textproto"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Message is an email the server accepted
+type Message struct {
+	From string
+	To   []string
+	// Data is the message as sent after DATA, headers and body
+	Data []byte
+}
+
+// Server is a fake SMTP server listening on a local port
+type Server struct {
+	Host string
+	Port int
+
+	// Username and Password make AUTH PLAIN required before MAIL when set
+	Username string
+	Password string
+	// RejectRecipients are answered with 550 on RCPT
+	RejectRecipients []string
+
+	listener    net.Listener
+	tlsConfig   *tls.Config
+	certificate *x509.Certificate
+
+	mu       sync.Mutex
+	messages []Message
+	wg       sync.WaitGroup
+}
+
+// NewServer starts a server without STARTTLS
+func NewServer() *Server {
+	s := NewUnstartedServer()
+	s.Start()
+	return s
+}
+
+// NewTLSServer starts a server that offers STARTTLS, see ClientTLSConfig
+func NewTLSServer() *Server {
+	s := NewUnstartedServer()
+	s.StartTLS()
+	return s
+}
+
+// NewUnstartedServer listens on a local port, set the fields before calling Start or StartTLS
+func NewUnstartedServer() *Server {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic("smtptest: failed to listen: " + err.Error())
+	}
+	addr := listener.Addr().(*net.TCPAddr)
+	return &Server{Host: addr.IP.String(), Port: addr.Port, listener: listener}
+}
+
+// Start serves connections
+func (s *Server) Start() {
+	s.wg.Add(1)
+	go s.serve()
+}
+
+// StartTLS serves connections and offers STARTTLS with a self-signed certificate
+func (s *Server) StartTLS() {
+	certificate, err := selfSignedCertificate(s.Host)
+	if err != nil {
+		panic("smtptest: failed to create certificate: " + err.Error())
+	}
+	s.certificate = certificate.Leaf
+	s.tlsConfig = &tls.Config{Certificates: []tls.Certificate{certificate}}
+	s.Start()
+}
+
+// ClientTLSConfig trusts the certificate of the server
+func (s *Server) ClientTLSConfig() *tls.Config {
+	pool := x509.NewCertPool()
+	if s.certificate != nil {
+		pool.AddCert(s.certificate)
+	}
+	return &tls.Config{RootCAs: pool, ServerName: s.Host}
+}
+
+// Messages returns the emails accepted so far
+func (s *Server) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.messages)
+}
+
+// Close stops listening and waits for open connections to finish
+func (s *Server) Close() {
+	s.listener.Close()
+	s.wg.Wait()
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer conn.Close()
+			// A client that stops talking must not keep Close waiting forever
+			conn.SetDeadline(time.Now().Add(10 * time.Second))
+			s.handle(conn)
+		}()
+	}
+}
+
+// session is the state of one SMTP conversation
+type session struct {
+	conn          net.Conn
+	text          *textproto.Conn
+	tls           bool
+	authenticated bool
+	message       *Message
+}
+
+func (s *Server) handle(conn net.Conn) {
+	ss := &session{conn: conn, text: textproto.NewConn(conn)}
+	ss.reply(220, "smtptest ESMTP")
+
+	for {
+		line, err := ss.text.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			extensions := []string{"smtptest", "8BITMIME"}
+			if s.tlsConfig != nil && !ss.tls {
+				extensions = append(extensions, "STARTTLS")
+			}
+			if s.Username != "" {
+				extensions = append(extensions, "AUTH PLAIN")
+			}
+			ss.reply(250, extensions...)
+		case "STARTTLS":
+			if s.tlsConfig == nil || ss.tls {
+				ss.reply(502, "STARTTLS not available")
+				continue
+			}
+			ss.reply(220, "ready to start TLS")
+			tlsConn := tls.Server(ss.conn, s.tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			// The client starts over with EHLO on the encrypted connection
+			*ss = session{conn: tlsConn, text: textproto.NewConn(tlsConn), tls: true}
+		case "AUTH":
+			ss.auth(s, arg)
+		case "MAIL":
+			if s.Username != "" && !ss.authenticated {
+				ss.reply(530, "authentication required")
+				continue
+			}
+			ss.message = &Message{From: address(arg)}
+			ss.reply(250, "OK")
+		case "RCPT":
+			if ss.message == nil {
+				ss.reply(503, "MAIL first")
+				continue
+			}
+			to := address(arg)
+			if slices.Contains(s.RejectRecipients, to) {
+				ss.reply(550, "mailbox unavailable")
+				continue
+			}
+			ss.message.To = append(ss.message.To, to)
+			ss.reply(250, "OK")
+		case "DATA":
+			if ss.message == nil || len(ss.message.To) == 0 {
+				ss.reply(503, "RCPT first")
+				continue
+			}
+			ss.reply(354, "end data with <CR><LF>.<CR><LF>")
+			data, err := ss.text.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			// ReadDotBytes turns CRLF into LF, restore the line endings that were sent
+			ss.message.Data = bytes.ReplaceAll(data, []byte("\n"), []byte("\r\n"))
+			s.mu.Lock()
+			s.messages = append(s.messages, *ss.message)
+			s.mu.Unlock()
+			ss.message = nil
+			ss.reply(250, "OK: queued")
+		case "RSET":
+			ss.message = nil
+			ss.reply(250, "OK")
+		case "NOOP":
+			ss.reply(250, "OK")
+		case "QUIT":
+			ss.reply(221, "bye")
+			return
+		default:
+			ss.reply(502, "command not implemented")
+		}
+	}
+}
+
+// auth checks AUTH PLAIN credentials, sent with the command or after a 334 prompt
+func (ss *session) auth(s *Server, arg string) {
+	mechanism, response, _ := strings.Cut(arg, " ")
+	if s.Username == "" || !strings.EqualFold(mechanism, "PLAIN") {
+		ss.reply(504, "unrecognized authentication type")
+		return
+	}
+	if response == "" {
+		ss.reply(334, "")
+		line, err := ss.text.ReadLine()
+		if err != nil {
+			return
+		}
+		response = line
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(response)
+	if err != nil {
+		ss.reply(501, "invalid base64")
+		return
+	}
+	// PLAIN is authorization identity, username and password separated by NUL
+	parts := strings.Split(string(decoded), "\x00")
+	if len(parts) != 3 || parts[1] != s.Username || parts[2] != s.Password {
+		ss.reply(535, "authentication failed")
+		return
+	}
+	ss.authenticated = true
+	ss.reply(235, "authentication succeeded")
+}
+
+// reply writes a reply, several lines make a multi-line reply
+func (ss *session) reply(code int, lines ...string) {
+	w := bufio.NewWriter(ss.conn)
+	for i, line := range lines {
+		separator := "-"
+		if i == len(lines)-1 {
+			separator = " "
+		}
+		fmt.Fprintf(w, "%d%s%s\r\n", code, separator, line)
+	}
+	w.Flush()
+}
+
+// address takes the mailbox out of "FROM:<a@example.com> SIZE=10" or "TO:<a@example.com>"
+func address(arg string) string {
+	start, end := strings.Index(arg, "<"), strings.Index(arg, ">")
+	if start < 0 || end < start {
+		return ""
+	}
+	return arg[start+1 : end]
+}
+
+// selfSignedCertificate is a short-lived certificate for host
+func selfSignedCertificate(host string) (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{Organization: []string{"smtptest"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		IPAddresses:           []net.IP{net.ParseIP(host)},
+		DNSNames:              []string{"localhost"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, nil
+}
@@ -0,0 +1,93 @@
+package notifiers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/26christy/CarbonQuest/common/models"
+)
+
+// TeamsNotifier posts alarms to a Microsoft Teams incoming webhook as an Adaptive Card,
+// with the header styled by status. A template replaces the card.
+type TeamsNotifier struct {
+	URL      string
+	Template *Template
+	// ACKLink is the URL of the Acknowledge action, {alarm_id} is replaced with the alarm ID
+	ACKLink string
+}
+
+// NewTeamsNotifier initializes a TeamsNotifier
+func NewTeamsNotifier(url, ackLink string) *TeamsNotifier {
+	return &TeamsNotifier{URL: url, ACKLink: ackLink}
+}
+
+// Notify posts the alarm to the Teams webhook, the request is aborted when ctx is done
+func (t *TeamsNotifier) Notify(ctx context.Context, alarm models.AlarmEvent) error {
+	payload, headers, err := renderPayload(ctx, t.Template, alarm, func() ([]byte, error) {
+		return json.Marshal(t.message(alarm))
+	})
+	if err != nil {
+		return err
+	}
+	if err := post(ctx, requestOptions{}, t.URL, payload, headers); err != nil {
+		return fmt.Errorf("TeamsNotifier: %w", err)
+	}
+
+	fmt.Printf("Teams Notification sent for alarm %s\n", alarm.AlarmID)
+	return nil
+}
+
+// message wraps the Adaptive Card of an alarm in the message Teams webhooks expect
+func (t *TeamsNotifier) message(alarm models.AlarmEvent) map[string]any {
+	facts := []map[string]any{
+		{"title": "Status", "value": alarm.Type},
+		{"title": "Alarm ID", "value": alarm.AlarmID},
+		{"title": "Time", "value": alarm.Timestamp.Format(time.RFC3339)},
+	}
+	if len(alarm.Labels) > 0 {
+		facts = append(facts, map[string]any{"title": "Labels", "value": strings.Join(labelPairs(alarm.Labels), ", ")})
+	}
+	for _, key := range slices.Sorted(maps.Keys(alarm.Annotations)) {
+		facts = append(facts, map[string]any{"title": key, "value": alarm.Annotations[key]})
+	}
+	card := map[string]any{
+		"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+		"type":    "AdaptiveCard",
+		"version": "1.4",
+		"body": []map[string]any{
+			{
+				"type":  "Container",
+				"style": styleOf(alarm.Type).teams,
+				"bleed": true,
+				"items": []map[string]any{{
+					"type":   "TextBlock",
+					"text":   fmt.Sprintf("%s is %s", alarm.Name, alarm.Type),
+					"weight": "Bolder",
+					"size":   "Medium",
+					"wrap":   true,
+				}},
+			},
+			{"type": "FactSet", "facts": facts},
+		},
+	}
+	if link := ackLink(t.ACKLink, alarm); link != "" {
+		card["actions"] = []map[string]any{{
+			"type":  "Action.OpenUrl",
+			"title": "Acknowledge",
+			"url":   link,
+		}}
+	}
+
+	return map[string]any{
+		"type": "message",
+		"attachments": []map[string]any{{
+			"contentType": "application/vnd.microsoft.card.adaptive",
+			"content":     card,
+		}},
+	}
+}
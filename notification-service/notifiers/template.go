@@ -0,0 +1,205 @@
+package notifiers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"maps"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/26christy/CarbonQuest/common/models"
+)
+
+// Limits on the size of a template, a rendered body is capped separately
+const (
+	maxTemplateBody    = 10000
+	maxTemplateHeaders = 20
+	maxRenderedBody    = 64 << 10
+)
+
+// TemplateData is what a notification template can refer to,
+// e.g. {{.Alarm.Name}}, {{.Status}} or {{if .ACKed}}...{{end}}.
+// Resolved is set for the recovery notification of an alarm.
+type TemplateData struct {
+	Alarm    models.AlarmEvent
+	Status   string
+	ACKed    bool
+	Resolved bool
+	Reminder int
+}
+
+// Rendered is the output of a template
+type Rendered struct {
+	Body    string            `json:"body"`
+	HTML    string            `json:"html,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// Template renders the body and headers of a notification.
+// The HTML body is escaped as HTML, html is nil when there is none.
+type Template struct {
+	body    *template.Template
+	html    *htmltemplate.Template
+	headers map[string]*template.Template
+}
+
+// templateFuncs are the helper functions available in templates
+var templateFuncs = template.FuncMap{
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"trim":  strings.TrimSpace,
+	"join":  strings.Join,
+	// formatTime formats a time with a Go layout, e.g. {{formatTime "15:04" .Alarm.Timestamp}}
+	"formatTime": func(layout string, t time.Time) string {
+		return t.Format(layout)
+	},
+	// since is the time elapsed since t, rounded to the second
+	"since": func(t time.Time) string {
+		return time.Since(t).Round(time.Second).String()
+	},
+	// default returns fallback when value is empty, e.g. {{default "unknown" .Alarm.Severity}}
+	"default": func(fallback, value string) string {
+		if value == "" {
+			return fallback
+		}
+		return value
+	},
+	"truncate": func(length int, s string) string {
+		if runes := []rune(s); len(runes) > length {
+			return string(runes[:length])
+		}
+		return s
+	},
+	// pairs formats labels as sorted key=value pairs, e.g. {{join (pairs .Alarm.Labels) ", "}}
+	"pairs": labelPairs,
+	// json encodes a value, use it to embed strings in a JSON body safely
+	"json": func(v any) (string, error) {
+		raw, err := json.Marshal(v)
+		return string(raw), err
+	},
+}
+
+// ParseTemplate parses the body and headers and renders them once against
+// SampleAlarm, so mistakes such as unknown fields are reported up front
+func ParseTemplate(config models.NotificationTemplate) (*Template, error) {
+	if len(config.Body) > maxTemplateBody || len(config.HTML) > maxTemplateBody {
+		return nil, fmt.Errorf("template body is longer than %d characters", maxTemplateBody)
+	}
+	if len(config.Headers) > maxTemplateHeaders {
+		return nil, fmt.Errorf("template has more than %d headers", maxTemplateHeaders)
+	}
+
+	body, err := template.New("body").Funcs(templateFuncs).Option("missingkey=zero").Parse(config.Body)
+	if err != nil {
+		return nil, err
+	}
+	t := &Template{body: body, headers: make(map[string]*template.Template, len(config.Headers))}
+	if config.HTML != "" {
+		t.html, err = htmltemplate.New("html").Funcs(htmltemplate.FuncMap(templateFuncs)).Option("missingkey=zero").Parse(config.HTML)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for name, value := range config.Headers {
+		if !validHeaderName(name) {
+			return nil, fmt.Errorf("invalid header name %q", name)
+		}
+		header, err := template.New(name).Funcs(templateFuncs).Option("missingkey=zero").Parse(value)
+		if err != nil {
+			return nil, err
+		}
+		t.headers[name] = header
+	}
+
+	if _, err := t.Render(context.Background(), SampleAlarm()); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Render executes the template for the alarm, the reminder count is read from ctx
+func (t *Template) Render(ctx context.Context, alarm models.AlarmEvent) (Rendered, error) {
+	data := TemplateData{
+		Alarm:    alarm,
+		Status:   alarm.Type,
+		ACKed:    alarm.Type == "ACK",
+		Resolved: alarm.Type == "resolved",
+		Reminder: deliveryFrom(ctx).Reminder,
+	}
+
+	var body bytes.Buffer
+	if err := t.body.Execute(&body, data); err != nil {
+		return Rendered{}, err
+	}
+	if body.Len() > maxRenderedBody {
+		return Rendered{}, fmt.Errorf("rendered body is larger than %d bytes", maxRenderedBody)
+	}
+
+	rendered := Rendered{Body: body.String(), Headers: make(map[string]string, len(t.headers))}
+	if t.html != nil {
+		var html bytes.Buffer
+		if err := t.html.Execute(&html, data); err != nil {
+			return Rendered{}, err
+		}
+		if html.Len() > maxRenderedBody {
+			return Rendered{}, fmt.Errorf("rendered HTML is larger than %d bytes", maxRenderedBody)
+		}
+		rendered.HTML = html.String()
+	}
+	for name, header := range t.headers {
+		var value bytes.Buffer
+		if err := header.Execute(&value, data); err != nil {
+			return Rendered{}, err
+		}
+		if strings.ContainsAny(value.String(), "\r\n") {
+			return Rendered{}, errors.New("header " + name + " must render to a single line")
+		}
+		rendered.Headers[name] = value.String()
+	}
+	return rendered, nil
+}
+
+// validHeaderName reports whether name is an HTTP token, as header names must be
+func validHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		isAlphaNum := r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9'
+		if !isAlphaNum && !strings.ContainsRune("!#$%&'*+-.^_`|~", r) {
+			return false
+		}
+	}
+	return true
+}
+
+// labelPairs formats labels as key=value, sorted by key
+func labelPairs(labels map[string]string) []string {
+	pairs := make([]string, 0, len(labels))
+	for _, key := range slices.Sorted(maps.Keys(labels)) {
+		pairs = append(pairs, key+"="+labels[key])
+	}
+	return pairs
+}
+
+// SampleAlarm is the alarm templates are checked and previewed against
+func SampleAlarm() models.AlarmEvent {
+	return models.AlarmEvent{
+		AlarmID:   "554e76e4-fc22-4eea-b6c6-616e5d4c8caf",
+		Name:      "db-replication-lag",
+		Type:      "triggered",
+		Timestamp: time.Date(2025, 3, 19, 11, 7, 0, 0, time.UTC),
+		Severity:  "critical",
+		Labels:    map[string]string{"team": "db", "region": "eu-west"},
+		Annotations: map[string]string{
+			"runbook_url": "https://runbooks.example.com/db-replication-lag",
+			"summary":     "Replica is 45s behind the primary",
+		},
+	}
+}
@@ -2,16 +2,27 @@ package notifiers
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/26christy/CarbonQuest/common/models"
+	"github.com/26christy/CarbonQuest/common/webhooksig"
+	"github.com/google/uuid"
 )
 
-// WebHookNotifier sends notifications to an external WebHook
+// WebHookNotifier sends notifications to an external WebHook.
+// Without a template it posts the alarm as JSON. With a secret every
+// request is signed, see package webhooksig for the headers.
 type WebHookNotifier struct {
-	URL string
+	URL      string
+	Template *Template
+	// Secret signs the payload with HMAC-SHA256, requests are not signed when it is empty
+	Secret string
+	// options are the method, headers, client and accepted statuses of the requests
+	options requestOptions
 }
 
 // NewWebHookNotifier initializes a WebHookNotifier
@@ -19,23 +30,91 @@ func NewWebHookNotifier(url string) *WebHookNotifier {
 	return &WebHookNotifier{URL: url}
 }
 
-// Notify sends an HTTP POST request to the WebHook
-func (w *WebHookNotifier) Notify(alarm models.AlarmEvent) error {
-	payload, err := json.Marshal(alarm)
+// Notify sends the alarm to the WebHook, the request is aborted when ctx is done
+func (w *WebHookNotifier) Notify(ctx context.Context, alarm models.AlarmEvent) error {
+	payload, headers, err := renderPayload(ctx, w.Template, alarm, func() ([]byte, error) {
+		return json.Marshal(alarm)
+	})
 	if err != nil {
 		return err
 	}
+	if w.Secret != "" {
+		headers = w.sign(ctx, payload, headers)
+	}
+	if err := post(ctx, w.options, w.URL, payload, headers); err != nil {
+		return fmt.Errorf("WebHookNotifier: %w", err)
+	}
+
+	fmt.Printf("WebHook Notification sent to %s\n", w.URL)
+	return nil
+}
+
+// sign adds the signature headers to the template headers, replacing any template header of the same name
+func (w *WebHookNotifier) sign(ctx context.Context, payload []byte, headers map[string]string) map[string]string {
+	deliveryID := deliveryFrom(ctx).ID
+	if deliveryID == "" {
+		deliveryID = uuid.NewString()
+	}
+	signed := http.Header{}
+	webhooksig.SetHeaders(signed, w.Secret, deliveryID, time.Now(), payload)
+
+	merged := make(map[string]string, len(headers)+len(signed))
+	for name, value := range headers {
+		if signed.Get(name) == "" {
+			merged[name] = value
+		}
+	}
+	for name := range signed {
+		merged[name] = signed.Get(name)
+	}
+	return merged
+}
+
+// renderPayload renders the template when there is one, otherwise the payload is built by fallback
+func renderPayload(ctx context.Context, tmpl *Template, alarm models.AlarmEvent, fallback func() ([]byte, error)) ([]byte, map[string]string, error) {
+	if tmpl == nil {
+		payload, err := fallback()
+		return payload, nil, err
+	}
+	rendered, err := tmpl.Render(ctx, alarm)
+	if err != nil {
+		return nil, nil, err
+	}
+	return []byte(rendered.Body), rendered.Headers, nil
+}
 
-	resp, err := http.Post(w.URL, "application/json", bytes.NewBuffer(payload))
+// post sends a JSON payload, by default as a POST that fails on any status but 2xx.
+// The template headers are set after the static headers of the options.
+func post(ctx context.Context, options requestOptions, url string, payload []byte, headers map[string]string) error {
+	method, client := options.method, options.client
+	if method == "" {
+		method = http.MethodPost
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewBuffer(payload))
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range options.headers {
+		req.Header.Set(name, value)
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("WebHookNotifier: Failed to send notification, status code: %d", resp.StatusCode)
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
 	}
+	defer resp.Body.Close()
+	recordStatus(ctx, resp.StatusCode)
 
-	fmt.Printf("WebHook Notification sent to %s\n", w.URL)
+	if !options.accepts(resp.StatusCode) {
+		return fmt.Errorf("Failed to send notification, status code: %d", resp.StatusCode)
+	}
 	return nil
 }
@@ -0,0 +1,188 @@
+package service
+
+import (
+	"cmp"
+	"errors"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/26christy/CarbonQuest/common/models"
+)
+
+// Circuit breaker settings used when neither the notifier nor the environment sets them
+const (
+	defaultFailureThreshold  = 5
+	defaultOpenSeconds       = 60
+	defaultHalfOpenSuccesses = 1
+)
+
+// ErrCircuitOpen is returned for a delivery to a notifier whose circuit breaker is open
+var ErrCircuitOpen = errors.New("circuit breaker of the notifier is open")
+
+// circuitBreaker stops deliveries to a notifier that keeps failing. After
+// FailureThreshold failed attempts in a row the circuit opens and deliveries
+// are rejected for OpenSeconds. It then lets one trial delivery through at a
+// time, and closes again after HalfOpenSuccesses of them succeed.
+// It also counts the deliveries reported in the metrics.
+type circuitBreaker struct {
+	mu       sync.Mutex
+	settings models.BreakerSettings
+	state    string
+	failures int
+	// successes counts the successful trials while half open
+	successes int
+	trial     bool
+	openedAt  time.Time
+
+	delivered int
+	failed    int
+	rejected  int
+	opened    int
+}
+
+// newCircuitBreaker returns a closed circuit breaker, settings must not have zero values
+func newCircuitBreaker(settings models.BreakerSettings) *circuitBreaker {
+	return &circuitBreaker{settings: settings, state: models.CircuitClosed}
+}
+
+// allow reports whether an attempt may be made now. Every attempt it allows
+// must be followed by record or abandon.
+func (b *circuitBreaker) allow(now time.Time) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == models.CircuitOpen && !now.Before(b.retryAt()) {
+		b.state = models.CircuitHalfOpen
+		b.successes = 0
+	}
+	switch {
+	case b.state == models.CircuitOpen, b.state == models.CircuitHalfOpen && b.trial:
+		b.rejected++
+		return ErrCircuitOpen
+	case b.state == models.CircuitHalfOpen:
+		b.trial = true
+	}
+	return nil
+}
+
+// record updates the circuit with the outcome of an attempt
+func (b *circuitBreaker) record(err error, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	halfOpen := b.state == models.CircuitHalfOpen
+	b.trial = false
+	if err == nil {
+		b.delivered++
+		b.failures = 0
+		if halfOpen {
+			b.successes++
+			if b.successes >= b.settings.HalfOpenSuccesses {
+				b.state = models.CircuitClosed
+			}
+		}
+		return
+	}
+
+	b.failed++
+	b.failures++
+	if halfOpen || b.state == models.CircuitClosed && b.failures >= b.settings.FailureThreshold {
+		b.state = models.CircuitOpen
+		b.openedAt = now
+		b.opened++
+	}
+}
+
+// abandon releases an attempt that was cut short by shutdown, without counting it
+func (b *circuitBreaker) abandon() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.trial = false
+}
+
+// configure applies new settings, the state of the circuit is kept
+func (b *circuitBreaker) configure(settings models.BreakerSettings) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.settings = settings
+}
+
+// retryAt is when an open circuit lets a trial through, callers must hold b.mu
+func (b *circuitBreaker) retryAt() time.Time {
+	return b.openedAt.Add(time.Duration(b.settings.OpenSeconds) * time.Second)
+}
+
+// status reports the state of the circuit, an open circuit that is due for a trial is reported as half open
+func (b *circuitBreaker) status(now time.Time) models.CircuitStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	status := models.CircuitStatus{State: b.state, ConsecutiveFailures: b.failures}
+	if b.state == models.CircuitClosed {
+		return status
+	}
+	openedAt, retryAt := b.openedAt, b.retryAt()
+	status.OpenedAt = &openedAt
+	if b.state == models.CircuitOpen {
+		if now.Before(retryAt) {
+			status.RetryAt = &retryAt
+		} else {
+			status.State = models.CircuitHalfOpen
+		}
+	}
+	return status
+}
+
+// metrics reports the counters of the circuit breaker
+func (b *circuitBreaker) metrics(config models.NotifierConfig, now time.Time) NotifierMetrics {
+	status := b.status(now)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return NotifierMetrics{
+		ID:            config.ID.String(),
+		Name:          config.Name,
+		Type:          config.Type,
+		Circuit:       status,
+		Delivered:     b.delivered,
+		Failed:        b.failed,
+		Rejected:      b.rejected,
+		CircuitOpened: b.opened,
+	}
+}
+
+// breakerSettings fills the settings the notifier leaves out with the service defaults
+func (s *notificationServiceImpl) breakerSettings(config models.NotifierConfig) models.BreakerSettings {
+	settings := s.breakerDefaults
+	if config.Breaker != nil {
+		settings.FailureThreshold = cmp.Or(config.Breaker.FailureThreshold, settings.FailureThreshold)
+		settings.OpenSeconds = cmp.Or(config.Breaker.OpenSeconds, settings.OpenSeconds)
+		settings.HalfOpenSuccesses = cmp.Or(config.Breaker.HalfOpenSuccesses, settings.HalfOpenSuccesses)
+	}
+	settings.FailureThreshold = cmp.Or(settings.FailureThreshold, defaultFailureThreshold)
+	settings.OpenSeconds = cmp.Or(settings.OpenSeconds, defaultOpenSeconds)
+	settings.HalfOpenSuccesses = cmp.Or(settings.HalfOpenSuccesses, defaultHalfOpenSuccesses)
+	return settings
+}
+
+// breakerDefaultsFromEnv reads BREAKER_FAILURE_THRESHOLD, BREAKER_OPEN_SECONDS and
+// BREAKER_HALF_OPEN_SUCCESSES, the settings of notifiers without their own
+func breakerDefaultsFromEnv() models.BreakerSettings {
+	read := func(key string, fallback int) int {
+		value, err := strconv.Atoi(os.Getenv(key))
+		if err != nil || value <= 0 {
+			return fallback
+		}
+		return value
+	}
+	return models.BreakerSettings{
+		FailureThreshold:  read("BREAKER_FAILURE_THRESHOLD", defaultFailureThreshold),
+		OpenSeconds:       read("BREAKER_OPEN_SECONDS", defaultOpenSeconds),
+		HalfOpenSuccesses: read("BREAKER_HALF_OPEN_SUCCESSES", defaultHalfOpenSuccesses),
+	}
+}
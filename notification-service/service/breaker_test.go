@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/26christy/CarbonQuest/common/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	breaker := newCircuitBreaker(models.BreakerSettings{FailureThreshold: 2, OpenSeconds: 60, HalfOpenSuccesses: 2})
+	now := time.Now()
+	failure := errors.New("connection refused")
+
+	// Failures below the threshold keep the circuit closed, a success resets the count
+	require.NoError(t, breaker.allow(now))
+	breaker.record(failure, now)
+	require.NoError(t, breaker.allow(now))
+	breaker.record(nil, now)
+	assert.Equal(t, 0, breaker.status(now).ConsecutiveFailures)
+
+	for range 2 {
+		require.NoError(t, breaker.allow(now))
+		breaker.record(failure, now)
+	}
+	status := breaker.status(now)
+	assert.Equal(t, models.CircuitOpen, status.State)
+	assert.Equal(t, now.Add(time.Minute), *status.RetryAt)
+	assert.ErrorIs(t, breaker.allow(now.Add(30*time.Second)), ErrCircuitOpen)
+
+	// After the open period one trial at a time is let through
+	later := now.Add(time.Minute)
+	assert.Equal(t, models.CircuitHalfOpen, breaker.status(later).State)
+	require.NoError(t, breaker.allow(later))
+	assert.ErrorIs(t, breaker.allow(later), ErrCircuitOpen, "a trial is already running")
+
+	// A failed trial opens the circuit again
+	breaker.record(failure, later)
+	assert.Equal(t, models.CircuitOpen, breaker.status(later).State)
+
+	// It closes once enough trials succeed
+	later = later.Add(time.Minute)
+	require.NoError(t, breaker.allow(later))
+	breaker.record(nil, later)
+	assert.Equal(t, models.CircuitHalfOpen, breaker.status(later).State)
+	require.NoError(t, breaker.allow(later))
+	breaker.record(nil, later)
+	assert.Equal(t, models.CircuitClosed, breaker.status(later).State)
+
+	metrics := breaker.metrics(models.NotifierConfig{Name: "pager"}, later)
+	assert.Equal(t, 4, metrics.Failed)
+	assert.Equal(t, 3, metrics.Delivered)
+	assert.Equal(t, 2, metrics.Rejected)
+	assert.Equal(t, 2, metrics.CircuitOpened)
+}
+
+func TestCircuitBreaker_Abandon(t *testing.T) {
+	breaker := newCircuitBreaker(models.BreakerSettings{FailureThreshold: 1, OpenSeconds: 1, HalfOpenSuccesses: 1})
+	now := time.Now()
+	require.NoError(t, breaker.allow(now))
+	breaker.record(errors.New("connection refused"), now)
+
+	later := now.Add(time.Second)
+	require.NoError(t, breaker.allow(later))
+	breaker.abandon()
+	assert.NoError(t, breaker.allow(later), "an abandoned trial frees the slot")
+}
+
+func TestSendNotification_CircuitOpen(t *testing.T) {
+	service := setupNotificationService()
+
+	calls := 0
+	config, err := service.RegisterNotifier(models.NotifierConfig{
+		Type:    "webhook",
+		Param:   "http://down.example.com",
+		Enabled: true,
+		Breaker: &models.BreakerSettings{FailureThreshold: 2, OpenSeconds: 60},
+	}, notifierFunc(func(ctx context.Context, alarm models.AlarmEvent) error {
+		calls++
+		return errors.New("connection refused")
+	}))
+	require.NoError(t, err)
+
+	// The circuit opens after 2 of the 3 attempts and the retry is not made
+	service.SendNotification(context.Background(), models.AlarmEvent{AlarmID: "1"})
+	service.deliveries.Wait()
+	assert.Equal(t, 2, calls)
+
+	// The next alarm is dead-lettered without calling the notifier
+	service.SendNotification(context.Background(), models.AlarmEvent{AlarmID: "2"})
+	service.deliveries.Wait()
+	assert.Equal(t, 2, calls)
+
+	deadLetters, err := service.GetDeadLetters()
+	require.NoError(t, err)
+	require.Len(t, deadLetters, 2)
+	rejected := deadLetters[1]
+	if deadLetters[0].Alarm.AlarmID == "2" {
+		rejected = deadLetters[0]
+	}
+	assert.Equal(t, 0, rejected.Attempts)
+	assert.Equal(t, ErrCircuitOpen.Error(), rejected.LastError)
+
+	// Replaying while the circuit is open leaves the dead letter untouched
+	assert.ErrorIs(t, service.ReplayDeadLetter(context.Background(), rejected.ID), ErrCircuitOpen)
+	kept, err := service.GetDeadLetter(rejected.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 0, kept.Attempts)
+
+	notifier, err := service.GetNotifier(config.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.CircuitOpen, notifier.Circuit.State)
+
+	metrics := service.Metrics()
+	require.Len(t, metrics.Notifiers, 1)
+	assert.Equal(t, models.CircuitOpen, metrics.Notifiers[0].Circuit.State)
+	assert.Equal(t, 2, metrics.Notifiers[0].Failed)
+	assert.Equal(t, 3, metrics.Notifiers[0].Rejected)
+}
+
+func TestBreakerSettings(t *testing.T) {
+	service := setupNotificationService()
+	service.breakerDefaults = models.BreakerSettings{FailureThreshold: 10}
+
+	settings := service.breakerSettings(models.NotifierConfig{Breaker: &models.BreakerSettings{OpenSeconds: 5}})
+	assert.Equal(t, models.BreakerSettings{FailureThreshold: 10, OpenSeconds: 5, HalfOpenSuccesses: defaultHalfOpenSuccesses}, settings)
+}
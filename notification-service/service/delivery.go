@@ -0,0 +1,213 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/26christy/CarbonQuest/common/models"
+	"github.com/26christy/CarbonQuest/notification-service/notifiers"
+	"github.com/google/uuid"
+)
+
+// maxRetryDelay caps the backoff between two attempts of a delivery
+const maxRetryDelay = 30 * time.Second
+
+var (
+	// ErrNotifierNotRegistered is returned when replaying a dead letter whose notifier is gone
+	ErrNotifierNotRegistered = errors.New("notifier of the dead letter is not registered")
+	// ErrNotifierDisabled is returned when replaying a dead letter whose notifier is disabled
+	ErrNotifierDisabled = errors.New("notifier of the dead letter is disabled")
+	// ErrDeliveryFailed is returned when a replayed dead letter failed again
+	ErrDeliveryFailed = errors.New("delivery failed")
+)
+
+// deliver sends the alarm through one notifier, retrying with jittered
+// exponential backoff up to the notifier's attempt limit. Every attempt
+// carries the same delivery ID. It stops with ErrCircuitOpen once the
+// circuit breaker of the notifier refuses an attempt. It returns the
+// number of attempts made and the last error.
+func (s *notificationServiceImpl) deliver(ctx context.Context, registered registeredNotifier, alarm models.AlarmEvent) (int, error) {
+	ctx = notifiers.WithDeliveryID(ctx, uuid.NewString())
+
+	maxAttempts := registered.config.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = s.maxAttempts
+	}
+	maxAttempts = max(maxAttempts, 1)
+
+	timeout := notifiers.NotifyTimeout()
+	var err error
+	for attempt := 1; ; attempt++ {
+		if ctx.Err() != nil {
+			return attempt - 1, ctx.Err()
+		}
+		if err := registered.breaker.allow(time.Now()); err != nil {
+			return attempt - 1, err
+		}
+
+		notifyCtx, cancel := context.WithTimeout(ctx, timeout)
+		notifyCtx, receipt := notifiers.WithReceipt(notifyCtx)
+		start := time.Now()
+		err = registered.notifier.Notify(notifyCtx, alarm)
+		cancel()
+		if ctx.Err() != nil {
+			// Shutdown cut the attempt short, it says nothing about the notifier
+			registered.breaker.abandon()
+		} else {
+			registered.breaker.record(err, time.Now())
+		}
+		s.recordDelivery(registered.config, alarm, attempt, start, receipt, err)
+		if err == nil || attempt >= maxAttempts {
+			return attempt, err
+		}
+
+		delay := s.backoff(attempt)
+		fmt.Printf("[Retry] Attempt %d of %d for alarm %s failed: %v, retrying in %v\n", attempt, maxAttempts, alarm.AlarmID, err, delay)
+		select {
+		case <-ctx.Done():
+			return attempt, err
+		case <-time.After(delay):
+		}
+	}
+}
+
+// backoff doubles the retry delay per attempt and picks a random
+// delay in its upper half, so retries of many deliveries spread out
+func (s *notificationServiceImpl) backoff(attempt int) time.Duration {
+	delay := s.retryDelay
+	for i := 1; i < attempt && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	delay = min(delay, maxRetryDelay)
+	if delay <= 1 {
+		return delay
+	}
+	half := delay / 2
+	return half + rand.N(delay-half)
+}
+
+// recordDelivery keeps the receipt of one attempt in the delivery log
+func (s *notificationServiceImpl) recordDelivery(config models.NotifierConfig, alarm models.AlarmEvent, attempt int, start time.Time, receipt *notifiers.Receipt, err error) {
+	record := models.DeliveryRecord{
+		ID:            uuid.New(),
+		AlarmID:       alarm.AlarmID,
+		NotifierID:    config.ID,
+		NotifierType:  config.Type,
+		NotifierParam: config.RedactedParam(),
+		Attempt:       attempt,
+		LatencyMS:     time.Since(start).Milliseconds(),
+		StatusCode:    receipt.StatusCode,
+		Delivered:     err == nil,
+		Timestamp:     start,
+	}
+	if err != nil {
+		record.Error = err.Error()
+	}
+	if saveErr := s.store.SaveDelivery(record); saveErr != nil {
+		fmt.Printf("[ERROR] Failed to record delivery of alarm %s: %v\n", alarm.AlarmID, saveErr)
+	}
+}
+
+// pruneDeliveries drops the delivery records older than the retention period
+func (s *notificationServiceImpl) pruneDeliveries(now time.Time) {
+	pruned, err := s.store.PruneDeliveries(now.Add(-s.deliveryRetention))
+	if err != nil {
+		fmt.Printf("[ERROR] Failed to prune the delivery log: %v\n", err)
+		return
+	}
+	if pruned > 0 {
+		fmt.Printf("[NotificationService] Pruned %d delivery records older than %s\n", pruned, s.deliveryRetention)
+	}
+}
+
+// GetDeliveries returns the delivery log, newest attempt first
+func (s *notificationServiceImpl) GetDeliveries(query models.DeliveryQuery) ([]models.DeliveryRecord, error) {
+	return s.store.GetDeliveries(query)
+}
+
+func (s *notificationServiceImpl) deadLetter(deadLetter models.DeadLetter) {
+	if err := s.store.SaveDeadLetter(deadLetter); err != nil {
+		fmt.Printf("[ERROR] Failed to save dead letter for alarm %s: %v\n", deadLetter.Alarm.AlarmID, err)
+	}
+}
+
+// GetDeadLetters returns the notifications that could not be delivered, oldest first
+func (s *notificationServiceImpl) GetDeadLetters() ([]models.DeadLetter, error) {
+	return s.store.GetDeadLetters()
+}
+
+func (s *notificationServiceImpl) GetDeadLetter(id uuid.UUID) (models.DeadLetter, error) {
+	return s.store.GetDeadLetter(id)
+}
+
+// ReplayDeadLetter sends a dead letter again through the notifier it failed on.
+// It is removed when delivered, otherwise it is kept with the new attempts added.
+func (s *notificationServiceImpl) ReplayDeadLetter(ctx context.Context, id uuid.UUID) error {
+	deadLetter, err := s.store.GetDeadLetter(id)
+	if err != nil {
+		return err
+	}
+
+	registered, found := s.findNotifier(deadLetter.Notifier)
+	if !found {
+		return ErrNotifierNotRegistered
+	}
+	if !registered.config.Enabled {
+		return ErrNotifierDisabled
+	}
+
+	attempts, err := s.deliver(ctx, registered, deadLetter.Alarm)
+	if attempts == 0 && errors.Is(err, ErrCircuitOpen) {
+		// Nothing was sent, the dead letter is kept as it was
+		return err
+	}
+	if err != nil {
+		deadLetter.Attempts += attempts
+		deadLetter.LastError = err.Error()
+		deadLetter.FailedAt = time.Now()
+		if saveErr := s.store.SaveDeadLetter(deadLetter); saveErr != nil {
+			return saveErr
+		}
+		return fmt.Errorf("%w after %d attempts: %v", ErrDeliveryFailed, attempts, err)
+	}
+
+	fmt.Printf("[Success] Replayed dead letter %s for alarm %s\n", id, deadLetter.Alarm.AlarmID)
+	return s.store.DeleteDeadLetter(id)
+}
+
+// DiscardDeadLetter drops a dead letter without sending it
+func (s *notificationServiceImpl) DiscardDeadLetter(id uuid.UUID) error {
+	return s.store.DeleteDeadLetter(id)
+}
+
+// deliveryRetention reads DELIVERY_RETENTION_HOURS, how long delivery records are kept
+func deliveryRetention() time.Duration {
+	hours, err := strconv.Atoi(os.Getenv("DELIVERY_RETENTION_HOURS"))
+	if err != nil || hours <= 0 {
+		hours = 168 // default is a week
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// notifyMaxAttempts reads NOTIFY_MAX_ATTEMPTS, the attempts per delivery for notifiers without their own limit
+func notifyMaxAttempts() int {
+	attempts, err := strconv.Atoi(os.Getenv("NOTIFY_MAX_ATTEMPTS"))
+	if err != nil || attempts <= 0 {
+		attempts = 3 // default is 3 attempts
+	}
+	return attempts
+}
+
+// notifyRetryDelay reads NOTIFY_RETRY_DELAY, the milliseconds before the first retry
+func notifyRetryDelay() time.Duration {
+	millis, err := strconv.Atoi(os.Getenv("NOTIFY_RETRY_DELAY"))
+	if err != nil || millis <= 0 {
+		millis = 1000 // default is 1 second
+	}
+	return time.Duration(millis) * time.Millisecond
+}
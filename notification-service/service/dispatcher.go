@@ -0,0 +1,211 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/26christy/CarbonQuest/common/models"
+	"github.com/google/uuid"
+)
+
+// ErrQueueFull is returned for a delivery refused because the queue of its notifier is full
+var ErrQueueFull = errors.New("delivery queue of the notifier is full")
+
+// queuedDelivery is an alarm waiting in the queue of a notifier
+type queuedDelivery struct {
+	// ctx carries the values of the caller but not its cancellation, the delivery outlives the call
+	ctx        context.Context
+	deadline   time.Time
+	registered registeredNotifier
+	alarm      models.AlarmEvent
+	queuedAt   time.Time
+}
+
+// deliveryQueue holds the deliveries of one notifier in the order they were sent.
+// It starts up to limit of them at a time, each of which then waits for a worker
+// of the shared pool, so a slow or hung notifier holds at most limit workers and
+// the other notifiers keep the rest. A delivery only starts once the delivery
+// before it of the same alarm has finished, so the notifications of an alarm keep
+// their order, and while it waits it holds neither a worker nor one of the limit.
+type deliveryQueue struct {
+	capacity int
+	limit    int
+
+	mu      sync.Mutex
+	waiting []queuedDelivery
+	// inFlight holds the alarm IDs with a delivery being sent
+	inFlight   map[string]bool
+	running    int
+	dispatched int
+	dropped    int
+	wait       latencyStats
+	latency    latencyStats
+}
+
+// newDeliveryQueue returns a queue of NOTIFY_QUEUE_SIZE deliveries that sends
+// up to NOTIFY_NOTIFIER_WORKERS of them at once, half the pool by default
+func (s *notificationServiceImpl) newDeliveryQueue() *deliveryQueue {
+	limit := s.notifierWorkers
+	if limit <= 0 || limit > cap(s.workers) {
+		limit = max(1, cap(s.workers)/2)
+	}
+	return &deliveryQueue{
+		capacity: s.queueSize,
+		limit:    limit,
+		inFlight: make(map[string]bool),
+	}
+}
+
+// enqueue adds the delivery unless the queue is full, callers must hold s.mu
+func (s *notificationServiceImpl) enqueue(q *deliveryQueue, delivery queuedDelivery) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.waiting) >= q.capacity {
+		q.dropped++
+		return false
+	}
+	q.waiting = append(q.waiting, delivery)
+	s.startDeliveries(q)
+	return true
+}
+
+// startDeliveries starts the waiting deliveries whose alarm has none in flight,
+// oldest first, until limit are running. Callers must hold q.mu.
+func (s *notificationServiceImpl) startDeliveries(q *deliveryQueue) {
+	for i := 0; i < len(q.waiting) && q.running < q.limit; {
+		delivery := q.waiting[i]
+		if q.inFlight[delivery.alarm.AlarmID] {
+			i++
+			continue
+		}
+		q.waiting = slices.Delete(q.waiting, i, i+1)
+		q.inFlight[delivery.alarm.AlarmID] = true
+		q.running++
+		go s.runDelivery(q, delivery)
+	}
+}
+
+// runDelivery sends the delivery with a worker of the pool, waiting while all of them are busy
+func (s *notificationServiceImpl) runDelivery(q *deliveryQueue, delivery queuedDelivery) {
+	s.workers <- struct{}{}
+	start := time.Now()
+	s.dispatch(delivery)
+	<-s.workers
+
+	q.mu.Lock()
+	delete(q.inFlight, delivery.alarm.AlarmID)
+	q.running--
+	q.dispatched++
+	q.wait.observe(start.Sub(delivery.queuedAt))
+	q.latency.observe(time.Since(delivery.queuedAt))
+	s.startDeliveries(q)
+	q.mu.Unlock()
+	s.deliveries.Done()
+}
+
+// dispatch delivers a queued alarm and dead-letters it when every attempt fails
+func (s *notificationServiceImpl) dispatch(delivery queuedDelivery) {
+	ctx, cancel := context.WithCancel(delivery.ctx)
+	if !delivery.deadline.IsZero() {
+		ctx, cancel = context.WithDeadline(delivery.ctx, delivery.deadline)
+	}
+	defer cancel()
+	stop := context.AfterFunc(s.ctx, cancel)
+	defer stop()
+
+	attempts, err := s.deliver(ctx, delivery.registered, delivery.alarm)
+	if err != nil {
+		s.deadLetterDelivery(delivery.registered, delivery.alarm, attempts, err)
+		return
+	}
+	fmt.Printf("[Success] Notification sent via %T\n", delivery.registered.notifier)
+}
+
+// deadLetterDelivery keeps a notification that could not be delivered
+func (s *notificationServiceImpl) deadLetterDelivery(registered registeredNotifier, alarm models.AlarmEvent, attempts int, err error) {
+	fmt.Printf("[Error] Failed to send notification after %d attempts: %v\n", attempts, err)
+	notifier := registered.config.Redacted()
+	notifier.Param = registered.config.RedactedParam()
+	s.deadLetter(models.DeadLetter{
+		ID:        uuid.New(),
+		Alarm:     alarm,
+		Notifier:  notifier,
+		Attempts:  attempts,
+		LastError: err.Error(),
+		FailedAt:  time.Now(),
+	})
+}
+
+// metrics reports the depth, counters and latencies of the queue
+func (q *deliveryQueue) metrics() QueueMetrics {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return QueueMetrics{
+		Depth:      len(q.waiting),
+		Capacity:   q.capacity,
+		Running:    q.running,
+		Dispatched: q.dispatched,
+		Dropped:    q.dropped,
+		Wait:       q.wait.metrics(),
+		Latency:    q.latency.metrics(),
+	}
+}
+
+// latencyStats sums up durations, callers must hold the lock of the queue
+type latencyStats struct {
+	count int64
+	total time.Duration
+	last  time.Duration
+	max   time.Duration
+}
+
+func (l *latencyStats) observe(d time.Duration) {
+	l.count++
+	l.total += d
+	l.last = d
+	l.max = max(l.max, d)
+}
+
+func (l *latencyStats) metrics() LatencyMetrics {
+	metrics := LatencyMetrics{LastMS: l.last.Milliseconds(), MaxMS: l.max.Milliseconds()}
+	if l.count > 0 {
+		metrics.AverageMS = (l.total / time.Duration(l.count)).Milliseconds()
+	}
+	return metrics
+}
+
+// notifyWorkers reads NOTIFY_WORKERS, the deliveries that may run at once across all notifiers
+func notifyWorkers() int {
+	workers, err := strconv.Atoi(os.Getenv("NOTIFY_WORKERS"))
+	if err != nil || workers <= 0 {
+		workers = 8 // default is 8 workers
+	}
+	return workers
+}
+
+// notifyNotifierWorkers reads NOTIFY_NOTIFIER_WORKERS, the deliveries one notifier
+// may send at once. Zero leaves it to the queue, which takes half the pool.
+func notifyNotifierWorkers() int {
+	workers, err := strconv.Atoi(os.Getenv("NOTIFY_NOTIFIER_WORKERS"))
+	if err != nil || workers <= 0 {
+		return 0
+	}
+	return workers
+}
+
+// notifyQueueSize reads NOTIFY_QUEUE_SIZE, the deliveries each notifier may have waiting
+func notifyQueueSize() int {
+	size, err := strconv.Atoi(os.Getenv("NOTIFY_QUEUE_SIZE"))
+	if err != nil || size <= 0 {
+		size = 100 // default is 100 deliveries
+	}
+	return size
+}
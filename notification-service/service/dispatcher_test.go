@@ -0,0 +1,224 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/26christy/CarbonQuest/common/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingNotifier records the alarms it is sent and holds the ones named in block until released
+type blockingNotifier struct {
+	mu      sync.Mutex
+	sent    []string
+	block   map[string]chan struct{}
+	started chan string
+}
+
+func newBlockingNotifier(block ...string) *blockingNotifier {
+	n := &blockingNotifier{block: make(map[string]chan struct{}), started: make(chan string, 10)}
+	for _, name := range block {
+		n.block[name] = make(chan struct{})
+	}
+	return n
+}
+
+func (n *blockingNotifier) Notify(ctx context.Context, alarm models.AlarmEvent) error {
+	n.started <- alarm.Name
+	if release, found := n.block[alarm.Name]; found {
+		<-release
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.sent = append(n.sent, alarm.Name)
+	return nil
+}
+
+func (n *blockingNotifier) release(name string) {
+	close(n.block[name])
+}
+
+func (n *blockingNotifier) names() []string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]string(nil), n.sent...)
+}
+
+// waitStarted waits for the notifier to be called with the alarms, in any order
+func waitStarted(t *testing.T, n *blockingNotifier, names ...string) {
+	t.Helper()
+	var started []string
+	for range names {
+		select {
+		case name := <-n.started:
+			started = append(started, name)
+		case <-time.After(time.Second):
+			t.Fatalf("only %v of %v were sent", started, names)
+		}
+	}
+	require.ElementsMatch(t, names, started)
+}
+
+func TestDispatcher_SlowNotifierDoesNotDelayOthers(t *testing.T) {
+	service := setupNotificationService()
+	slow := newBlockingNotifier("first")
+	fast := newBlockingNotifier()
+	service.RegisterNotifier(models.NotifierConfig{Type: "mock", Param: "slow", Enabled: true}, slow)
+	service.RegisterNotifier(models.NotifierConfig{Type: "mock", Param: "fast", Enabled: true}, fast)
+
+	service.SendNotification(context.Background(), models.AlarmEvent{AlarmID: "1", Name: "first"})
+	service.SendNotification(context.Background(), models.AlarmEvent{AlarmID: "2", Name: "second"})
+	waitStarted(t, slow, "first", "second")
+
+	// The fast notifier sends both alarms and the slow one the second while the first is stuck
+	assert.Eventually(t, func() bool {
+		return len(fast.names()) == 2 && len(slow.names()) == 1
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, []string{"second"}, slow.names())
+
+	slow.release("first")
+	service.deliveries.Wait()
+	assert.Equal(t, []string{"second", "first"}, slow.names())
+}
+
+func TestDispatcher_HungNotifierDoesNotTakeThePool(t *testing.T) {
+	service := setupNotificationService()
+	names := []string{"1", "2", "3", "4", "5", "6"}
+	hung := newBlockingNotifier(names...)
+	fast := newBlockingNotifier()
+	service.RegisterNotifier(models.NotifierConfig{Type: "mock", Param: "hung", Enabled: true}, hung)
+	service.RegisterNotifier(models.NotifierConfig{Type: "mock", Param: "fast", Enabled: true}, fast)
+
+	// More deliveries hang than there are workers, the hung notifier only gets half of them
+	for _, name := range names {
+		service.SendNotification(context.Background(), models.AlarmEvent{AlarmID: name, Name: name})
+	}
+	waitStarted(t, hung, "1", "2")
+	assert.Eventually(t, func() bool { return len(fast.names()) == len(names) }, time.Second, time.Millisecond)
+	select {
+	case name := <-hung.started:
+		t.Fatalf("%s was sent while the hung notifier held its share of the workers", name)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	metrics := service.Metrics()
+	assert.Equal(t, 2, metrics.Busy)
+	assert.Equal(t, 2, metrics.Notifiers[0].Queue.Running)
+	assert.Equal(t, 4, metrics.Notifiers[0].Queue.Depth)
+
+	for _, name := range names {
+		hung.release(name)
+	}
+	service.deliveries.Wait()
+	assert.ElementsMatch(t, names, hung.names())
+}
+
+func TestDispatcher_WaitingOnAlarmHoldsNoWorker(t *testing.T) {
+	service := setupNotificationService()
+	notifier := newBlockingNotifier("stuck")
+	service.RegisterNotifier(testConfig, notifier)
+
+	// The later notifications of the stuck alarm wait without a worker, another alarm still goes out
+	service.SendNotification(context.Background(), models.AlarmEvent{AlarmID: "1", Name: "stuck"})
+	for range 4 {
+		service.SendNotification(context.Background(), models.AlarmEvent{AlarmID: "1", Name: "reminder"})
+	}
+	service.SendNotification(context.Background(), models.AlarmEvent{AlarmID: "2", Name: "other"})
+	waitStarted(t, notifier, "stuck", "other")
+	assert.Eventually(t, func() bool { return service.Metrics().Busy == 1 }, time.Second, time.Millisecond)
+
+	notifier.release("stuck")
+	service.deliveries.Wait()
+	assert.Equal(t, []string{"other", "stuck", "reminder", "reminder", "reminder", "reminder"}, notifier.names())
+}
+
+func TestDispatcher_KeepsOrderWithinAlarm(t *testing.T) {
+	service := setupNotificationService()
+	notifier := newBlockingNotifier("triggered")
+	service.RegisterNotifier(testConfig, notifier)
+
+	service.SendNotification(context.Background(), models.AlarmEvent{AlarmID: "1", Name: "triggered"})
+	service.SendNotification(context.Background(), models.AlarmEvent{AlarmID: "1", Name: "ACK"})
+	service.SendNotification(context.Background(), models.AlarmEvent{AlarmID: "2", Name: "other"})
+	// Another alarm goes out meanwhile, the ACK of the first waits for its trigger
+	waitStarted(t, notifier, "triggered", "other")
+	select {
+	case name := <-notifier.started:
+		t.Fatalf("%s was sent before the notification queued ahead of it", name)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	notifier.release("triggered")
+	service.deliveries.Wait()
+	assert.Equal(t, []string{"other", "triggered", "ACK"}, notifier.names())
+}
+
+func TestDispatcher_BoundsWorkers(t *testing.T) {
+	service := setupNotificationService()
+	service.workers = make(chan struct{}, 1)
+	first := newBlockingNotifier("alarm")
+	second := newBlockingNotifier("alarm")
+	second.started = first.started
+	service.RegisterNotifier(models.NotifierConfig{Type: "mock", Param: "first", Enabled: true}, first)
+	service.RegisterNotifier(models.NotifierConfig{Type: "mock", Param: "second", Enabled: true}, second)
+
+	service.SendNotification(context.Background(), models.AlarmEvent{AlarmID: "1", Name: "alarm"})
+	waitStarted(t, first, "alarm")
+	select {
+	case <-first.started:
+		t.Fatal("a second delivery ran while the only worker was busy")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	metrics := service.Metrics()
+	assert.Equal(t, 1, metrics.Workers)
+	assert.Equal(t, 1, metrics.Busy)
+
+	first.release("alarm")
+	second.release("alarm")
+	service.deliveries.Wait()
+	assert.Equal(t, []string{"alarm"}, first.names())
+	assert.Equal(t, []string{"alarm"}, second.names())
+	assert.Equal(t, 0, service.Metrics().Busy)
+}
+
+func TestDispatcher_FullQueue(t *testing.T) {
+	service := setupNotificationService()
+	service.workers = make(chan struct{}, 1)
+	service.queueSize = 1
+	notifier := newBlockingNotifier("blocking")
+	config, err := service.RegisterNotifier(testConfig, notifier)
+	require.NoError(t, err)
+
+	service.SendNotification(context.Background(), models.AlarmEvent{AlarmID: "0", Name: "blocking"})
+	waitStarted(t, notifier, "blocking")
+	// One alarm waits for the worker and one fits in the queue, the rest are refused
+	for _, id := range []string{"1", "2", "3"} {
+		service.SendNotification(context.Background(), models.AlarmEvent{AlarmID: id, Name: "queued"})
+	}
+
+	deadLetters, err := service.GetDeadLetters()
+	require.NoError(t, err)
+	require.NotEmpty(t, deadLetters)
+	for _, deadLetter := range deadLetters {
+		assert.Equal(t, 0, deadLetter.Attempts)
+		assert.Equal(t, ErrQueueFull.Error(), deadLetter.LastError)
+		assert.Equal(t, config.ID, deadLetter.Notifier.ID)
+	}
+
+	queue := service.Metrics().Notifiers[0].Queue
+	assert.Equal(t, 1, queue.Capacity)
+	assert.Equal(t, len(deadLetters), queue.Dropped)
+
+	notifier.release("blocking")
+	service.deliveries.Wait()
+	queue = service.Metrics().Notifiers[0].Queue
+	assert.Equal(t, 0, queue.Depth)
+	assert.Equal(t, 0, queue.Running)
+	assert.Equal(t, 4-len(deadLetters), queue.Dispatched)
+	assert.GreaterOrEqual(t, queue.Latency.MaxMS, queue.Wait.MaxMS)
+}
@@ -1,12 +1,32 @@
 package service
 
 import (
+	"context"
+
 	"github.com/26christy/CarbonQuest/common/models"
 	"github.com/26christy/CarbonQuest/notification-service/notifiers"
+	"github.com/google/uuid"
 )
 
 type NotificationService interface {
-	SendNotification(alarm models.AlarmEvent)
-	RegisterNotifier(n notifiers.Notifier)
+	SendNotification(ctx context.Context, alarm models.AlarmEvent)
+	RegisterNotifier(config models.NotifierConfig, n notifiers.Notifier) (models.NotifierConfig, error)
+	AddNotifier(config models.NotifierConfig, n notifiers.Notifier) (models.NotifierConfig, error)
+	GetNotifiers() []models.NotifierConfig
+	GetNotifier(id uuid.UUID) (models.NotifierConfig, error)
+	UpdateNotifier(config models.NotifierConfig) (models.NotifierConfig, error)
+	DeleteNotifier(id uuid.UUID) error
+	RoutingPolicy() string
+	RestoreState() error
+	HandleAlarmChange(change models.AlarmHistoryEntry)
 	StartNotificationScheduler()
+	Shutdown(ctx context.Context) error
+
+	GetDeadLetters() ([]models.DeadLetter, error)
+	GetDeadLetter(id uuid.UUID) (models.DeadLetter, error)
+	ReplayDeadLetter(ctx context.Context, id uuid.UUID) error
+	DiscardDeadLetter(id uuid.UUID) error
+
+	GetDeliveries(query models.DeliveryQuery) ([]models.DeliveryRecord, error)
+	Metrics() NotificationMetrics
 }
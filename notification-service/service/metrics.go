@@ -0,0 +1,70 @@
+package service
+
+import (
+	"time"
+
+	"github.com/26christy/CarbonQuest/common/models"
+)
+
+// NotifierMetrics are the delivery counters, queue and circuit state of one notifier since start
+type NotifierMetrics struct {
+	ID      string               `json:"id"`
+	Name    string               `json:"name"`
+	Type    string               `json:"type"`
+	Circuit models.CircuitStatus `json:"circuit"`
+	Queue   QueueMetrics         `json:"queue"`
+	// Delivered and Failed count attempts, Rejected counts deliveries refused by an open circuit
+	Delivered int `json:"delivered"`
+	Failed    int `json:"failed"`
+	Rejected  int `json:"rejected"`
+	// CircuitOpened counts how often the circuit opened
+	CircuitOpened int `json:"circuit_opened"`
+}
+
+// QueueMetrics report the delivery queue of a notifier
+type QueueMetrics struct {
+	// Depth is the deliveries waiting for a worker, Running those being sent
+	Depth    int `json:"depth"`
+	Capacity int `json:"capacity"`
+	Running  int `json:"running"`
+	// Dispatched counts the deliveries that finished, Dropped those refused because the queue was full
+	Dispatched int `json:"dispatched"`
+	Dropped    int `json:"dropped"`
+	// Wait is the time from queueing to the first attempt, Latency the time from queueing until delivered or dead-lettered
+	Wait    LatencyMetrics `json:"wait"`
+	Latency LatencyMetrics `json:"latency"`
+}
+
+// LatencyMetrics sum up durations in milliseconds
+type LatencyMetrics struct {
+	LastMS    int64 `json:"last_ms"`
+	AverageMS int64 `json:"average_ms"`
+	MaxMS     int64 `json:"max_ms"`
+}
+
+// NotificationMetrics report the worker pool and the state of the notifiers
+type NotificationMetrics struct {
+	// Workers is the size of the pool, Busy the workers sending a delivery
+	Workers   int               `json:"workers"`
+	Busy      int               `json:"busy"`
+	Notifiers []NotifierMetrics `json:"notifiers"`
+}
+
+// Metrics reports the workers in use and the deliveries, queue and circuit state of every registered notifier
+func (s *notificationServiceImpl) Metrics() NotificationMetrics {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	metrics := NotificationMetrics{
+		Workers:   cap(s.workers),
+		Busy:      len(s.workers),
+		Notifiers: make([]NotifierMetrics, 0, len(s.notifiers)),
+	}
+	for _, registered := range s.notifiers {
+		notifier := registered.breaker.metrics(registered.config, now)
+		notifier.Queue = registered.queue.metrics()
+		metrics.Notifiers = append(metrics.Notifiers, notifier)
+	}
+	return metrics
+}
@@ -0,0 +1,205 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"time"
+
+	"github.com/26christy/CarbonQuest/common/models"
+	"github.com/26christy/CarbonQuest/notification-service/notifiers"
+	"github.com/26christy/CarbonQuest/notification-service/storage"
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrDuplicateNotifier is returned when a notifier with the same type and param is registered
+	ErrDuplicateNotifier = errors.New("a notifier with the same type and param is already registered")
+	// ErrInvalidNotifier is returned when a notifier cannot be created from its type and param
+	ErrInvalidNotifier = errors.New("invalid notifier")
+)
+
+// RegisterNotifier adds a notifier without storing it, it is used for the
+// default notifier which is registered from the environment on every start
+func (s *notificationServiceImpl) RegisterNotifier(config models.NotifierConfig, notifier notifiers.Notifier) (models.NotifierConfig, error) {
+	return s.register(registeredNotifier{config: config, notifier: notifier}, false)
+}
+
+// AddNotifier persists the notifier config and registers the notifier,
+// so that it is registered again when the service restarts
+func (s *notificationServiceImpl) AddNotifier(config models.NotifierConfig, notifier notifiers.Notifier) (models.NotifierConfig, error) {
+	return s.register(registeredNotifier{config: config, notifier: notifier, persisted: true}, true)
+}
+
+// register assigns an ID and a name when missing, and saves the config first when save is set
+func (s *notificationServiceImpl) register(registered registeredNotifier, save bool) (models.NotifierConfig, error) {
+	if err := validateRules(registered.config); err != nil {
+		return models.NotifierConfig{}, err
+	}
+	if registered.config.ID == uuid.Nil {
+		registered.config.ID = uuid.New()
+	}
+	if registered.config.Name == "" {
+		registered.config.Name = registered.config.Type
+	}
+	registered.config.Circuit = nil
+	registered.breaker = newCircuitBreaker(s.breakerSettings(registered.config))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if i := s.indexOfTarget(registered.config, uuid.Nil); i >= 0 {
+		return models.NotifierConfig{}, fmt.Errorf("%w: %s", ErrDuplicateNotifier, s.notifiers[i].config.Name)
+	}
+	if save {
+		if err := s.store.SaveNotifier(registered.config); err != nil {
+			return models.NotifierConfig{}, err
+		}
+	}
+
+	registered.queue = s.newDeliveryQueue()
+	s.notifiers = append(s.notifiers, registered)
+	fmt.Printf("[NotificationService] Registered notifier %s (%s)\n", registered.config.Name, registered.config.ID)
+	return registered.config, nil
+}
+
+// GetNotifiers returns the registered notifiers in registration order, with the state of their circuit
+func (s *notificationServiceImpl) GetNotifiers() []models.NotifierConfig {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	configs := make([]models.NotifierConfig, 0, len(s.notifiers))
+	for _, registered := range s.notifiers {
+		configs = append(configs, registered.withCircuit())
+	}
+	return configs
+}
+
+func (s *notificationServiceImpl) GetNotifier(id uuid.UUID) (models.NotifierConfig, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.indexOfID(id)
+	if i < 0 {
+		return models.NotifierConfig{}, storage.ErrNotifierNotFound
+	}
+	return s.notifiers[i].withCircuit(), nil
+}
+
+// withCircuit returns the config with the current state of the circuit breaker
+func (r registeredNotifier) withCircuit() models.NotifierConfig {
+	config := r.config
+	status := r.breaker.status(time.Now())
+	config.Circuit = &status
+	return config
+}
+
+// UpdateNotifier replaces the config of the notifier with the same ID.
+// The notifier is created again when its type, param, template, secret or HTTP options change,
+// with a closed circuit breaker. Otherwise the breaker keeps its state. Deliveries already
+// queued go out with the config they were queued with.
+func (s *notificationServiceImpl) UpdateNotifier(config models.NotifierConfig) (models.NotifierConfig, error) {
+	if err := validateRules(config); err != nil {
+		return models.NotifierConfig{}, err
+	}
+	if config.Name == "" {
+		config.Name = config.Type
+	}
+	config.Circuit = nil
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.indexOfID(config.ID)
+	if i < 0 {
+		return models.NotifierConfig{}, storage.ErrNotifierNotFound
+	}
+	if j := s.indexOfTarget(config, config.ID); j >= 0 {
+		return models.NotifierConfig{}, fmt.Errorf("%w: %s", ErrDuplicateNotifier, s.notifiers[j].config.Name)
+	}
+
+	registered := s.notifiers[i]
+	if config.Type != registered.config.Type || config.Param != registered.config.Param ||
+		config.Secret != registered.config.Secret || !reflect.DeepEqual(config.Template, registered.config.Template) ||
+		!reflect.DeepEqual(config.HTTP, registered.config.HTTP) {
+		notifier, err := notifiers.CreateNotifier(config)
+		if err != nil {
+			return models.NotifierConfig{}, fmt.Errorf("%w: %v", ErrInvalidNotifier, err)
+		}
+		registered.notifier = notifier
+		registered.breaker = newCircuitBreaker(s.breakerSettings(config))
+	} else {
+		registered.breaker.configure(s.breakerSettings(config))
+	}
+	if registered.persisted {
+		if err := s.store.UpdateNotifier(config); err != nil {
+			return models.NotifierConfig{}, err
+		}
+	}
+
+	registered.config = config
+	s.notifiers[i] = registered
+	fmt.Printf("[NotificationService] Updated notifier %s (%s)\n", config.Name, config.ID)
+	return config, nil
+}
+
+// DeleteNotifier removes the notifier, deliveries already queued still go out
+func (s *notificationServiceImpl) DeleteNotifier(id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.indexOfID(id)
+	if i < 0 {
+		return storage.ErrNotifierNotFound
+	}
+	if s.notifiers[i].persisted {
+		if err := s.store.DeleteNotifier(id); err != nil {
+			return err
+		}
+	}
+
+	s.notifiers = slices.Delete(s.notifiers, i, i+1)
+	fmt.Printf("[NotificationService] Deleted notifier %s\n", id)
+	return nil
+}
+
+// findNotifier looks the notifier of a dead letter up by ID, then by type and
+// redacted param, which stay the same across restarts while the ID of the
+// default notifier does not. A redacted param shared by several notifiers finds none.
+func (s *notificationServiceImpl) findNotifier(config models.NotifierConfig) (registeredNotifier, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if i := s.indexOfID(config.ID); i >= 0 {
+		return s.notifiers[i], true
+	}
+	var (
+		found   registeredNotifier
+		matches int
+	)
+	for _, registered := range s.notifiers {
+		if registered.config.Type == config.Type && registered.config.RedactedParam() == config.RedactedParam() {
+			found = registered
+			matches++
+		}
+	}
+	return found, matches == 1
+}
+
+// indexOfID returns the position of the notifier with the ID or -1, callers must hold s.mu
+func (s *notificationServiceImpl) indexOfID(id uuid.UUID) int {
+	return slices.IndexFunc(s.notifiers, func(registered registeredNotifier) bool {
+		return registered.config.ID == id
+	})
+}
+
+// indexOfTarget returns the position of a notifier other than except with the
+// same type and param as config or -1, callers must hold s.mu
+func (s *notificationServiceImpl) indexOfTarget(config models.NotifierConfig, except uuid.UUID) int {
+	return slices.IndexFunc(s.notifiers, func(registered registeredNotifier) bool {
+		return registered.config.ID != except &&
+			registered.config.Type == config.Type &&
+			registered.config.Param == config.Param
+	})
+}
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"slices"
+
+	"github.com/26christy/CarbonQuest/common/models"
+	"github.com/26christy/CarbonQuest/common/utils"
+)
+
+// ErrInvalidRule is returned when a routing rule of a notifier is not valid
+var ErrInvalidRule = errors.New("invalid routing rule")
+
+// validateRules checks the routing rules of a notifier before it is registered
+func validateRules(config models.NotifierConfig) error {
+	if err := utils.ValidateStruct(config); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidRule, err)
+	}
+	for i, rule := range config.Rules {
+		if rule.Name == "" && len(rule.Statuses) == 0 && len(rule.Severities) == 0 && rule.MinSeverity == "" && len(rule.Labels) == 0 {
+			return fmt.Errorf("%w: rule %d matches every alarm, leave the rules out instead", ErrInvalidRule, i+1)
+		}
+		if _, err := path.Match(rule.Name, ""); err != nil {
+			return fmt.Errorf("%w: rule %d: name pattern %q: %v", ErrInvalidRule, i+1, rule.Name, err)
+		}
+	}
+	return nil
+}
+
+// route picks the enabled notifiers an alarm is sent to, callers must hold s.mu.
+// Notifiers without rules receive every alarm, with first match they only
+// receive the alarms that no notifier with rules matched.
+func (s *notificationServiceImpl) route(alarm models.AlarmEvent) []registeredNotifier {
+	targets := []registeredNotifier{}
+	var catchAll []registeredNotifier
+	for _, registered := range s.notifiers {
+		if !registered.config.Enabled {
+			continue
+		}
+		switch {
+		case len(registered.config.Rules) == 0:
+			if s.routingPolicy == models.RoutingFirstMatch {
+				catchAll = append(catchAll, registered)
+			} else {
+				targets = append(targets, registered)
+			}
+		case matchesRules(registered.config.Rules, alarm):
+			targets = append(targets, registered)
+			if s.routingPolicy == models.RoutingFirstMatch {
+				return targets
+			}
+		}
+	}
+	if s.routingPolicy == models.RoutingFirstMatch {
+		return catchAll
+	}
+	return targets
+}
+
+// matchesRules reports whether any of the rules matches the alarm
+func matchesRules(rules []models.RoutingRule, alarm models.AlarmEvent) bool {
+	return slices.ContainsFunc(rules, func(rule models.RoutingRule) bool {
+		return matchesRule(rule, alarm)
+	})
+}
+
+func matchesRule(rule models.RoutingRule, alarm models.AlarmEvent) bool {
+	if rule.Name != "" {
+		if matched, _ := path.Match(rule.Name, alarm.Name); !matched {
+			return false
+		}
+	}
+	if len(rule.Statuses) > 0 && !slices.Contains(rule.Statuses, alarm.Type) {
+		return false
+	}
+	if len(rule.Severities) > 0 && !slices.Contains(rule.Severities, alarm.Severity) {
+		return false
+	}
+	// A lower rank is more severe, alarms without a severity never match
+	if rank := models.SeverityRank(alarm.Severity); rule.MinSeverity != "" && (rank == 0 || rank > models.SeverityRank(rule.MinSeverity)) {
+		return false
+	}
+	for key, value := range rule.Labels {
+		if got, exists := alarm.Labels[key]; !exists || got != value {
+			return false
+		}
+	}
+	return true
+}
+
+// RoutingPolicy returns how alarms are routed when several notifiers match
+func (s *notificationServiceImpl) RoutingPolicy() string {
+	return s.routingPolicy
+}
+
+// notifyRoutingPolicy reads ROUTING_POLICY, fan_out or first_match
+func notifyRoutingPolicy() string {
+	policy := os.Getenv("ROUTING_POLICY")
+	if policy != models.RoutingFirstMatch {
+		policy = models.RoutingFanOut // default sends to every matching notifier
+	}
+	return policy
+}
@@ -1,81 +1,338 @@
 package service
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
-	"io"
-	"net/http"
 	"os"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/26christy/CarbonQuest/common/client"
 	"github.com/26christy/CarbonQuest/common/models"
 	"github.com/26christy/CarbonQuest/notification-service/notifiers"
+	"github.com/26christy/CarbonQuest/notification-service/storage"
 )
 
 // Concrete implementation of NotificationService
 type notificationServiceImpl struct {
-	notifiers         []notifiers.Notifier
+	notifiers         []registeredNotifier
 	alarms            map[string]models.AlarmEvent
-	notificationState map[string]NotificationState
-	httpClient        *http.Client
+	notificationState map[string]models.NotificationState
+	store             storage.NotificationStorage
+	alarmClient       *client.AlarmClient
 	mu                sync.Mutex
+	// processMu serializes processAlarm between the scheduler and pushed events
+	processMu  sync.Mutex
+	seenEvents map[string]struct{}
+	seenOrder  []string
+	// scheduled holds the timer of each triggered alarm that is not due yet
+	scheduled map[string]*time.Timer
+
+	// ctx is cancelled on shutdown to abort deliveries that are still running
+	ctx        context.Context
+	cancel     context.CancelFunc
+	done       chan struct{}
+	closing    bool
+	deliveries sync.WaitGroup
+
+	// maxAttempts and retryDelay are the retry defaults for notifiers without their own limit
+	maxAttempts int
+	retryDelay  time.Duration
+	// routingPolicy decides whether an alarm goes to every matching notifier or the first
+	routingPolicy string
+	// breakerDefaults are the circuit breaker settings of notifiers without their own
+	breakerDefaults models.BreakerSettings
+	// workers holds a token for every delivery being sent, its capacity bounds them
+	workers chan struct{}
+	// queueSize is the capacity of the delivery queue of each notifier
+	queueSize int
+	// notifierWorkers caps the workers one notifier may hold, 0 for half the pool
+	notifierWorkers int
+	// deliveryRetention is how long delivery records are kept in the store
+	deliveryRetention time.Duration
 }
 
+// registeredNotifier keeps the config a notifier was created from,
+// it identifies the notifier in dead letters. Only notifiers added
+// through the API are persisted, the default notifier is not.
+type registeredNotifier struct {
+	config    models.NotifierConfig
+	notifier  notifiers.Notifier
+	persisted bool
+	breaker   *circuitBreaker
+	queue     *deliveryQueue
+}
+
+// maxSeenEvents bounds the event IDs remembered for dropping redeliveries
+const maxSeenEvents = 10000
+
 // NewNotificationService initializes the notification service
-func NewNotificationService(client *http.Client) NotificationService {
+func NewNotificationService(alarmClient *client.AlarmClient, store storage.NotificationStorage) NotificationService {
+	ctx, cancel := context.WithCancel(context.Background())
 	return &notificationServiceImpl{
-		notifiers:         []notifiers.Notifier{},
+		notifiers:         []registeredNotifier{},
 		alarms:            make(map[string]models.AlarmEvent),
-		notificationState: make(map[string]NotificationState),
-		httpClient:        client,
+		notificationState: make(map[string]models.NotificationState),
+		store:             store,
+		alarmClient:       alarmClient,
+		seenEvents:        make(map[string]struct{}),
+		scheduled:         make(map[string]*time.Timer),
+		ctx:               ctx,
+		cancel:            cancel,
+		done:              make(chan struct{}),
+		maxAttempts:       notifyMaxAttempts(),
+		retryDelay:        notifyRetryDelay(),
+		routingPolicy:     notifyRoutingPolicy(),
+		breakerDefaults:   breakerDefaultsFromEnv(),
+		workers:           make(chan struct{}, notifyWorkers()),
+		queueSize:         notifyQueueSize(),
+		notifierWorkers:   notifyNotifierWorkers(),
+		deliveryRetention: deliveryRetention(),
 	}
 }
 
-// SendNotification sends notifications to all registered notifiers
-func (s *notificationServiceImpl) SendNotification(alarm models.AlarmEvent) {
+// SendNotification queues the alarm for the notifiers its routing rules select
+// and returns without waiting for the deliveries. They outlive ctx, which only
+// passes its values and deadline on. Each attempt gets NOTIFY_TIMEOUT seconds,
+// and deliveries still queued or running when shutdown gives up are cancelled.
+// A notification that fails every attempt, that is refused by an open circuit
+// breaker, or whose notifier has a full queue, is kept as a dead letter.
+func (s *notificationServiceImpl) SendNotification(ctx context.Context, alarm models.AlarmEvent) {
+	deadline, _ := ctx.Deadline()
+	delivery := queuedDelivery{
+		ctx:      context.WithoutCancel(ctx),
+		deadline: deadline,
+		alarm:    alarm,
+		queuedAt: time.Now(),
+	}
+
+	s.mu.Lock()
+	if s.closing {
+		s.mu.Unlock()
+		fmt.Printf("[NotificationService] Shutting down, not sending alarm %s\n", alarm.AlarmID)
+		return
+	}
+	var refused []registeredNotifier
+	for _, registered := range s.route(alarm) {
+		delivery.registered = registered
+		s.deliveries.Add(1)
+		if !s.enqueue(registered.queue, delivery) {
+			s.deliveries.Done()
+			refused = append(refused, registered)
+		}
+	}
+	s.mu.Unlock()
+
 	fmt.Printf("[NotificationService] Sending alarm: %+v\n", alarm)
 
-	for _, notifier := range s.notifiers {
-		err := notifier.Notify(alarm)
+	for _, registered := range refused {
+		s.deadLetterDelivery(registered, alarm, 0, ErrQueueFull)
+	}
+}
+
+// Shutdown stops the scheduler and waits for the queued deliveries to finish.
+// When ctx is done first the remaining deliveries are cancelled and dead-lettered.
+func (s *notificationServiceImpl) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	if s.closing {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closing = true
+	close(s.done)
+	for alarmID, timer := range s.scheduled {
+		timer.Stop()
+		delete(s.scheduled, alarmID)
+	}
+	s.mu.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		s.deliveries.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		s.cancel()
+		return nil
+	case <-ctx.Done():
+		s.cancel()
+		<-drained
+		return ctx.Err()
+	}
+}
+
+// RestoreState reloads the notification state and the notifiers
+// registered through the API from storage. It is called once on boot.
+func (s *notificationServiceImpl) RestoreState() error {
+	states, err := s.store.GetAllNotificationStates()
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	for alarmID, state := range states {
+		s.notificationState[alarmID] = state
+	}
+	s.mu.Unlock()
+
+	configs, err := s.store.GetAllNotifiers()
+	if err != nil {
+		return err
+	}
+
+	for _, config := range configs {
+		notifier, err := notifiers.CreateNotifier(config)
 		if err != nil {
-			fmt.Printf("[Error] Failed to send notification: %v\n", err)
-		} else {
-			fmt.Printf("[Success] Notification sent via %T\n", notifier)
+			fmt.Printf("[ERROR] Skipping stored notifier %s: %v\n", config.Type, err)
+			continue
+		}
+		_, err = s.register(registeredNotifier{config: config, notifier: notifier, persisted: true}, false)
+		if errors.Is(err, ErrDuplicateNotifier) {
+			// Registered twice before duplicates were rejected, keep the first one
+			fmt.Printf("[NotificationService] Removing duplicate stored notifier %s: %v\n", config.ID, err)
+			err = s.store.DeleteNotifier(config.ID)
+		}
+		if err != nil {
+			fmt.Printf("[ERROR] Skipping stored notifier %s: %v\n", config.ID, err)
 		}
 	}
+
+	fmt.Printf("[NotificationService] Restored %d notification states and %d notifiers\n", len(states), len(configs))
+	return nil
+}
+
+// saveNotificationState records the state in memory and in storage
+func (s *notificationServiceImpl) saveNotificationState(alarmID string, state models.NotificationState) {
+	s.mu.Lock()
+	s.notificationState[alarmID] = state
+	s.mu.Unlock()
+
+	if err := s.store.SaveNotificationState(alarmID, state); err != nil {
+		fmt.Printf("[ERROR] Failed to persist notification state for %s: %v\n", alarmID, err)
+	}
 }
 
-// RegisterNotifier dynamically adds a new notifier
-func (s *notificationServiceImpl) RegisterNotifier(notifier notifiers.Notifier) {
+// HandleAlarmChange reacts to a change pushed by alarm-service without
+// waiting for the next poll. Events are delivered at-least-once, so an
+// event that was already handled is ignored.
+func (s *notificationServiceImpl) HandleAlarmChange(change models.AlarmHistoryEntry) {
+	if !s.markEventSeen(change.ID.String()) {
+		fmt.Printf("[DEBUG] Ignoring duplicate alarm event %s\n", change.ID)
+		return
+	}
+
+	alarmID := change.AlarmID.String()
+	if change.NewValue == nil {
+		// The alarm was deleted, forget about it
+		s.mu.Lock()
+		s.unschedule(alarmID)
+		delete(s.alarms, alarmID)
+		delete(s.notificationState, alarmID)
+		s.mu.Unlock()
+		return
+	}
+
+	// A newer event replaces the timer of an alarm that was scheduled for later
+	event := toAlarmEvent(*change.NewValue)
+	s.mu.Lock()
+	s.alarms[alarmID] = event
+	s.unschedule(alarmID)
+	if event.Type == "triggered" && event.Timestamp.After(time.Now()) {
+		if !s.closing {
+			s.schedule(alarmID, event.Timestamp)
+		}
+		s.mu.Unlock()
+		return
+	}
+	s.mu.Unlock()
+
+	s.processAlarm(s.ctx, event, time.Now())
+}
+
+// schedule processes a triggered alarm when it is due, callers must hold s.mu.
+// The timer is created under the lock, so it is stored before it can fire.
+func (s *notificationServiceImpl) schedule(alarmID string, due time.Time) {
+	var timer *time.Timer
+	timer = time.AfterFunc(time.Until(due), func() {
+		s.mu.Lock()
+		if s.scheduled[alarmID] != timer {
+			// Replaced or stopped after it fired
+			s.mu.Unlock()
+			return
+		}
+		delete(s.scheduled, alarmID)
+		latest, exists := s.alarms[alarmID]
+		s.mu.Unlock()
+		if exists {
+			s.processAlarm(s.ctx, latest, time.Now())
+		}
+	})
+	s.scheduled[alarmID] = timer
+}
+
+// unschedule stops the timer of the alarm if it has one, callers must hold s.mu
+func (s *notificationServiceImpl) unschedule(alarmID string) {
+	if timer, exists := s.scheduled[alarmID]; exists {
+		timer.Stop()
+		delete(s.scheduled, alarmID)
+	}
+}
+
+// markEventSeen returns false when the event ID was seen before
+func (s *notificationServiceImpl) markEventSeen(eventID string) bool {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	s.notifiers = append(s.notifiers, notifier)
-	fmt.Printf("[NotificationService] Registered a new notifier: %v\n", notifier)
+	if _, seen := s.seenEvents[eventID]; seen {
+		return false
+	}
+	s.seenEvents[eventID] = struct{}{}
+	s.seenOrder = append(s.seenOrder, eventID)
+	if len(s.seenOrder) > maxSeenEvents {
+		delete(s.seenEvents, s.seenOrder[0])
+		s.seenOrder = s.seenOrder[1:]
+	}
+	return true
 }
 
+// StartNotificationScheduler polls alarm-service every POLL_INTERVAL minutes.
+// Changes are pushed as events, polling only reconciles anything missed.
 func (s *notificationServiceImpl) StartNotificationScheduler() {
 	fmt.Println("[DEBUG] Notification Scheduler Started")
-	ticker := time.NewTicker(1 * time.Minute) // Check every minute
+	pollInterval, err := strconv.Atoi(os.Getenv("POLL_INTERVAL"))
+	if err != nil || pollInterval <= 0 {
+		pollInterval = 1 // default is every minute
+	}
+	ticker := time.NewTicker(time.Duration(pollInterval) * time.Minute)
 
 	go func() {
-		for t := range ticker.C {
-			fmt.Println("[DEBUG] Running scheduler at", t)
-			s.checkAndSendNotifications()
-			fmt.Println("[DEBUG] Scheduler completed iteration at", time.Now())
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.done:
+				fmt.Println("[DEBUG] Notification Scheduler Stopped")
+				return
+			case t := <-ticker.C:
+				fmt.Println("[DEBUG] Running scheduler at", t)
+				s.checkAndSendNotifications(s.ctx)
+				s.pruneDeliveries(t)
+				fmt.Println("[DEBUG] Scheduler completed iteration at", time.Now())
+			}
 		}
 	}()
 }
 
-func (s *notificationServiceImpl) checkAndSendNotifications() {
+func (s *notificationServiceImpl) checkAndSendNotifications(ctx context.Context) {
 	now := time.Now()
 	fmt.Println("[DEBUG] Checking notifications at", now)
 
 	// Fetch latest alarms before checking
-	alarms, err := s.fetchAlarmsFromAlarmService()
+	alarms, err := s.fetchAlarmsFromAlarmService(ctx)
 	if err != nil {
 		fmt.Println("[ERROR] Failed to fetch alarms, skipping notification check")
 		return
@@ -87,138 +344,138 @@ func (s *notificationServiceImpl) checkAndSendNotifications() {
 	}
 
 	for _, alarm := range alarms {
-		s.processAlarm(alarm, now)
+		s.processAlarm(ctx, alarm, now)
 	}
 }
 
-// Process each alarm based on its state (ACKed / unACKed)
-func (s *notificationServiceImpl) processAlarm(alarm models.AlarmEvent, now time.Time) {
+// Process each alarm based on its state (ACKed / unACKed / resolved)
+func (s *notificationServiceImpl) processAlarm(ctx context.Context, alarm models.AlarmEvent, now time.Time) {
+	s.processMu.Lock()
+	defer s.processMu.Unlock()
+
 	switch alarm.Type {
 	case "triggered":
 		if time.Now().After(alarm.Timestamp) {
-			fmt.Printf("[INFO] Sending first notification for alarm %s (Triggered)\n", alarm.AlarmID)
-			s.SendNotification(alarm)
-			s.callUpdateAlarm(alarm.AlarmID, "active")
 			s.mu.Lock()
-			defer s.mu.Unlock()
-
-			s.notificationState[alarm.AlarmID] = NotificationState{
-				FirstNotificationSent: true,
-				LastNotificationAt:    now,
+			state := s.notificationState[alarm.AlarmID]
+			s.mu.Unlock()
+
+			// The first notification may have gone out before a restart or
+			// a failed status update, only retry moving the alarm to active
+			if !state.FirstNotificationSent {
+				fmt.Printf("[INFO] Sending first notification for alarm %s (Triggered)\n", alarm.AlarmID)
+				s.SendNotification(ctx, alarm)
+				s.saveNotificationState(alarm.AlarmID, models.NotificationState{
+					FirstNotificationSent: true,
+					LastNotificationAt:    now,
+				})
 			}
+			s.updateAlarmStatus(ctx, alarm.AlarmID, "active")
+		}
+	case "active", "ACK":
+		// The maps are written by pushed events and polls, read them under the lock
+		s.mu.Lock()
+		latest, state := s.alarms[alarm.AlarmID], s.notificationState[alarm.AlarmID]
+		s.mu.Unlock()
+
+		if alarm.Type == "active" {
+			s.handleUnACKedAlarm(ctx, latest, state, now)
+		} else {
+			s.handleACKedAlarm(ctx, latest, state, now)
 		}
-	case "active":
-		s.handleUnACKedAlarm(s.alarms[alarm.AlarmID], s.notificationState[alarm.AlarmID], now)
-	case "ACK":
-		s.handleACKedAlarm(s.alarms[alarm.AlarmID], s.notificationState[alarm.AlarmID], now)
+	case "resolved":
+		s.handleResolvedAlarm(ctx, alarm, now)
 	}
+	// Closed alarms, like resolved ones, get no reminders
 }
 
-type NotificationState struct {
-	FirstNotificationSent bool
-	LastNotificationAt    time.Time
+// Send the recovery notification of a resolved alarm, once, and only
+// when the alarm was notified while it was open
+func (s *notificationServiceImpl) handleResolvedAlarm(ctx context.Context, alarm models.AlarmEvent, now time.Time) {
+	s.mu.Lock()
+	state := s.notificationState[alarm.AlarmID]
+	s.mu.Unlock()
+
+	if !state.FirstNotificationSent || state.RecoverySent {
+		return
+	}
+	fmt.Printf("[INFO] Sending recovery notification for alarm %s (Resolved)\n", alarm.AlarmID)
+	s.SendNotification(ctx, alarm)
+	state.RecoverySent = true
+	state.LastNotificationAt = now
+	s.saveNotificationState(alarm.AlarmID, state)
 }
 
 // Handle ACKed alarms notifications
-func (s *notificationServiceImpl) handleACKedAlarm(alarm models.AlarmEvent, n NotificationState, now time.Time) {
-	ackDurationStr := os.Getenv("ACK_DURATION")
-	ackDurationInt, err := strconv.Atoi(ackDurationStr)
-	if err != nil {
-		fmt.Printf("Error parsing ACK_DURATION: %v", err)
-		ackDurationInt = 1440 // default is 24 hours
-	}
-	ackDuration := time.Duration(ackDurationInt) * time.Minute
+func (s *notificationServiceImpl) handleACKedAlarm(ctx context.Context, alarm models.AlarmEvent, n models.NotificationState, now time.Time) {
+	ackDuration := reminderInterval("ACK_DURATION", alarm.Severity, 1440) // default is 24 hours
 
 	if now.After(n.LastNotificationAt.Add(ackDuration)) {
 		fmt.Println("[DEBUG] Sending reminder for ACKed alarm:", alarm.AlarmID)
-		s.SendNotification(alarm)
-
-		s.mu.Lock()
-		defer s.mu.Unlock()
+		reminder := n.Reminders + 1
+		s.SendNotification(notifiers.WithDelivery(ctx, notifiers.Delivery{Reminder: reminder}), alarm)
 
 		// Update the notification state
-		s.notificationState[alarm.AlarmID] = NotificationState{
+		s.saveNotificationState(alarm.AlarmID, models.NotificationState{
 			FirstNotificationSent: true,
 			LastNotificationAt:    now, // Current time as the last notification sent
-		}
+			Reminders:             reminder,
+		})
 	} else {
 		fmt.Println("[DEBUG] Skipping ACKed alarm, NextNotificationAt not reached")
 	}
 }
 
 // Send a reminder to unacked alarm if time duration has met
-func (s *notificationServiceImpl) handleUnACKedAlarm(alarm models.AlarmEvent, n NotificationState, now time.Time) {
-	ackDurationStr := os.Getenv("UNACK_DURATION")
-	ackDurationInt, err := strconv.Atoi(ackDurationStr)
-	if err != nil {
-		fmt.Printf("Error parsing ACK_DURATION: %v", err)
-		ackDurationInt = 120 // default is 2 hours.
-	}
-
-	ackDuration := time.Duration(ackDurationInt) * time.Minute
+func (s *notificationServiceImpl) handleUnACKedAlarm(ctx context.Context, alarm models.AlarmEvent, n models.NotificationState, now time.Time) {
+	ackDuration := reminderInterval("UNACK_DURATION", alarm.Severity, 120) // default is 2 hours.
 	if now.After(n.LastNotificationAt.Add(ackDuration)) {
 		fmt.Println("[DEBUG] Sending reminder for unACKed alarm:", alarm.AlarmID)
-		s.SendNotification(alarm)
-		s.mu.Lock()
-		defer s.mu.Unlock()
+		reminder := n.Reminders + 1
+		s.SendNotification(notifiers.WithDelivery(ctx, notifiers.Delivery{Reminder: reminder}), alarm)
 
 		// Update the notification state
-		s.notificationState[alarm.AlarmID] = NotificationState{
+		s.saveNotificationState(alarm.AlarmID, models.NotificationState{
 			FirstNotificationSent: true,
 			LastNotificationAt:    now, // Current time as the last notification sent
-		}
+			Reminders:             reminder,
+		})
 	} else {
 		fmt.Println("[DEBUG] Skipping unACKed alarm, NextNotificationAt not reached")
 	}
 }
 
-type alarmResponse struct {
-	Alarms []models.Alarm `json:"alarms"`
+// reminderInterval reads the minutes between reminders from key, ACK_DURATION or UNACK_DURATION.
+// The key with the severity appended, such as UNACK_DURATION_CRITICAL, overrides it for alarms of that severity.
+func reminderInterval(key, severity string, fallback int) time.Duration {
+	if severity != "" {
+		minutes, err := strconv.Atoi(os.Getenv(key + "_" + strings.ToUpper(severity)))
+		if err == nil && minutes > 0 {
+			return time.Duration(minutes) * time.Minute
+		}
+	}
+
+	minutes, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		fmt.Printf("Error parsing %s: %v", key, err)
+		minutes = fallback
+	}
+	return time.Duration(minutes) * time.Minute
 }
 
 // fetchAlarmsFromAlarmService retrieves alarms from alarm-service.
-func (s *notificationServiceImpl) fetchAlarmsFromAlarmService() ([]models.AlarmEvent, error) {
+func (s *notificationServiceImpl) fetchAlarmsFromAlarmService(ctx context.Context) ([]models.AlarmEvent, error) {
 	fmt.Println("[DEBUG] Fetching alarms from alarm-service...")
 
-	host := os.Getenv("HOST")
-	port := os.Getenv("ALARM_SERVICE_PORT")
-	url := fmt.Sprintf("http://%s:%s/alarms", host, port)
-
-	// call the alarm-service api
-	resp, err := http.Get(url)
+	list, err := s.alarmClient.ListAlarms(ctx, models.AlarmQuery{})
 	if err != nil {
 		fmt.Printf("[ERROR] Failed to fetch alarms: %v\n", err)
 		return nil, err
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		fmt.Printf("[ERROR] Unexpected status code from alarm-service: %d\n", resp.StatusCode)
-		return nil, fmt.Errorf("unexpected status code from alarm-service: %d", resp.StatusCode)
-	}
-
-	// Read response body
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		fmt.Printf("[ERROR] Failed to read response body: %v\n", err)
-		return nil, err
-	}
-
-	// Decode response into `alarmResponse` struct
-	var alarmResp alarmResponse
-	if err := json.Unmarshal(body, &alarmResp); err != nil {
-		fmt.Printf("[ERROR] Failed to decode alarms: %v\n", err)
-		return nil, err
-	}
 
 	var alarmEvents []models.AlarmEvent
-	for _, alarm := range alarmResp.Alarms {
-		alarmEvents = append(alarmEvents, models.AlarmEvent{
-			AlarmID:   alarm.ID.String(),
-			Name:      alarm.Name,
-			Type:      alarm.Status,
-			Timestamp: alarm.Timestamp,
-		})
+	for _, alarm := range list.Alarms {
+		alarmEvents = append(alarmEvents, toAlarmEvent(alarm))
 	}
 
 	// Store fetched alarms in memory
@@ -231,52 +488,27 @@ func (s *notificationServiceImpl) fetchAlarmsFromAlarmService() ([]models.AlarmE
 		s.alarms[event.AlarmID] = event
 	}
 
-	fmt.Printf("[DEBUG] Fetched %d alarms from alarm-service\n", len(alarmResp.Alarms))
+	fmt.Printf("[DEBUG] Fetched %d alarms from alarm-service\n", len(list.Alarms))
 	return alarmEvents, nil
 }
 
-// updateAlarmStatus updates the status of an alarm in the alarm-service.
-func (s *notificationServiceImpl) callUpdateAlarm(alarmID string, status string) (models.UpdateAlarm, bool) {
-	host := os.Getenv("HOST")
-	port := os.Getenv("ALARM_SERVICE_PORT")
-	url := fmt.Sprintf("http://%s:%s/alarms/%s", host, port, alarmID)
-
-	// Create the request payload
-	payload := map[string]string{
-		"status": status,
-	}
-
-	payloadBytes, err := json.Marshal(payload)
-	if err != nil {
-		return models.UpdateAlarm{}, false
-	}
-
-	req, err := http.NewRequest(http.MethodPut, url, bytes.NewBuffer(payloadBytes))
-	if err != nil {
-		return models.UpdateAlarm{}, false
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := s.httpClient.Do(req)
-	if err != nil {
-		return models.UpdateAlarm{}, false
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return models.UpdateAlarm{}, false
-	}
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return models.UpdateAlarm{}, false
+// toAlarmEvent converts an alarm into the payload sent to notifiers
+func toAlarmEvent(alarm models.Alarm) models.AlarmEvent {
+	return models.AlarmEvent{
+		AlarmID:     alarm.ID.String(),
+		Name:        alarm.Name,
+		Type:        alarm.Status,
+		Timestamp:   alarm.Timestamp,
+		Severity:    alarm.Severity,
+		Priority:    alarm.Priority,
+		Labels:      alarm.Labels,
+		Annotations: alarm.Annotations,
 	}
+}
 
-	var updatedAlarm models.UpdateAlarm
-	if err := json.Unmarshal(body, &updatedAlarm); err != nil {
-		return models.UpdateAlarm{}, false
+// updateAlarmStatus updates the status of an alarm in the alarm-service.
+func (s *notificationServiceImpl) updateAlarmStatus(ctx context.Context, alarmID string, status string) {
+	if _, err := s.alarmClient.UpdateAlarmStatus(ctx, alarmID, status); err != nil {
+		fmt.Printf("[ERROR] Failed to set status %s for alarm %s: %v\n", status, alarmID, err)
 	}
-
-	return updatedAlarm, true
 }
@@ -2,6 +2,9 @@ package service
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"os"
@@ -9,8 +12,11 @@ import (
 	"testing"
 	"time"
 
+	"github.com/26christy/CarbonQuest/common/client"
 	"github.com/26christy/CarbonQuest/common/models"
 	"github.com/26christy/CarbonQuest/notification-service/notifiers"
+	"github.com/26christy/CarbonQuest/notification-service/storage"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -19,7 +25,7 @@ type MockNotifier struct {
 	mock.Mock
 }
 
-func (m *MockNotifier) Notify(alarm models.AlarmEvent) error {
+func (m *MockNotifier) Notify(ctx context.Context, alarm models.AlarmEvent) error {
 	args := m.Called(alarm)
 	return args.Error(0)
 }
@@ -50,19 +56,39 @@ func setupNotificationService() *notificationServiceImpl {
 		Transport: &MockRoundTripper{}, // Use the mock RoundTripper here
 	}
 
+	ctx, cancel := context.WithCancel(context.Background())
 	return &notificationServiceImpl{
-		notifiers:         []notifiers.Notifier{},
+		notifiers:         []registeredNotifier{},
 		alarms:            make(map[string]models.AlarmEvent),
-		notificationState: make(map[string]NotificationState),
-		httpClient:        mockClient,
-		mu:                sync.Mutex{},
+		notificationState: make(map[string]models.NotificationState),
+		store:             storage.NewMemoryStorage(),
+		alarmClient: client.NewAlarmClient(client.Config{
+			BaseURL:    "http://alarm-service",
+			HTTPClient: mockClient,
+			MaxRetries: -1,
+		}),
+		mu:            sync.Mutex{},
+		seenEvents:    make(map[string]struct{}),
+		scheduled:     make(map[string]*time.Timer),
+		ctx:           ctx,
+		cancel:        cancel,
+		done:          make(chan struct{}),
+		maxAttempts:   3,
+		retryDelay:    time.Millisecond,
+		routingPolicy: models.RoutingFanOut,
+		// Tests of the circuit breaker set their own threshold
+		breakerDefaults: models.BreakerSettings{FailureThreshold: 100},
+		workers:         make(chan struct{}, 4),
+		queueSize:       10,
 	}
 }
 
+var testConfig = models.NotifierConfig{Type: "mock", Enabled: true}
+
 func TestSendNotification(t *testing.T) {
 	service := setupNotificationService()
 	mockNotifier := new(MockNotifier)
-	service.RegisterNotifier(mockNotifier)
+	service.RegisterNotifier(testConfig, mockNotifier)
 
 	alarm := models.AlarmEvent{
 		AlarmID:   "123",
@@ -73,7 +99,8 @@ func TestSendNotification(t *testing.T) {
 
 	mockNotifier.On("Notify", alarm).Return(nil)
 
-	service.SendNotification(alarm)
+	service.SendNotification(context.Background(), alarm)
+	service.deliveries.Wait()
 	mockNotifier.AssertCalled(t, "Notify", alarm)
 }
 
@@ -82,7 +109,15 @@ func TestRegisterNotifier(t *testing.T) {
 	mockNotifier := new(MockNotifier)
 
 	assert.Equal(t, 0, len(service.notifiers))
-	service.RegisterNotifier(mockNotifier)
+	config, err := service.RegisterNotifier(testConfig, mockNotifier)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(service.notifiers))
+	assert.NotEqual(t, uuid.Nil, config.ID)
+	assert.Equal(t, "mock", config.Name, "the name defaults to the type")
+
+	// The same target can't be registered twice
+	_, err = service.RegisterNotifier(models.NotifierConfig{Name: "again", Type: "mock", Enabled: true}, mockNotifier)
+	assert.ErrorIs(t, err, ErrDuplicateNotifier)
 	assert.Equal(t, 1, len(service.notifiers))
 }
 
@@ -103,11 +138,11 @@ func TestHandleACKedAlarm(t *testing.T) {
 		Type:      "ACK",
 		Timestamp: time.Now(),
 	}
-	state := NotificationState{
+	state := models.NotificationState{
 		LastNotificationAt: time.Now().Add(-2 * time.Minute),
 	}
 
-	service.handleACKedAlarm(alarm, state, time.Now())
+	service.handleACKedAlarm(context.Background(), alarm, state, time.Now())
 	assert.True(t, service.notificationState["123"].FirstNotificationSent)
 }
 
@@ -121,10 +156,832 @@ func TestHandleUnACKedAlarm(t *testing.T) {
 		Type:      "active",
 		Timestamp: time.Now(),
 	}
-	state := NotificationState{
+	state := models.NotificationState{
 		LastNotificationAt: time.Now().Add(-2 * time.Minute),
 	}
 
-	service.handleUnACKedAlarm(alarm, state, time.Now())
+	service.handleUnACKedAlarm(context.Background(), alarm, state, time.Now())
 	assert.True(t, service.notificationState["123"].FirstNotificationSent)
 }
+
+func TestHandleUnACKedAlarm_PersistsState(t *testing.T) {
+	service := setupNotificationService()
+
+	os.Setenv("UNACK_DURATION", "1") // 1 minute
+	alarm := models.AlarmEvent{
+		AlarmID:   "123",
+		Name:      "UnACKed Alarm",
+		Type:      "active",
+		Timestamp: time.Now(),
+	}
+	state := models.NotificationState{
+		LastNotificationAt: time.Now().Add(-2 * time.Minute),
+	}
+
+	service.handleUnACKedAlarm(context.Background(), alarm, state, time.Now())
+
+	states, err := service.store.GetAllNotificationStates()
+	assert.NoError(t, err)
+	assert.True(t, states["123"].FirstNotificationSent, "notification state should be written to storage")
+}
+
+func TestReminderInterval(t *testing.T) {
+	t.Setenv("UNACK_DURATION", "60")
+	t.Setenv("UNACK_DURATION_CRITICAL", "5")
+
+	assert.Equal(t, 5*time.Minute, reminderInterval("UNACK_DURATION", "critical", 120))
+	assert.Equal(t, time.Hour, reminderInterval("UNACK_DURATION", "minor", 120), "severities without their own interval use the base")
+	assert.Equal(t, time.Hour, reminderInterval("UNACK_DURATION", "", 120))
+	assert.Equal(t, 24*time.Hour, reminderInterval("ACK_DURATION_UNSET", "critical", 1440), "the fallback applies without any setting")
+}
+
+func TestHandleUnACKedAlarm_SeverityInterval(t *testing.T) {
+	service := setupNotificationService()
+	t.Setenv("UNACK_DURATION", "120")
+	t.Setenv("UNACK_DURATION_CRITICAL", "5")
+
+	mockNotifier := new(MockNotifier)
+	mockNotifier.On("Notify", mock.Anything).Return(nil)
+	service.RegisterNotifier(testConfig, mockNotifier)
+
+	state := models.NotificationState{FirstNotificationSent: true, LastNotificationAt: time.Now().Add(-10 * time.Minute)}
+	service.handleUnACKedAlarm(context.Background(), models.AlarmEvent{AlarmID: "minor", Severity: "minor"}, state, time.Now())
+	service.handleUnACKedAlarm(context.Background(), models.AlarmEvent{AlarmID: "critical", Severity: "critical"}, state, time.Now())
+	service.deliveries.Wait()
+
+	mockNotifier.AssertNumberOfCalls(t, "Notify", 1)
+	mockNotifier.AssertCalled(t, "Notify", models.AlarmEvent{AlarmID: "critical", Severity: "critical"})
+}
+
+func TestHandleUnACKedAlarm_CountsReminders(t *testing.T) {
+	service := setupNotificationService()
+
+	os.Setenv("UNACK_DURATION", "1") // 1 minute
+	tmpl, err := notifiers.ParseTemplate(models.NotificationTemplate{Body: "{{.Reminder}}"})
+	assert.NoError(t, err)
+
+	var reminders []string
+	service.RegisterNotifier(testConfig, notifierFunc(func(ctx context.Context, alarm models.AlarmEvent) error {
+		rendered, err := tmpl.Render(ctx, alarm)
+		reminders = append(reminders, rendered.Body)
+		return err
+	}))
+
+	alarm := models.AlarmEvent{AlarmID: "123", Name: "UnACKed Alarm", Type: "active", Timestamp: time.Now()}
+	state := models.NotificationState{
+		FirstNotificationSent: true,
+		LastNotificationAt:    time.Now().Add(-2 * time.Minute),
+	}
+
+	service.handleUnACKedAlarm(context.Background(), alarm, state, time.Now())
+	service.deliveries.Wait()
+	state = service.notificationState["123"]
+	service.handleUnACKedAlarm(context.Background(), alarm, state, time.Now().Add(2*time.Minute))
+	service.deliveries.Wait()
+
+	assert.Equal(t, []string{"1", "2"}, reminders)
+	assert.Equal(t, 2, service.notificationState["123"].Reminders)
+}
+
+func TestAddNotifier(t *testing.T) {
+	service := setupNotificationService()
+	config, err := service.AddNotifier(models.NotifierConfig{Name: "console", Type: "log", Enabled: true}, notifiers.NewLogNotifier())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(service.notifiers))
+
+	configs, err := service.store.GetAllNotifiers()
+	assert.NoError(t, err)
+	assert.Equal(t, []models.NotifierConfig{config}, configs)
+
+	_, err = service.AddNotifier(models.NotifierConfig{Type: "log", Enabled: true}, notifiers.NewLogNotifier())
+	assert.ErrorIs(t, err, ErrDuplicateNotifier)
+	configs, _ = service.store.GetAllNotifiers()
+	assert.Len(t, configs, 1, "a duplicate is not stored")
+}
+
+func TestRestoreState(t *testing.T) {
+	store := storage.NewMemoryStorage()
+	lastNotificationAt := time.Now().Add(-time.Minute)
+	store.SaveNotificationState("123", models.NotificationState{FirstNotificationSent: true, LastNotificationAt: lastNotificationAt})
+	store.SaveNotifier(models.NotifierConfig{ID: uuid.New(), Type: "log", Enabled: true})
+	store.SaveNotifier(models.NotifierConfig{ID: uuid.New(), Type: "invalid_type", Enabled: true})
+	// Registered twice before duplicates were rejected
+	duplicate := models.NotifierConfig{ID: uuid.New(), Type: "log", Enabled: true}
+	store.SaveNotifier(duplicate)
+
+	service := setupNotificationService()
+	service.store = store
+
+	err := service.RestoreState()
+	assert.NoError(t, err)
+	assert.True(t, service.notificationState["123"].FirstNotificationSent)
+	assert.Equal(t, lastNotificationAt, service.notificationState["123"].LastNotificationAt)
+	assert.Equal(t, 1, len(service.notifiers), "invalid and duplicate stored notifiers should be skipped")
+
+	configs, _ := store.GetAllNotifiers()
+	assert.NotContains(t, configs, duplicate, "the stored duplicate is removed")
+}
+
+func TestProcessAlarm_TriggeredAlreadyNotified(t *testing.T) {
+	service := setupNotificationService()
+	mockNotifier := new(MockNotifier)
+	service.RegisterNotifier(testConfig, mockNotifier)
+
+	// First notification went out before a restart
+	service.notificationState["123"] = models.NotificationState{
+		FirstNotificationSent: true,
+		LastNotificationAt:    time.Now().Add(-time.Minute),
+	}
+
+	alarm := models.AlarmEvent{
+		AlarmID:   "123",
+		Name:      "Triggered Alarm",
+		Type:      "triggered",
+		Timestamp: time.Now().Add(-time.Hour),
+	}
+
+	service.processAlarm(context.Background(), alarm, time.Now())
+	service.deliveries.Wait()
+	mockNotifier.AssertNotCalled(t, "Notify", mock.Anything)
+}
+
+func TestProcessAlarm_Resolved(t *testing.T) {
+	service := setupNotificationService()
+	t.Setenv("UNACK_DURATION", "1")
+	t.Setenv("ACK_DURATION", "1")
+	mockNotifier := new(MockNotifier)
+	mockNotifier.On("Notify", mock.Anything).Return(nil)
+	service.RegisterNotifier(testConfig, mockNotifier)
+
+	// Nobody was told about the alarm, so there is nothing to recover from
+	quiet := models.AlarmEvent{AlarmID: "quiet", Name: "Quiet Alarm", Type: "resolved"}
+	service.processAlarm(context.Background(), quiet, time.Now())
+	service.deliveries.Wait()
+	mockNotifier.AssertNotCalled(t, "Notify", mock.Anything)
+
+	service.notificationState["123"] = models.NotificationState{
+		FirstNotificationSent: true,
+		LastNotificationAt:    time.Now().Add(-time.Hour),
+		Reminders:             3,
+	}
+	alarm := models.AlarmEvent{AlarmID: "123", Name: "Resolved Alarm", Type: "resolved"}
+	service.alarms["123"] = alarm
+
+	// The recovery notification is sent once, later polls send no reminders
+	service.processAlarm(context.Background(), alarm, time.Now())
+	service.processAlarm(context.Background(), alarm, time.Now().Add(time.Hour))
+	alarm.Type = "closed"
+	service.alarms["123"] = alarm
+	service.processAlarm(context.Background(), alarm, time.Now().Add(2*time.Hour))
+	service.deliveries.Wait()
+
+	mockNotifier.AssertNumberOfCalls(t, "Notify", 1)
+	mockNotifier.AssertCalled(t, "Notify", mock.MatchedBy(func(event models.AlarmEvent) bool {
+		return event.AlarmID == "123" && event.Type == "resolved"
+	}))
+	states, err := service.store.GetAllNotificationStates()
+	assert.NoError(t, err)
+	assert.True(t, states["123"].RecoverySent, "the recovery is persisted so a restart does not send it again")
+	assert.Equal(t, 3, states["123"].Reminders)
+}
+
+func TestHandleAlarmChange(t *testing.T) {
+	service := setupNotificationService()
+	mockNotifier := new(MockNotifier)
+	service.RegisterNotifier(testConfig, mockNotifier)
+	mockNotifier.On("Notify", mock.Anything).Return(nil)
+
+	alarm := models.Alarm{
+		ID:          uuid.New(),
+		Name:        "Pushed Alarm",
+		Timestamp:   time.Now().Add(-time.Second),
+		Status:      "triggered",
+		Labels:      map[string]string{"team": "db"},
+		Annotations: map[string]string{"runbook_url": "https://runbooks.example.com/pushed"},
+	}
+	change := models.AlarmHistoryEntry{
+		ID:       uuid.New(),
+		AlarmID:  alarm.ID,
+		Action:   models.AlarmCreated,
+		NewValue: &alarm,
+	}
+
+	// A triggered alarm is notified as soon as the event arrives
+	service.HandleAlarmChange(change)
+	service.deliveries.Wait()
+	mockNotifier.AssertNumberOfCalls(t, "Notify", 1)
+	mockNotifier.AssertCalled(t, "Notify", mock.MatchedBy(func(event models.AlarmEvent) bool {
+		return event.Labels["team"] == "db" && event.Annotations["runbook_url"] == "https://runbooks.example.com/pushed"
+	}))
+	assert.True(t, service.notificationState[alarm.ID.String()].FirstNotificationSent)
+
+	// Redelivery of the same event is ignored
+	service.HandleAlarmChange(change)
+	mockNotifier.AssertNumberOfCalls(t, "Notify", 1)
+
+	// Deleting the alarm forgets it
+	service.HandleAlarmChange(models.AlarmHistoryEntry{
+		ID:       uuid.New(),
+		AlarmID:  alarm.ID,
+		Action:   models.AlarmDeleted,
+		OldValue: &alarm,
+	})
+	assert.NotContains(t, service.alarms, alarm.ID.String())
+	assert.NotContains(t, service.notificationState, alarm.ID.String())
+}
+
+func TestHandleAlarmChange_FutureTrigger(t *testing.T) {
+	service := setupNotificationService()
+	notified := make(chan models.AlarmEvent, 1)
+	service.RegisterNotifier(testConfig, notifierFunc(func(ctx context.Context, alarm models.AlarmEvent) error {
+		notified <- alarm
+		return nil
+	}))
+
+	alarm := models.Alarm{
+		ID:        uuid.New(),
+		Name:      "Scheduled Alarm",
+		Timestamp: time.Now().Add(50 * time.Millisecond),
+		Status:    "triggered",
+	}
+	service.HandleAlarmChange(models.AlarmHistoryEntry{
+		ID:       uuid.New(),
+		AlarmID:  alarm.ID,
+		Action:   models.AlarmCreated,
+		NewValue: &alarm,
+	})
+
+	select {
+	case event := <-notified:
+		assert.Equal(t, alarm.ID.String(), event.AlarmID)
+	case <-time.After(time.Second):
+		t.Fatal("scheduled alarm was not notified when due")
+	}
+}
+
+func TestHandleAlarmChange_NewerEventReplacesSchedule(t *testing.T) {
+	service := setupNotificationService()
+	notified := make(chan models.AlarmEvent, 2)
+	service.RegisterNotifier(testConfig, notifierFunc(func(ctx context.Context, alarm models.AlarmEvent) error {
+		notified <- alarm
+		return nil
+	}))
+
+	alarm := models.Alarm{ID: uuid.New(), Name: "Scheduled Alarm", Timestamp: time.Now().Add(time.Hour), Status: "triggered"}
+	service.HandleAlarmChange(models.AlarmHistoryEntry{ID: uuid.New(), AlarmID: alarm.ID, NewValue: &alarm})
+
+	// Moved earlier, only the new time fires
+	moved := alarm
+	moved.Timestamp = time.Now().Add(50 * time.Millisecond)
+	service.HandleAlarmChange(models.AlarmHistoryEntry{ID: uuid.New(), AlarmID: alarm.ID, NewValue: &moved})
+
+	service.mu.Lock()
+	assert.Len(t, service.scheduled, 1)
+	service.mu.Unlock()
+
+	select {
+	case <-notified:
+	case <-time.After(time.Second):
+		t.Fatal("rescheduled alarm was not notified when due")
+	}
+	service.mu.Lock()
+	assert.Empty(t, service.scheduled, "a timer that fired is forgotten")
+	service.mu.Unlock()
+}
+
+func TestShutdown_StopsScheduledAlarms(t *testing.T) {
+	service := setupNotificationService()
+	notified := make(chan models.AlarmEvent, 1)
+	service.RegisterNotifier(testConfig, notifierFunc(func(ctx context.Context, alarm models.AlarmEvent) error {
+		notified <- alarm
+		return nil
+	}))
+
+	alarm := models.Alarm{ID: uuid.New(), Name: "Scheduled Alarm", Timestamp: time.Now().Add(50 * time.Millisecond), Status: "triggered"}
+	service.HandleAlarmChange(models.AlarmHistoryEntry{ID: uuid.New(), AlarmID: alarm.ID, NewValue: &alarm})
+	assert.NoError(t, service.Shutdown(context.Background()))
+	assert.Empty(t, service.scheduled)
+
+	select {
+	case <-notified:
+		t.Fatal("an alarm scheduled before shutdown was notified")
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestProcessAlarm_ConcurrentWithPushedEvents(t *testing.T) {
+	service := setupNotificationService()
+	service.RegisterNotifier(testConfig, notifierFunc(func(ctx context.Context, alarm models.AlarmEvent) error { return nil }))
+
+	alarm := models.Alarm{ID: uuid.New(), Name: "Busy Alarm", Timestamp: time.Now(), Status: "active"}
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			service.HandleAlarmChange(models.AlarmHistoryEntry{ID: uuid.New(), AlarmID: alarm.ID, NewValue: &alarm})
+		}()
+		go func() {
+			defer wg.Done()
+			service.processAlarm(context.Background(), toAlarmEvent(alarm), time.Now())
+		}()
+	}
+	wg.Wait()
+	service.deliveries.Wait()
+}
+
+// notifierFunc adapts a function to the Notifier interface
+type notifierFunc func(ctx context.Context, alarm models.AlarmEvent) error
+
+func (f notifierFunc) Notify(ctx context.Context, alarm models.AlarmEvent) error {
+	return f(ctx, alarm)
+}
+
+func TestSendNotification_RespectsDeadline(t *testing.T) {
+	service := setupNotificationService()
+
+	hung := notifierFunc(func(ctx context.Context, alarm models.AlarmEvent) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	next := new(MockNotifier)
+	next.On("Notify", mock.Anything).Return(nil)
+	service.RegisterNotifier(testConfig, hung)
+	service.RegisterNotifier(models.NotifierConfig{Type: "mock", Param: "next", Enabled: true}, next)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	service.SendNotification(ctx, models.AlarmEvent{AlarmID: "123"})
+	service.deliveries.Wait()
+
+	assert.Less(t, time.Since(start), time.Second, "a hung notifier must not block past the deadline")
+	next.AssertCalled(t, "Notify", mock.Anything)
+	deadLetters, err := service.GetDeadLetters()
+	assert.NoError(t, err)
+	if assert.Len(t, deadLetters, 1) {
+		assert.Equal(t, context.DeadlineExceeded.Error(), deadLetters[0].LastError)
+	}
+}
+
+func TestShutdown_DrainsInFlightDeliveries(t *testing.T) {
+	service := setupNotificationService()
+
+	started, release := make(chan struct{}), make(chan struct{})
+	service.RegisterNotifier(testConfig, notifierFunc(func(ctx context.Context, alarm models.AlarmEvent) error {
+		close(started)
+		<-release
+		return ctx.Err()
+	}))
+
+	delivered := make(chan struct{})
+	go func() {
+		service.SendNotification(context.Background(), models.AlarmEvent{AlarmID: "123"})
+		close(delivered)
+	}()
+	<-started
+
+	shutdown := make(chan error, 1)
+	go func() {
+		shutdown <- service.Shutdown(context.Background())
+	}()
+
+	select {
+	case <-shutdown:
+		t.Fatal("Shutdown returned before the delivery in flight finished")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	<-delivered
+	assert.NoError(t, <-shutdown)
+
+	// Nothing new is sent once shut down
+	late := new(MockNotifier)
+	service.RegisterNotifier(models.NotifierConfig{Type: "mock", Param: "late", Enabled: true}, late)
+	service.SendNotification(context.Background(), models.AlarmEvent{AlarmID: "456"})
+	late.AssertNotCalled(t, "Notify", mock.Anything)
+}
+
+func TestShutdown_CancelsDeliveriesAfterTimeout(t *testing.T) {
+	service := setupNotificationService()
+
+	started := make(chan struct{})
+	cancelled := make(chan error, 1)
+	service.RegisterNotifier(testConfig, notifierFunc(func(ctx context.Context, alarm models.AlarmEvent) error {
+		close(started)
+		<-ctx.Done()
+		cancelled <- ctx.Err()
+		return ctx.Err()
+	}))
+
+	go service.SendNotification(context.Background(), models.AlarmEvent{AlarmID: "123"})
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err := service.Shutdown(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.ErrorIs(t, <-cancelled, context.Canceled, "the delivery should be cancelled by shutdown")
+}
+
+func TestSendNotification_RetriesUntilDelivered(t *testing.T) {
+	service := setupNotificationService()
+
+	calls := 0
+	service.RegisterNotifier(testConfig, notifierFunc(func(ctx context.Context, alarm models.AlarmEvent) error {
+		calls++
+		if calls < 2 {
+			return errors.New("connection refused")
+		}
+		return nil
+	}))
+
+	service.SendNotification(context.Background(), models.AlarmEvent{AlarmID: "123"})
+	service.deliveries.Wait()
+
+	assert.Equal(t, 2, calls)
+	deadLetters, err := service.GetDeadLetters()
+	assert.NoError(t, err)
+	assert.Empty(t, deadLetters)
+}
+
+func TestSendNotification_DeadLettersAfterMaxAttempts(t *testing.T) {
+	service := setupNotificationService()
+
+	calls := 0
+	config, _ := service.RegisterNotifier(models.NotifierConfig{Type: "webhook", Param: "https://hooks.example.com/services/T000/B000/secret", MaxAttempts: 2, Enabled: true}, notifierFunc(func(ctx context.Context, alarm models.AlarmEvent) error {
+		calls++
+		return errors.New("connection refused")
+	}))
+
+	service.SendNotification(context.Background(), models.AlarmEvent{AlarmID: "123"})
+	service.deliveries.Wait()
+
+	assert.Equal(t, 2, calls, "the notifier limit overrides the service default")
+	deadLetters, err := service.GetDeadLetters()
+	assert.NoError(t, err)
+	if assert.Len(t, deadLetters, 1) {
+		assert.Equal(t, "123", deadLetters[0].Alarm.AlarmID)
+		config.Param = "https://hooks.example.com/REDACTED"
+		assert.Equal(t, config, deadLetters[0].Notifier, "the webhook URL is not stored")
+		assert.Equal(t, 2, deadLetters[0].Attempts)
+		assert.Equal(t, "connection refused", deadLetters[0].LastError)
+	}
+}
+
+func TestReplayDeadLetter(t *testing.T) {
+	service := setupNotificationService()
+
+	failing := true
+	service.RegisterNotifier(testConfig, notifierFunc(func(ctx context.Context, alarm models.AlarmEvent) error {
+		if failing {
+			return errors.New("connection refused")
+		}
+		return nil
+	}))
+	service.SendNotification(context.Background(), models.AlarmEvent{AlarmID: "123"})
+	service.deliveries.Wait()
+
+	deadLetters, _ := service.GetDeadLetters()
+	if !assert.Len(t, deadLetters, 1) {
+		return
+	}
+	id := deadLetters[0].ID
+
+	// Failing again keeps the dead letter with the attempts added
+	err := service.ReplayDeadLetter(context.Background(), id)
+	assert.ErrorIs(t, err, ErrDeliveryFailed)
+	deadLetter, err := service.GetDeadLetter(id)
+	assert.NoError(t, err)
+	assert.Equal(t, 6, deadLetter.Attempts)
+
+	failing = false
+	assert.NoError(t, service.ReplayDeadLetter(context.Background(), id))
+	_, err = service.GetDeadLetter(id)
+	assert.ErrorIs(t, err, storage.ErrDeadLetterNotFound)
+}
+
+func TestReplayDeadLetter_NotifierNotRegistered(t *testing.T) {
+	service := setupNotificationService()
+
+	deadLetter := models.DeadLetter{
+		ID:       uuid.New(),
+		Alarm:    models.AlarmEvent{AlarmID: "123"},
+		Notifier: models.NotifierConfig{Type: "webhook", Param: "http://gone.example.com"},
+		Attempts: 3,
+		FailedAt: time.Now(),
+	}
+	assert.NoError(t, service.store.SaveDeadLetter(deadLetter))
+
+	err := service.ReplayDeadLetter(context.Background(), deadLetter.ID)
+	assert.ErrorIs(t, err, ErrNotifierNotRegistered)
+
+	_, err = service.GetDeadLetter(deadLetter.ID)
+	assert.NoError(t, err, "the dead letter is kept")
+}
+
+func TestReplayDeadLetter_FindsNotifierAfterRestart(t *testing.T) {
+	service := setupNotificationService()
+
+	// The default notifier gets a new ID on every start
+	notifier := new(MockNotifier)
+	notifier.On("Notify", mock.Anything).Return(nil)
+	service.RegisterNotifier(models.NotifierConfig{Type: "webhook", Param: "https://hooks.example.com/services/secret", Enabled: true}, notifier)
+
+	deadLetter := models.DeadLetter{
+		ID:       uuid.New(),
+		Alarm:    models.AlarmEvent{AlarmID: "123"},
+		Notifier: models.NotifierConfig{ID: uuid.New(), Type: "webhook", Param: "https://hooks.example.com/REDACTED"},
+		FailedAt: time.Now(),
+	}
+	assert.NoError(t, service.store.SaveDeadLetter(deadLetter))
+	assert.NoError(t, service.ReplayDeadLetter(context.Background(), deadLetter.ID))
+	notifier.AssertCalled(t, "Notify", mock.Anything)
+
+	// Two notifiers behind the same redacted URL cannot tell which one it was
+	service.RegisterNotifier(models.NotifierConfig{Type: "webhook", Param: "https://hooks.example.com/services/other", Enabled: true}, notifier)
+	assert.NoError(t, service.store.SaveDeadLetter(deadLetter))
+	err := service.ReplayDeadLetter(context.Background(), deadLetter.ID)
+	assert.ErrorIs(t, err, ErrNotifierNotRegistered)
+}
+
+func TestPruneDeliveries(t *testing.T) {
+	service := setupNotificationService()
+	service.deliveryRetention = time.Hour
+
+	now := time.Now()
+	old := models.DeliveryRecord{ID: uuid.New(), AlarmID: "123", NotifierType: "log", Timestamp: now.Add(-2 * time.Hour)}
+	recent := models.DeliveryRecord{ID: uuid.New(), AlarmID: "123", NotifierType: "log", Timestamp: now.Add(-time.Minute)}
+	assert.NoError(t, service.store.SaveDelivery(old))
+	assert.NoError(t, service.store.SaveDelivery(recent))
+
+	service.pruneDeliveries(now)
+
+	deliveries, err := service.GetDeliveries(models.DeliveryQuery{})
+	assert.NoError(t, err)
+	assert.Equal(t, []models.DeliveryRecord{recent}, deliveries)
+}
+
+func TestBackoff(t *testing.T) {
+	service := setupNotificationService()
+	service.retryDelay = 100 * time.Millisecond
+
+	for attempt, want := range map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 3: 400 * time.Millisecond} {
+		delay := service.backoff(attempt)
+		assert.GreaterOrEqual(t, delay, want/2)
+		assert.LessOrEqual(t, delay, want)
+	}
+	assert.LessOrEqual(t, service.backoff(50), maxRetryDelay)
+}
+
+func TestSendNotification_RecordsEveryAttempt(t *testing.T) {
+	service := setupNotificationService()
+
+	calls := 0
+	config, _ := service.RegisterNotifier(models.NotifierConfig{Type: "webhook", Param: "https://hooks.example.com/services/T000/B000/secret", Enabled: true}, notifierFunc(func(ctx context.Context, alarm models.AlarmEvent) error {
+		calls++
+		if calls < 2 {
+			return errors.New("connection refused")
+		}
+		return nil
+	}))
+
+	service.SendNotification(context.Background(), models.AlarmEvent{AlarmID: "123"})
+	service.deliveries.Wait()
+
+	deliveries, err := service.GetDeliveries(models.DeliveryQuery{AlarmID: "123"})
+	assert.NoError(t, err)
+	if assert.Len(t, deliveries, 2) {
+		assert.Equal(t, 2, deliveries[0].Attempt)
+		assert.True(t, deliveries[0].Delivered)
+		assert.Empty(t, deliveries[0].Error)
+		assert.Equal(t, 1, deliveries[1].Attempt)
+		assert.False(t, deliveries[1].Delivered)
+		assert.Equal(t, "connection refused", deliveries[1].Error)
+		assert.Equal(t, config.ID, deliveries[1].NotifierID)
+		assert.Equal(t, "webhook", deliveries[1].NotifierType)
+		assert.Equal(t, "https://hooks.example.com/REDACTED", deliveries[1].NotifierParam)
+	}
+}
+
+func TestSendNotification_SkipsDisabledNotifiers(t *testing.T) {
+	service := setupNotificationService()
+
+	enabled, disabled := new(MockNotifier), new(MockNotifier)
+	enabled.On("Notify", mock.Anything).Return(nil)
+	service.RegisterNotifier(testConfig, enabled)
+	service.RegisterNotifier(models.NotifierConfig{Type: "mock", Param: "muted"}, disabled)
+
+	service.SendNotification(context.Background(), models.AlarmEvent{AlarmID: "123"})
+	service.deliveries.Wait()
+
+	enabled.AssertCalled(t, "Notify", mock.Anything)
+	disabled.AssertNotCalled(t, "Notify", mock.Anything)
+}
+
+func TestUpdateNotifier(t *testing.T) {
+	service := setupNotificationService()
+
+	logConfig, err := service.AddNotifier(models.NotifierConfig{Type: "log", Enabled: true}, notifiers.NewLogNotifier())
+	assert.NoError(t, err)
+	webhook, err := service.AddNotifier(models.NotifierConfig{Type: "webhook", Param: "http://example.com", Enabled: true}, notifiers.NewWebHookNotifier("http://example.com"))
+	assert.NoError(t, err)
+
+	// Disabling keeps the notifier but stops sending through it
+	logConfig.Name = "console"
+	logConfig.Enabled = false
+	updated, err := service.UpdateNotifier(logConfig)
+	assert.NoError(t, err)
+	assert.Equal(t, logConfig, updated)
+	assert.False(t, service.GetNotifiers()[0].Enabled)
+
+	stored, _ := service.store.GetAllNotifiers()
+	assert.Equal(t, logConfig, stored[0], "the update is persisted")
+
+	// Changing the target creates the notifier again
+	webhook.Param = "http://other.example.com"
+	_, err = service.UpdateNotifier(webhook)
+	assert.NoError(t, err)
+	registered, _ := service.findNotifier(webhook)
+	assert.Equal(t, "http://other.example.com", registered.notifier.(*notifiers.WebHookNotifier).URL)
+
+	// Pointing it at the target of another notifier is a duplicate
+	webhook.Type, webhook.Param = "log", ""
+	_, err = service.UpdateNotifier(webhook)
+	assert.ErrorIs(t, err, ErrDuplicateNotifier)
+
+	webhook.Type = "invalid_type"
+	_, err = service.UpdateNotifier(webhook)
+	assert.ErrorIs(t, err, ErrInvalidNotifier)
+
+	_, err = service.UpdateNotifier(models.NotifierConfig{ID: uuid.New(), Type: "log"})
+	assert.ErrorIs(t, err, storage.ErrNotifierNotFound)
+}
+
+func TestDeleteNotifier(t *testing.T) {
+	service := setupNotificationService()
+
+	// The default notifier is not stored, deleting it only unregisters it
+	defaultConfig, _ := service.RegisterNotifier(testConfig, new(MockNotifier))
+	added, _ := service.AddNotifier(models.NotifierConfig{Type: "log", Enabled: true}, notifiers.NewLogNotifier())
+
+	assert.NoError(t, service.DeleteNotifier(defaultConfig.ID))
+	assert.NoError(t, service.DeleteNotifier(added.ID))
+	assert.Empty(t, service.GetNotifiers())
+
+	stored, _ := service.store.GetAllNotifiers()
+	assert.Empty(t, stored)
+
+	assert.ErrorIs(t, service.DeleteNotifier(added.ID), storage.ErrNotifierNotFound)
+	_, err := service.GetNotifier(added.ID)
+	assert.ErrorIs(t, err, storage.ErrNotifierNotFound)
+}
+
+func TestRegistryIsSafeForConcurrentSends(t *testing.T) {
+	service := setupNotificationService()
+
+	notifier := new(MockNotifier)
+	notifier.On("Notify", mock.Anything).Return(nil)
+
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			config, err := service.RegisterNotifier(models.NotifierConfig{Type: "mock", Param: fmt.Sprint(i), Enabled: true}, notifier)
+			if assert.NoError(t, err) {
+				assert.NoError(t, service.DeleteNotifier(config.ID))
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			service.SendNotification(context.Background(), models.AlarmEvent{AlarmID: "123"})
+		}()
+	}
+	wg.Wait()
+
+	assert.Empty(t, service.GetNotifiers())
+}
+
+func TestReplayDeadLetter_NotifierDisabled(t *testing.T) {
+	service := setupNotificationService()
+
+	notifier := new(MockNotifier)
+	config, _ := service.RegisterNotifier(models.NotifierConfig{Type: "mock", Enabled: false}, notifier)
+
+	deadLetter := models.DeadLetter{ID: uuid.New(), Alarm: models.AlarmEvent{AlarmID: "123"}, Notifier: config, FailedAt: time.Now()}
+	assert.NoError(t, service.store.SaveDeadLetter(deadLetter))
+
+	err := service.ReplayDeadLetter(context.Background(), deadLetter.ID)
+	assert.ErrorIs(t, err, ErrNotifierDisabled)
+	notifier.AssertNotCalled(t, "Notify", mock.Anything)
+}
+
+func TestValidateRules(t *testing.T) {
+	valid := models.NotifierConfig{Type: "log", Rules: []models.RoutingRule{
+		{Name: "db-*"},
+		{Statuses: []string{"triggered", "ACK"}, Severities: []string{"critical"}},
+		{Labels: map[string]string{"team": "db"}},
+	}}
+	assert.NoError(t, validateRules(valid))
+	assert.NoError(t, validateRules(models.NotifierConfig{Type: "log"}), "no rules receive every alarm")
+
+	invalid := []models.RoutingRule{
+		{},
+		{Name: "db-["},
+		{Statuses: []string{"resolved?"}},
+		{Severities: []string{"urgent"}},
+		{MinSeverity: "urgent"},
+		{Labels: map[string]string{"": "db"}},
+	}
+	for _, rule := range invalid {
+		err := validateRules(models.NotifierConfig{Type: "log", Rules: []models.RoutingRule{rule}})
+		assert.ErrorIs(t, err, ErrInvalidRule, "%+v", rule)
+	}
+
+	_, err := setupNotificationService().RegisterNotifier(models.NotifierConfig{Type: "log", Rules: invalid[:1]}, new(MockNotifier))
+	assert.ErrorIs(t, err, ErrInvalidRule, "rules are validated on registration")
+}
+
+func TestMatchesRule(t *testing.T) {
+	alarm := models.AlarmEvent{
+		AlarmID:  "123",
+		Name:     "db-replication-lag",
+		Type:     "triggered",
+		Severity: "critical",
+		Labels:   map[string]string{"team": "db", "region": "eu-west"},
+	}
+
+	tests := []struct {
+		rule models.RoutingRule
+		want bool
+	}{
+		{models.RoutingRule{Name: "db-*"}, true},
+		{models.RoutingRule{Name: "web-*"}, false},
+		{models.RoutingRule{Statuses: []string{"active", "triggered"}}, true},
+		{models.RoutingRule{Statuses: []string{"ACK"}}, false},
+		{models.RoutingRule{Severities: []string{"critical", "major"}}, true},
+		{models.RoutingRule{Severities: []string{"info"}}, false},
+		{models.RoutingRule{MinSeverity: "major"}, true},
+		{models.RoutingRule{MinSeverity: "critical"}, true},
+		{models.RoutingRule{Labels: map[string]string{"team": "db"}}, true},
+		{models.RoutingRule{Labels: map[string]string{"team": "web"}}, false},
+		{models.RoutingRule{Labels: map[string]string{"host": ""}}, false},
+		{models.RoutingRule{Name: "db-*", Statuses: []string{"ACK"}}, false},
+		{models.RoutingRule{Name: "db-*", Statuses: []string{"triggered"}, Labels: map[string]string{"region": "eu-west"}}, true},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, matchesRule(tt.rule, alarm), "%+v", tt.rule)
+	}
+
+	minor := models.AlarmEvent{Name: "disk", Severity: "minor"}
+	assert.False(t, matchesRule(models.RoutingRule{MinSeverity: "major"}, minor), "a less severe alarm does not match")
+	assert.True(t, matchesRule(models.RoutingRule{MinSeverity: "warning"}, minor))
+	assert.False(t, matchesRule(models.RoutingRule{MinSeverity: "info"}, models.AlarmEvent{Name: "disk"}), "an alarm without a severity does not match")
+}
+
+func TestRoute(t *testing.T) {
+	service := setupNotificationService()
+
+	dbTeam := new(MockNotifier)
+	webTeam := new(MockNotifier)
+	critical := new(MockNotifier)
+	fallback := new(MockNotifier)
+	for _, n := range []*MockNotifier{dbTeam, webTeam, critical, fallback} {
+		n.On("Notify", mock.Anything).Return(nil)
+	}
+	service.RegisterNotifier(models.NotifierConfig{Type: "mock", Param: "fallback", Enabled: true}, fallback)
+	service.RegisterNotifier(models.NotifierConfig{Type: "mock", Param: "db", Enabled: true, Rules: []models.RoutingRule{{Labels: map[string]string{"team": "db"}}}}, dbTeam)
+	service.RegisterNotifier(models.NotifierConfig{Type: "mock", Param: "web", Enabled: true, Rules: []models.RoutingRule{{Name: "web-*"}}}, webTeam)
+	service.RegisterNotifier(models.NotifierConfig{Type: "mock", Param: "critical", Enabled: true, Rules: []models.RoutingRule{{Severities: []string{"critical"}}}}, critical)
+
+	dbAlarm := models.AlarmEvent{AlarmID: "1", Name: "db-down", Severity: "critical", Labels: map[string]string{"team": "db"}}
+	otherAlarm := models.AlarmEvent{AlarmID: "2", Name: "disk-full", Severity: "minor"}
+
+	params := func(targets []registeredNotifier) []string {
+		result := []string{}
+		for _, target := range targets {
+			result = append(result, target.config.Param)
+		}
+		return result
+	}
+
+	// Fan out sends to every matching notifier and to those without rules
+	assert.Equal(t, []string{"fallback", "db", "critical"}, params(service.route(dbAlarm)))
+	assert.Equal(t, []string{"fallback"}, params(service.route(otherAlarm)))
+
+	// First match stops at the first notifier with matching rules, the others are the fallback
+	service.routingPolicy = models.RoutingFirstMatch
+	assert.Equal(t, []string{"db"}, params(service.route(dbAlarm)))
+	assert.Equal(t, []string{"fallback"}, params(service.route(otherAlarm)))
+
+	service.SendNotification(context.Background(), dbAlarm)
+	service.deliveries.Wait()
+	dbTeam.AssertNumberOfCalls(t, "Notify", 1)
+	webTeam.AssertNotCalled(t, "Notify", mock.Anything)
+	critical.AssertNotCalled(t, "Notify", mock.Anything)
+	fallback.AssertNotCalled(t, "Notify", mock.Anything)
+}
@@ -0,0 +1,19 @@
+package storage
+
+import "errors"
+
+// CreateStorage is a factory function that returns a NotificationStorage based on type.
+// param is the database file path for sqlite and is ignored for memory.
+func CreateStorage(storageType, param string) (NotificationStorage, error) {
+	switch storageType {
+	case "", "memory":
+		return NewMemoryStorage(), nil
+	case "sqlite":
+		if param == "" {
+			return nil, errors.New("sqlite database path is missing")
+		}
+		return NewSQLiteStorage(param)
+	default:
+		return nil, errors.New("unsupported storage type")
+	}
+}
@@ -0,0 +1,46 @@
+package storage
+
+import (
+	"errors"
+	"time"
+
+	"github.com/26christy/CarbonQuest/common/models"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrDeadLetterNotFound = errors.New("dead letter not found")
+	ErrNotifierNotFound   = errors.New("notifier not found")
+)
+
+// Storage interface for the notification scheduler.
+// Keeps the per alarm notification state and the notifiers
+// registered through the API so both can be reloaded on boot,
+// the dead letters of notifications that could not be delivered
+// and the log of every delivery attempt.
+type NotificationStorage interface {
+	SaveNotificationState(alarmID string, state models.NotificationState) error
+	GetAllNotificationStates() (map[string]models.NotificationState, error)
+	SaveNotifier(config models.NotifierConfig) error
+	// UpdateNotifier replaces the notifier with the same ID
+	UpdateNotifier(config models.NotifierConfig) error
+	DeleteNotifier(id uuid.UUID) error
+	// GetAllNotifiers returns the notifiers in registration order
+	GetAllNotifiers() ([]models.NotifierConfig, error)
+
+	// SaveDeadLetter inserts the dead letter or replaces the one with the same ID
+	SaveDeadLetter(deadLetter models.DeadLetter) error
+	// GetDeadLetters returns the dead letters, oldest failure first
+	GetDeadLetters() ([]models.DeadLetter, error)
+	GetDeadLetter(id uuid.UUID) (models.DeadLetter, error)
+	DeleteDeadLetter(id uuid.UUID) error
+
+	SaveDelivery(record models.DeliveryRecord) error
+	// GetDeliveries returns the delivery records matching the query, newest first
+	GetDeliveries(query models.DeliveryQuery) ([]models.DeliveryRecord, error)
+	// PruneDeliveries deletes the delivery records older than before and returns how many
+	PruneDeliveries(before time.Time) (int, error)
+}
+
+// defaultDeliveryLimit caps the delivery records returned when the query sets no limit
+const defaultDeliveryLimit = 100
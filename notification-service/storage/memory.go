@@ -0,0 +1,204 @@
+package storage
+
+import (
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/26christy/CarbonQuest/common/models"
+	"github.com/google/uuid"
+)
+
+type memoryStorage struct {
+	mu          sync.RWMutex
+	states      map[string]models.NotificationState
+	notifiers   []models.NotifierConfig
+	deadLetters map[uuid.UUID]models.DeadLetter
+	deliveries  []models.DeliveryRecord
+}
+
+func NewMemoryStorage() NotificationStorage {
+	return &memoryStorage{
+		states:      make(map[string]models.NotificationState),
+		notifiers:   []models.NotifierConfig{},
+		deadLetters: make(map[uuid.UUID]models.DeadLetter),
+	}
+}
+
+func (s *memoryStorage) SaveNotificationState(alarmID string, state models.NotificationState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.states[alarmID] = state
+	return nil
+}
+
+func (s *memoryStorage) GetAllNotificationStates() (map[string]models.NotificationState, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make(map[string]models.NotificationState, len(s.states))
+	for alarmID, state := range s.states {
+		result[alarmID] = state
+	}
+	return result, nil
+}
+
+func (s *memoryStorage) SaveNotifier(config models.NotifierConfig) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.notifiers = append(s.notifiers, config)
+	return nil
+}
+
+func (s *memoryStorage) UpdateNotifier(config models.NotifierConfig) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.notifiers {
+		if s.notifiers[i].ID == config.ID {
+			s.notifiers[i] = config
+			return nil
+		}
+	}
+	return ErrNotifierNotFound
+}
+
+func (s *memoryStorage) DeleteNotifier(id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.notifiers {
+		if s.notifiers[i].ID == id {
+			s.notifiers = append(s.notifiers[:i], s.notifiers[i+1:]...)
+			return nil
+		}
+	}
+	return ErrNotifierNotFound
+}
+
+func (s *memoryStorage) GetAllNotifiers() ([]models.NotifierConfig, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]models.NotifierConfig, len(s.notifiers))
+	copy(result, s.notifiers)
+	return result, nil
+}
+
+func (s *memoryStorage) SaveDeadLetter(deadLetter models.DeadLetter) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.deadLetters[deadLetter.ID] = deadLetter
+	return nil
+}
+
+func (s *memoryStorage) GetDeadLetters() ([]models.DeadLetter, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]models.DeadLetter, 0, len(s.deadLetters))
+	for _, deadLetter := range s.deadLetters {
+		result = append(result, deadLetter)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].FailedAt.Before(result[j].FailedAt)
+	})
+	return result, nil
+}
+
+func (s *memoryStorage) GetDeadLetter(id uuid.UUID) (models.DeadLetter, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	deadLetter, exists := s.deadLetters[id]
+	if !exists {
+		return models.DeadLetter{}, ErrDeadLetterNotFound
+	}
+	return deadLetter, nil
+}
+
+func (s *memoryStorage) DeleteDeadLetter(id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.deadLetters[id]; !exists {
+		return ErrDeadLetterNotFound
+	}
+	delete(s.deadLetters, id)
+	return nil
+}
+
+func (s *memoryStorage) SaveDelivery(record models.DeliveryRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.deliveries = append(s.deliveries, record)
+	return nil
+}
+
+func (s *memoryStorage) GetDeliveries(query models.DeliveryQuery) ([]models.DeliveryRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// Walked backwards so records with the same timestamp stay newest first
+	result := []models.DeliveryRecord{}
+	for i := len(s.deliveries) - 1; i >= 0; i-- {
+		if matchesDeliveryQuery(s.deliveries[i], query) {
+			result = append(result, s.deliveries[i])
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Timestamp.After(result[j].Timestamp)
+	})
+
+	limit := query.Limit
+	if limit <= 0 {
+		limit = defaultDeliveryLimit
+	}
+	if len(result) > limit {
+		result = result[:limit]
+	}
+	return result, nil
+}
+
+func (s *memoryStorage) PruneDeliveries(before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	kept := slices.DeleteFunc(s.deliveries, func(record models.DeliveryRecord) bool {
+		return record.Timestamp.Before(before)
+	})
+	pruned := len(s.deliveries) - len(kept)
+	s.deliveries = kept
+	return pruned, nil
+}
+
+// matchesDeliveryQuery reports whether the record passes every filter in the query
+func matchesDeliveryQuery(record models.DeliveryRecord, query models.DeliveryQuery) bool {
+	if query.AlarmID != "" && record.AlarmID != query.AlarmID {
+		return false
+	}
+	if query.NotifierID != "" && record.NotifierID.String() != query.NotifierID {
+		return false
+	}
+	if query.NotifierType != "" && record.NotifierType != query.NotifierType {
+		return false
+	}
+	if query.NotifierParam != "" && record.NotifierParam != query.NotifierParam {
+		return false
+	}
+	if query.Status != "" && record.Delivered != (query.Status == "delivered") {
+		return false
+	}
+	if !query.From.IsZero() && record.Timestamp.Before(query.From) {
+		return false
+	}
+	if !query.To.IsZero() && !record.Timestamp.Before(query.To) {
+		return false
+	}
+	return true
+}
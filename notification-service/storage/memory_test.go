@@ -0,0 +1,228 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/26christy/CarbonQuest/common/models"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStorage(t *testing.T) {
+	testNotificationStorage(t, NewMemoryStorage())
+}
+
+func TestMemoryStorage_DeadLetters(t *testing.T) {
+	testDeadLetters(t, NewMemoryStorage())
+}
+
+func TestMemoryStorage_Deliveries(t *testing.T) {
+	testDeliveries(t, NewMemoryStorage())
+}
+
+// testNotificationStorage is the contract every NotificationStorage implementation must satisfy
+func testNotificationStorage(t *testing.T, storage NotificationStorage) {
+	states, err := storage.GetAllNotificationStates()
+	assert.NoError(t, err)
+	assert.Empty(t, states, "a new storage should have no notification state")
+
+	state := models.NotificationState{
+		FirstNotificationSent: true,
+		LastNotificationAt:    time.Now(),
+		Reminders:             2,
+	}
+	assert.NoError(t, storage.SaveNotificationState("123", state))
+
+	// Saving again overwrites the previous state
+	state.LastNotificationAt = state.LastNotificationAt.Add(time.Minute)
+	assert.NoError(t, storage.SaveNotificationState("123", state))
+
+	states, err = storage.GetAllNotificationStates()
+	assert.NoError(t, err)
+	assert.Len(t, states, 1)
+	assert.True(t, states["123"].FirstNotificationSent)
+	assert.True(t, state.LastNotificationAt.Equal(states["123"].LastNotificationAt))
+	assert.Equal(t, 2, states["123"].Reminders)
+	assert.False(t, states["123"].RecoverySent)
+
+	state.RecoverySent = true
+	assert.NoError(t, storage.SaveNotificationState("123", state))
+	states, err = storage.GetAllNotificationStates()
+	assert.NoError(t, err)
+	assert.True(t, states["123"].RecoverySent)
+
+	logNotifier := models.NotifierConfig{ID: uuid.New(), Name: "console", Type: "log", Enabled: true}
+	webhook := models.NotifierConfig{
+		ID:          uuid.New(),
+		Name:        "pager",
+		Type:        "webhook",
+		Param:       "http://example.com",
+		MaxAttempts: 5,
+		Enabled:     true,
+		Rules: []models.RoutingRule{
+			{Name: "db-*", Statuses: []string{"triggered"}, Labels: map[string]string{"team": "db"}},
+			{Severities: []string{"critical"}},
+		},
+		Template: &models.NotificationTemplate{
+			Body:    `{"text": {{json .Alarm.Name}}}`,
+			Headers: map[string]string{"X-Alarm-ID": "{{.Alarm.AlarmID}}"},
+		},
+		Secret: "0123456789abcdef",
+		HTTP: &models.HTTPOptions{
+			Method:           "PUT",
+			Headers:          map[string]string{"X-Source": "carbonquest"},
+			BearerToken:      "token",
+			TimeoutSeconds:   5,
+			AcceptedStatuses: []int{200, 202},
+		},
+		Breaker: &models.BreakerSettings{FailureThreshold: 3, OpenSeconds: 30},
+	}
+	assert.NoError(t, storage.SaveNotifier(logNotifier))
+	assert.NoError(t, storage.SaveNotifier(webhook))
+
+	configs, err := storage.GetAllNotifiers()
+	assert.NoError(t, err)
+	assert.Equal(t, []models.NotifierConfig{logNotifier, webhook}, configs, "notifiers should be returned in registration order")
+
+	logNotifier.Enabled = false
+	logNotifier.Name = "muted console"
+	assert.NoError(t, storage.UpdateNotifier(logNotifier))
+	assert.NoError(t, storage.DeleteNotifier(webhook.ID))
+	assert.ErrorIs(t, storage.DeleteNotifier(webhook.ID), ErrNotifierNotFound)
+	assert.ErrorIs(t, storage.UpdateNotifier(webhook), ErrNotifierNotFound)
+
+	configs, err = storage.GetAllNotifiers()
+	assert.NoError(t, err)
+	assert.Equal(t, []models.NotifierConfig{logNotifier}, configs)
+}
+
+// testDeadLetters is the dead letter contract every NotificationStorage implementation must satisfy
+func testDeadLetters(t *testing.T, storage NotificationStorage) {
+	deadLetters, err := storage.GetDeadLetters()
+	assert.NoError(t, err)
+	assert.Empty(t, deadLetters)
+
+	now := time.Now()
+	older := models.DeadLetter{
+		ID:        uuid.New(),
+		Alarm:     models.AlarmEvent{AlarmID: "123", Name: "Boiler", Type: "triggered", Timestamp: now},
+		Notifier:  models.NotifierConfig{Type: "webhook", Param: "http://example.com", MaxAttempts: 3},
+		Attempts:  3,
+		LastError: "connection refused",
+		FailedAt:  now.Add(-time.Minute),
+	}
+	newer := older
+	newer.ID = uuid.New()
+	newer.FailedAt = now
+	require.NoError(t, storage.SaveDeadLetter(newer))
+	require.NoError(t, storage.SaveDeadLetter(older))
+
+	deadLetters, err = storage.GetDeadLetters()
+	require.NoError(t, err)
+	require.Len(t, deadLetters, 2)
+	assert.Equal(t, older.ID, deadLetters[0].ID, "dead letters should be returned oldest failure first")
+	assert.Equal(t, older.Alarm.Name, deadLetters[0].Alarm.Name)
+	assert.Equal(t, older.Notifier, deadLetters[0].Notifier)
+
+	// Saving with the same ID replaces the dead letter
+	older.Attempts = 6
+	require.NoError(t, storage.SaveDeadLetter(older))
+	found, err := storage.GetDeadLetter(older.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 6, found.Attempts)
+	assert.Equal(t, "connection refused", found.LastError)
+	assert.True(t, older.FailedAt.Equal(found.FailedAt))
+
+	require.NoError(t, storage.DeleteDeadLetter(older.ID))
+	_, err = storage.GetDeadLetter(older.ID)
+	assert.ErrorIs(t, err, ErrDeadLetterNotFound)
+	assert.ErrorIs(t, storage.DeleteDeadLetter(older.ID), ErrDeadLetterNotFound)
+
+	deadLetters, err = storage.GetDeadLetters()
+	require.NoError(t, err)
+	assert.Len(t, deadLetters, 1)
+}
+
+func testDeliveries(t *testing.T, storage NotificationStorage) {
+	deliveries, err := storage.GetDeliveries(models.DeliveryQuery{})
+	assert.NoError(t, err)
+	assert.Empty(t, deliveries)
+
+	now := time.Now()
+	failed := models.DeliveryRecord{
+		ID:            uuid.New(),
+		AlarmID:       "123",
+		NotifierID:    uuid.New(),
+		NotifierType:  "webhook",
+		NotifierParam: "http://example.com",
+		Attempt:       1,
+		LatencyMS:     250,
+		StatusCode:    503,
+		Error:         "status code: 503",
+		Timestamp:     now.Add(-2 * time.Minute),
+	}
+	retried := failed
+	retried.ID = uuid.New()
+	retried.Attempt = 2
+	retried.StatusCode = 200
+	retried.Delivered = true
+	retried.Error = ""
+	retried.Timestamp = now.Add(-time.Minute)
+	logged := models.DeliveryRecord{
+		ID:           uuid.New(),
+		AlarmID:      "456",
+		NotifierType: "log",
+		Attempt:      1,
+		Delivered:    true,
+		Timestamp:    now,
+	}
+	for _, record := range []models.DeliveryRecord{failed, retried, logged} {
+		require.NoError(t, storage.SaveDelivery(record))
+	}
+
+	deliveries, err = storage.GetDeliveries(models.DeliveryQuery{})
+	require.NoError(t, err)
+	require.Len(t, deliveries, 3)
+	assert.Equal(t, logged.ID, deliveries[0].ID, "deliveries should be returned newest first")
+	assert.Equal(t, failed, withTime(deliveries[2], failed.Timestamp))
+
+	tests := []struct {
+		name  string
+		query models.DeliveryQuery
+		want  []uuid.UUID
+	}{
+		{"by alarm", models.DeliveryQuery{AlarmID: "123"}, []uuid.UUID{retried.ID, failed.ID}},
+		{"by notifier", models.DeliveryQuery{NotifierType: "webhook", NotifierParam: "http://example.com"}, []uuid.UUID{retried.ID, failed.ID}},
+		{"by notifier ID", models.DeliveryQuery{NotifierID: failed.NotifierID.String()}, []uuid.UUID{retried.ID, failed.ID}},
+		{"failed", models.DeliveryQuery{Status: "failed"}, []uuid.UUID{failed.ID}},
+		{"delivered", models.DeliveryQuery{Status: "delivered", AlarmID: "123"}, []uuid.UUID{retried.ID}},
+		{"time range", models.DeliveryQuery{From: failed.Timestamp, To: logged.Timestamp}, []uuid.UUID{retried.ID, failed.ID}},
+		{"limit", models.DeliveryQuery{Limit: 1}, []uuid.UUID{logged.ID}},
+	}
+	for _, tt := range tests {
+		deliveries, err := storage.GetDeliveries(tt.query)
+		require.NoError(t, err, tt.name)
+		ids := []uuid.UUID{}
+		for _, record := range deliveries {
+			ids = append(ids, record.ID)
+		}
+		assert.Equal(t, tt.want, ids, tt.name)
+	}
+
+	pruned, err := storage.PruneDeliveries(retried.Timestamp)
+	require.NoError(t, err)
+	assert.Equal(t, 1, pruned)
+	deliveries, err = storage.GetDeliveries(models.DeliveryQuery{})
+	require.NoError(t, err)
+	assert.Len(t, deliveries, 2, "records from before are pruned")
+}
+
+// withTime replaces the timestamp when it is the same instant, so records read back compare equal
+func withTime(record models.DeliveryRecord, timestamp time.Time) models.DeliveryRecord {
+	if record.Timestamp.Equal(timestamp) {
+		record.Timestamp = timestamp
+	}
+	return record
+}
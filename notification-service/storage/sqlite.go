@@ -0,0 +1,449 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/26christy/CarbonQuest/common/database"
+	"github.com/26christy/CarbonQuest/common/models"
+	"github.com/google/uuid"
+)
+
+// migrations for the notification schema, never edit an existing entry, always append
+var migrations = []string{
+	`CREATE TABLE IF NOT EXISTS notification_states (
+		alarm_id                TEXT PRIMARY KEY,
+		first_notification_sent INTEGER NOT NULL,
+		last_notification_at    TEXT NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS notifiers (
+		id    INTEGER PRIMARY KEY AUTOINCREMENT,
+		type  TEXT NOT NULL,
+		param TEXT NOT NULL
+	)`,
+	`ALTER TABLE notifiers ADD COLUMN max_attempts INTEGER NOT NULL DEFAULT 0`,
+	`CREATE TABLE IF NOT EXISTS dead_letters (
+		id         TEXT PRIMARY KEY,
+		alarm      TEXT NOT NULL,
+		notifier   TEXT NOT NULL,
+		attempts   INTEGER NOT NULL,
+		last_error TEXT NOT NULL,
+		failed_at  TEXT NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_dead_letters_failed_at ON dead_letters (failed_at)`,
+	`CREATE TABLE IF NOT EXISTS deliveries (
+		id             TEXT PRIMARY KEY,
+		alarm_id       TEXT NOT NULL,
+		notifier_type  TEXT NOT NULL,
+		notifier_param TEXT NOT NULL,
+		attempt        INTEGER NOT NULL,
+		latency_ms     INTEGER NOT NULL,
+		status_code    INTEGER NOT NULL,
+		delivered      INTEGER NOT NULL,
+		error          TEXT NOT NULL,
+		timestamp      TEXT NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_deliveries_timestamp ON deliveries (timestamp)`,
+	`CREATE INDEX IF NOT EXISTS idx_deliveries_alarm_id ON deliveries (alarm_id, timestamp)`,
+	`ALTER TABLE notifiers ADD COLUMN notifier_id TEXT`,
+	`ALTER TABLE notifiers ADD COLUMN name TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE notifiers ADD COLUMN enabled INTEGER NOT NULL DEFAULT 1`,
+	// Notifiers registered before they had an ID get a random version 4 UUID
+	`UPDATE notifiers SET notifier_id =
+		lower(hex(randomblob(4))) || '-' || lower(hex(randomblob(2))) || '-4' ||
+		substr(lower(hex(randomblob(2))), 2) || '-' || substr('89ab', 1 + (abs(random()) % 4), 1) ||
+		substr(lower(hex(randomblob(2))), 2) || '-' || lower(hex(randomblob(6)))
+	 WHERE notifier_id IS NULL`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS idx_notifiers_notifier_id ON notifiers (notifier_id)`,
+	`ALTER TABLE notifiers ADD COLUMN rules TEXT NOT NULL DEFAULT '[]'`,
+	`ALTER TABLE notification_states ADD COLUMN reminders INTEGER NOT NULL DEFAULT 0`,
+	`ALTER TABLE notifiers ADD COLUMN template TEXT NOT NULL DEFAULT 'null'`,
+	`ALTER TABLE notifiers ADD COLUMN secret TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE notifiers ADD COLUMN http TEXT NOT NULL DEFAULT 'null'`,
+	`ALTER TABLE notifiers ADD COLUMN breaker TEXT NOT NULL DEFAULT 'null'`,
+	`ALTER TABLE notification_states ADD COLUMN recovery_sent INTEGER NOT NULL DEFAULT 0`,
+	`ALTER TABLE deliveries ADD COLUMN notifier_id TEXT NOT NULL DEFAULT ''`,
+	`CREATE INDEX IF NOT EXISTS idx_deliveries_notifier_id ON deliveries (notifier_id, timestamp)`,
+}
+
+// sqliteStorage keeps the scheduler state and registered notifiers
+// in a database file so they survive a restart of the service
+type sqliteStorage struct {
+	db *sql.DB
+}
+
+func NewSQLiteStorage(path string) (NotificationStorage, error) {
+	db, err := database.OpenSQLite(path, migrations)
+	if err != nil {
+		return nil, err
+	}
+	return &sqliteStorage{db: db}, nil
+}
+
+// Close releases the underlying database handle
+func (s *sqliteStorage) Close() error {
+	return s.db.Close()
+}
+
+func (s *sqliteStorage) SaveNotificationState(alarmID string, state models.NotificationState) error {
+	_, err := s.db.Exec(
+		`INSERT OR REPLACE INTO notification_states (alarm_id, first_notification_sent, last_notification_at, reminders, recovery_sent)
+		 VALUES (?, ?, ?, ?, ?)`,
+		alarmID,
+		state.FirstNotificationSent,
+		database.FormatTime(state.LastNotificationAt),
+		state.Reminders,
+		state.RecoverySent,
+	)
+	return err
+}
+
+func (s *sqliteStorage) GetAllNotificationStates() (map[string]models.NotificationState, error) {
+	rows, err := s.db.Query(`SELECT alarm_id, first_notification_sent, last_notification_at, reminders, recovery_sent
+		 FROM notification_states`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make(map[string]models.NotificationState)
+	for rows.Next() {
+		var (
+			alarmID, lastNotificationAt string
+			state                       models.NotificationState
+		)
+		if err := rows.Scan(&alarmID, &state.FirstNotificationSent, &lastNotificationAt, &state.Reminders, &state.RecoverySent); err != nil {
+			return nil, err
+		}
+		if state.LastNotificationAt, err = database.ParseTime(lastNotificationAt); err != nil {
+			return nil, err
+		}
+		result[alarmID] = state
+	}
+	return result, rows.Err()
+}
+
+func (s *sqliteStorage) SaveNotifier(config models.NotifierConfig) error {
+	rules, err := marshalRules(config.Rules)
+	if err != nil {
+		return err
+	}
+	tmpl, err := json.Marshal(config.Template)
+	if err != nil {
+		return err
+	}
+	options, err := json.Marshal(config.HTTP)
+	if err != nil {
+		return err
+	}
+	breaker, err := json.Marshal(config.Breaker)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(
+		`INSERT INTO notifiers (notifier_id, name, type, param, max_attempts, enabled, rules, template, secret, http, breaker)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		config.ID.String(), config.Name, config.Type, config.Param, config.MaxAttempts, config.Enabled, rules, string(tmpl), config.Secret,
+		string(options), string(breaker),
+	)
+	return err
+}
+
+func (s *sqliteStorage) UpdateNotifier(config models.NotifierConfig) error {
+	rules, err := marshalRules(config.Rules)
+	if err != nil {
+		return err
+	}
+	tmpl, err := json.Marshal(config.Template)
+	if err != nil {
+		return err
+	}
+	options, err := json.Marshal(config.HTTP)
+	if err != nil {
+		return err
+	}
+	breaker, err := json.Marshal(config.Breaker)
+	if err != nil {
+		return err
+	}
+	result, err := s.db.Exec(
+		`UPDATE notifiers SET name = ?, type = ?, param = ?, max_attempts = ?, enabled = ?, rules = ?, template = ?, secret = ?, http = ?, breaker = ?
+		 WHERE notifier_id = ?`,
+		config.Name, config.Type, config.Param, config.MaxAttempts, config.Enabled, rules, string(tmpl), config.Secret,
+		string(options), string(breaker), config.ID.String(),
+	)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrNotifierNotFound
+	}
+	return err
+}
+
+// marshalRules stores no rules as an empty list
+func marshalRules(rules []models.RoutingRule) (string, error) {
+	if rules == nil {
+		rules = []models.RoutingRule{}
+	}
+	raw, err := json.Marshal(rules)
+	return string(raw), err
+}
+
+func (s *sqliteStorage) DeleteNotifier(id uuid.UUID) error {
+	result, err := s.db.Exec(`DELETE FROM notifiers WHERE notifier_id = ?`, id.String())
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrNotifierNotFound
+	}
+	return err
+}
+
+func (s *sqliteStorage) GetAllNotifiers() ([]models.NotifierConfig, error) {
+	rows, err := s.db.Query(`SELECT notifier_id, name, type, param, max_attempts, enabled, rules, template, secret, http, breaker FROM notifiers ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []models.NotifierConfig{}
+	for rows.Next() {
+		var (
+			config                            models.NotifierConfig
+			id, rules, tmpl, options, breaker string
+		)
+		if err := rows.Scan(&id, &config.Name, &config.Type, &config.Param, &config.MaxAttempts, &config.Enabled,
+			&rules, &tmpl, &config.Secret, &options, &breaker); err != nil {
+			return nil, err
+		}
+		if config.ID, err = uuid.Parse(id); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(rules), &config.Rules); err != nil {
+			return nil, err
+		}
+		if len(config.Rules) == 0 {
+			config.Rules = nil
+		}
+		if err := json.Unmarshal([]byte(tmpl), &config.Template); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(options), &config.HTTP); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(breaker), &config.Breaker); err != nil {
+			return nil, err
+		}
+		result = append(result, config)
+	}
+	return result, rows.Err()
+}
+
+func (s *sqliteStorage) SaveDeadLetter(deadLetter models.DeadLetter) error {
+	alarm, err := json.Marshal(deadLetter.Alarm)
+	if err != nil {
+		return err
+	}
+	notifier, err := json.Marshal(deadLetter.Notifier)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec(
+		`INSERT OR REPLACE INTO dead_letters (id, alarm, notifier, attempts, last_error, failed_at)
+		 VALUES (?, ?, ?, ?, ?, ?)`,
+		deadLetter.ID.String(),
+		string(alarm),
+		string(notifier),
+		deadLetter.Attempts,
+		deadLetter.LastError,
+		database.FormatTime(deadLetter.FailedAt),
+	)
+	return err
+}
+
+func (s *sqliteStorage) GetDeadLetters() ([]models.DeadLetter, error) {
+	rows, err := s.db.Query(
+		`SELECT id, alarm, notifier, attempts, last_error, failed_at FROM dead_letters ORDER BY failed_at, id`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []models.DeadLetter{}
+	for rows.Next() {
+		deadLetter, err := scanDeadLetter(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, deadLetter)
+	}
+	return result, rows.Err()
+}
+
+func (s *sqliteStorage) GetDeadLetter(id uuid.UUID) (models.DeadLetter, error) {
+	row := s.db.QueryRow(
+		`SELECT id, alarm, notifier, attempts, last_error, failed_at FROM dead_letters WHERE id = ?`,
+		id.String(),
+	)
+	deadLetter, err := scanDeadLetter(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.DeadLetter{}, ErrDeadLetterNotFound
+	}
+	return deadLetter, err
+}
+
+func (s *sqliteStorage) DeleteDeadLetter(id uuid.UUID) error {
+	result, err := s.db.Exec(`DELETE FROM dead_letters WHERE id = ?`, id.String())
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrDeadLetterNotFound
+	}
+	return err
+}
+
+func (s *sqliteStorage) SaveDelivery(record models.DeliveryRecord) error {
+	_, err := s.db.Exec(
+		`INSERT INTO deliveries (id, alarm_id, notifier_id, notifier_type, notifier_param, attempt, latency_ms, status_code, delivered, error, timestamp)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		record.ID.String(),
+		record.AlarmID,
+		record.NotifierID.String(),
+		record.NotifierType,
+		record.NotifierParam,
+		record.Attempt,
+		record.LatencyMS,
+		record.StatusCode,
+		record.Delivered,
+		record.Error,
+		database.FormatTime(record.Timestamp),
+	)
+	return err
+}
+
+func (s *sqliteStorage) GetDeliveries(query models.DeliveryQuery) ([]models.DeliveryRecord, error) {
+	var (
+		conditions []string
+		args       []any
+	)
+	if query.AlarmID != "" {
+		conditions = append(conditions, "alarm_id = ?")
+		args = append(args, query.AlarmID)
+	}
+	if query.NotifierID != "" {
+		conditions = append(conditions, "notifier_id = ?")
+		args = append(args, query.NotifierID)
+	}
+	if query.NotifierType != "" {
+		conditions = append(conditions, "notifier_type = ?")
+		args = append(args, query.NotifierType)
+	}
+	if query.NotifierParam != "" {
+		conditions = append(conditions, "notifier_param = ?")
+		args = append(args, query.NotifierParam)
+	}
+	if query.Status != "" {
+		conditions = append(conditions, "delivered = ?")
+		args = append(args, query.Status == "delivered")
+	}
+	if !query.From.IsZero() {
+		conditions = append(conditions, "timestamp >= ?")
+		args = append(args, database.FormatTime(query.From))
+	}
+	if !query.To.IsZero() {
+		conditions = append(conditions, "timestamp < ?")
+		args = append(args, database.FormatTime(query.To))
+	}
+
+	statement := `SELECT id, alarm_id, notifier_id, notifier_type, notifier_param, attempt, latency_ms, status_code, delivered, error, timestamp
+		FROM deliveries`
+	if len(conditions) > 0 {
+		statement += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	statement += " ORDER BY timestamp DESC, rowid DESC LIMIT ?"
+	limit := query.Limit
+	if limit <= 0 {
+		limit = defaultDeliveryLimit
+	}
+	args = append(args, limit)
+
+	rows, err := s.db.Query(statement, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []models.DeliveryRecord{}
+	for rows.Next() {
+		var (
+			record                    models.DeliveryRecord
+			id, notifierID, timestamp string
+		)
+		err := rows.Scan(&id, &record.AlarmID, &notifierID, &record.NotifierType, &record.NotifierParam, &record.Attempt,
+			&record.LatencyMS, &record.StatusCode, &record.Delivered, &record.Error, &timestamp)
+		if err != nil {
+			return nil, err
+		}
+		if record.ID, err = uuid.Parse(id); err != nil {
+			return nil, err
+		}
+		// Records saved before they had the notifier ID keep it empty
+		if notifierID != "" {
+			if record.NotifierID, err = uuid.Parse(notifierID); err != nil {
+				return nil, err
+			}
+		}
+		if record.Timestamp, err = database.ParseTime(timestamp); err != nil {
+			return nil, err
+		}
+		result = append(result, record)
+	}
+	return result, rows.Err()
+}
+
+func (s *sqliteStorage) PruneDeliveries(before time.Time) (int, error) {
+	result, err := s.db.Exec(`DELETE FROM deliveries WHERE timestamp < ?`, database.FormatTime(before))
+	if err != nil {
+		return 0, err
+	}
+	pruned, err := result.RowsAffected()
+	return int(pruned), err
+}
+
+// scanner is satisfied by both *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanDeadLetter(row scanner) (models.DeadLetter, error) {
+	var (
+		deadLetter                  models.DeadLetter
+		id, alarm, notifier, failed string
+	)
+	if err := row.Scan(&id, &alarm, &notifier, &deadLetter.Attempts, &deadLetter.LastError, &failed); err != nil {
+		return models.DeadLetter{}, err
+	}
+
+	var err error
+	if deadLetter.ID, err = uuid.Parse(id); err != nil {
+		return models.DeadLetter{}, err
+	}
+	if err := json.Unmarshal([]byte(alarm), &deadLetter.Alarm); err != nil {
+		return models.DeadLetter{}, err
+	}
+	if err := json.Unmarshal([]byte(notifier), &deadLetter.Notifier); err != nil {
+		return models.DeadLetter{}, err
+	}
+	if deadLetter.FailedAt, err = database.ParseTime(failed); err != nil {
+		return models.DeadLetter{}, err
+	}
+	return deadLetter, nil
+}
@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"io"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/26christy/CarbonQuest/common/database"
+	"github.com/26christy/CarbonQuest/common/models"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLiteStorage(t *testing.T) {
+	storage, err := NewSQLiteStorage(filepath.Join(t.TempDir(), "notification.db"))
+	require.NoError(t, err)
+	defer storage.(io.Closer).Close()
+
+	testNotificationStorage(t, storage)
+}
+
+func TestSQLiteStorage_DeadLetters(t *testing.T) {
+	storage, err := NewSQLiteStorage(filepath.Join(t.TempDir(), "notification.db"))
+	require.NoError(t, err)
+	defer storage.(io.Closer).Close()
+
+	testDeadLetters(t, storage)
+}
+
+func TestSQLiteStorage_Deliveries(t *testing.T) {
+	storage, err := NewSQLiteStorage(filepath.Join(t.TempDir(), "notification.db"))
+	require.NoError(t, err)
+	defer storage.(io.Closer).Close()
+
+	testDeliveries(t, storage)
+}
+
+func TestSQLiteStorage_SurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notification.db")
+
+	storage, err := NewSQLiteStorage(path)
+	require.NoError(t, err)
+	require.NoError(t, storage.SaveNotificationState("123", models.NotificationState{FirstNotificationSent: true, LastNotificationAt: time.Now()}))
+	require.NoError(t, storage.SaveNotifier(models.NotifierConfig{ID: uuid.New(), Type: "log", Enabled: true}))
+	require.NoError(t, storage.(io.Closer).Close())
+
+	reopened, err := NewSQLiteStorage(path)
+	require.NoError(t, err)
+	defer reopened.(io.Closer).Close()
+
+	states, err := reopened.GetAllNotificationStates()
+	assert.NoError(t, err)
+	assert.True(t, states["123"].FirstNotificationSent)
+
+	configs, err := reopened.GetAllNotifiers()
+	assert.NoError(t, err)
+	assert.Len(t, configs, 1)
+}
+
+func TestSQLiteStorage_MigratesNotifiersWithoutID(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notification.db")
+
+	// A database from before notifiers had an ID, name and enabled flag
+	before := slices.Index(migrations, `ALTER TABLE notifiers ADD COLUMN notifier_id TEXT`)
+	require.Positive(t, before)
+	db, err := database.OpenSQLite(path, migrations[:before])
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO notifiers (type, param, max_attempts) VALUES ('webhook', 'http://example.com', 0), ('log', '', 0)`)
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	storage, err := NewSQLiteStorage(path)
+	require.NoError(t, err)
+	defer storage.(io.Closer).Close()
+
+	configs, err := storage.GetAllNotifiers()
+	require.NoError(t, err)
+	require.Len(t, configs, 2)
+	assert.Equal(t, "webhook", configs[0].Type)
+	assert.NotEqual(t, uuid.Nil, configs[0].ID)
+	assert.NotEqual(t, configs[0].ID, configs[1].ID)
+	assert.Equal(t, uuid.Version(4), configs[0].ID.Version())
+	assert.True(t, configs[0].Enabled, "existing notifiers stay enabled")
+}
+
+func TestCreateStorage(t *testing.T) {
+	storage, err := CreateStorage("memory", "")
+	assert.NoError(t, err)
+	assert.NotNil(t, storage)
+
+	_, err = CreateStorage("sqlite", "")
+	assert.Error(t, err)
+
+	_, err = CreateStorage("unknown", "")
+	assert.Error(t, err)
+}